@@ -0,0 +1,112 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+)
+
+// Tirage vérifiable d'une tombola (commit-reveal).
+//
+// À la création de la tombola, le serveur tire une graine secrète et publie son empreinte
+// SHA-256 (calculée sur la graine en hexadécimal). Au tirage, une valeur publique annoncée devant
+// les participants est mêlée à la graine :
+//
+//	clé = SHA-256(graine + ":" + valeur publique)
+//
+// Les tickets, triés par identifiant croissant, sont mélangés par Fisher-Yates : pour i de n-1 à 1,
+// j est tiré dans [0, i] à partir du flux SHA-256(clé || compteur sur 8 octets big-endian), lu par
+// mots de 8 octets big-endian, en rejetant les mots qui biaiseraient le modulo.
+//
+// Les gains sont les lots triés par rang puis par identifiant croissants, chaque lot répété autant
+// de fois que sa quantité. Le k-ième gain revient au premier ticket mélangé qui n'a pas encore été
+// retenu et, si la règle de gain le demande, dont le groupe (acheteur ou famille) n'a encore rien
+// gagné. Avec des lots de quantité 1 et de même rang et sans règle, le résultat est celui de la v1.
+// Les lots non retirés à la date limite sont remis en jeu en poursuivant ce parcours : le remplaçant
+// est le ticket suivant retenu, si bien qu'aucune nouvelle graine n'est nécessaire.
+// La graine est publiée après le tirage : chacun peut vérifier l'empreinte et recalculer les gagnants.
+const DrawAlgorithm = "sha256-fisher-yates-v2"
+
+// NewDrawSeed tire une graine secrète et renvoie son empreinte à publier
+func NewDrawSeed() (seed, commitment string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	seed = hex.EncodeToString(buf)
+	return seed, DrawCommitment(seed), nil
+}
+
+// DrawCommitment calcule l'empreinte publiée d'une graine
+func DrawCommitment(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// DrawOrder mélange les tickets (identifiants triés par ordre croissant) de façon déterministe
+func DrawOrder(seed, publicValue string, ticketIDs []uint) []uint {
+	order := make([]uint, len(ticketIDs))
+	copy(order, ticketIDs)
+
+	stream := drawStream{key: sha256.Sum256([]byte(seed + ":" + publicValue))}
+	for i := len(order) - 1; i > 0; i-- {
+		j := stream.intn(uint64(i) + 1)
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+// DrawWinners désigne le ticket gagnant de chacun des awards gains, dans l'ordre ; order est le résultat de
+// DrawOrder et groups associe chaque ticket à son groupe, nil lorsque la règle de gain ne limite rien.
+// Moins de awards tickets sont renvoyés lorsque les tickets éligibles viennent à manquer.
+func DrawWinners(order []uint, groups map[uint]uint, awards int) []uint {
+	winners := make([]uint, 0, min(awards, len(order)))
+	won := make(map[uint]bool)
+	for _, ticketID := range order {
+		if len(winners) == awards {
+			break
+		}
+		if groups != nil {
+			// Un groupe qui a gagné le reste : ses tickets suivants sont écartés définitivement
+			if won[groups[ticketID]] {
+				continue
+			}
+			won[groups[ticketID]] = true
+		}
+		winners = append(winners, ticketID)
+	}
+	return winners
+}
+
+type drawStream struct {
+	key     [32]byte
+	counter uint64
+	buf     []byte
+}
+
+func (s *drawStream) next() uint64 {
+	if len(s.buf) < 8 {
+		block := make([]byte, 0, len(s.key)+8)
+		block = append(block, s.key[:]...)
+		block = binary.BigEndian.AppendUint64(block, s.counter)
+		s.counter++
+		sum := sha256.Sum256(block)
+		s.buf = sum[:]
+	}
+	value := binary.BigEndian.Uint64(s.buf[:8])
+	s.buf = s.buf[8:]
+	return value
+}
+
+// intn renvoie un entier uniforme dans [0, n)
+func (s *drawStream) intn(n uint64) uint64 {
+	// 2^64 mod n mots sont écartés pour que chaque reste soit également probable
+	rejected := (math.MaxUint64%n + 1) % n
+	for {
+		if value := s.next(); value <= math.MaxUint64-rejected {
+			return value % n
+		}
+	}
+}
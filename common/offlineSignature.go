@@ -0,0 +1,46 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+)
+
+// offlineSigningKey renvoie la clé utilisée pour signer les autorisations hors ligne
+func offlineSigningKey() []byte {
+	if key := os.Getenv("OFFLINE_SIGNING_KEY"); key != "" {
+		return []byte(key)
+	}
+	return []byte(os.Getenv("SECRET_KEY"))
+}
+
+func sign(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignOfflineAllowance signe une autorisation de dépense hors ligne
+func SignOfflineAllowance(allowanceID, userID, standID uint, plafond int64, expiresAt time.Time) string {
+	payload := fmt.Sprintf("allowance|%d|%d|%d|%d|%d", allowanceID, userID, standID, plafond, expiresAt.Unix())
+	return sign(offlineSigningKey(), payload)
+}
+
+// OfflineSaleKey dérive la clé que le terminal utilise pour signer les ventes d'une autorisation
+func OfflineSaleKey(allowanceID uint) string {
+	return sign(offlineSigningKey(), fmt.Sprintf("sale-key|%d", allowanceID))
+}
+
+// SignOfflineSale signe une vente enregistrée hors ligne avec la clé dérivée de l'autorisation
+func SignOfflineSale(saleKey, clientRef string, allowanceID, userID, stockID uint, quantite int, recordedAt time.Time) string {
+	payload := fmt.Sprintf("sale|%s|%d|%d|%d|%d|%d", clientRef, allowanceID, userID, stockID, quantite, recordedAt.Unix())
+	return sign([]byte(saleKey), payload)
+}
+
+// VerifySignature compare deux signatures en temps constant
+func VerifySignature(expected, given string) bool {
+	return hmac.Equal([]byte(expected), []byte(given))
+}
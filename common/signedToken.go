@@ -0,0 +1,78 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSignedToken = errors.New("invalid token")
+	ErrExpiredSignedToken = errors.New("token expired")
+)
+
+func signedTokenKey() []byte {
+	if key := os.Getenv("TOKEN_SIGNING_KEY"); key != "" {
+		return []byte(key)
+	}
+	return []byte(os.Getenv("SECRET_KEY"))
+}
+
+// NewSignedToken crée un jeton signé lié à un usage, un sujet et une date d'expiration
+func NewSignedToken(purpose string, subject uint, expiresAt time.Time) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	payload := fmt.Sprintf("%s|%d|%d|%s", purpose, subject, expiresAt.Unix(), base64.RawURLEncoding.EncodeToString(nonce))
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+
+	return encoded + "." + sign(signedTokenKey(), encoded), nil
+}
+
+// ParseSignedToken vérifie la signature, l'usage et l'expiration d'un jeton et renvoie son sujet
+func ParseSignedToken(purpose, token string) (uint, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !VerifySignature(sign(signedTokenKey(), encoded), signature) {
+		return 0, ErrInvalidSignedToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, ErrInvalidSignedToken
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 4 || parts[0] != purpose {
+		return 0, ErrInvalidSignedToken
+	}
+
+	subject, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return 0, ErrInvalidSignedToken
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSignedToken
+	}
+	if time.Now().Unix() > expiresAt {
+		return 0, ErrExpiredSignedToken
+	}
+
+	return uint(subject), nil
+}
+
+// HashToken renvoie l'empreinte SHA-256 d'un jeton, seule forme conservée en base
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
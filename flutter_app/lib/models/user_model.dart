@@ -4,6 +4,8 @@ class User {
   final String? email;
   final String? role;
   final int? soldeJetons;
+  // Rôles détenus par l'utilisateur ; role est le rôle actif
+  final List<String> availableRoles;
   String? _password;
 
   User({
@@ -12,6 +14,7 @@ class User {
     required this.email,
     required this.role,
     this.soldeJetons,
+    this.availableRoles = const [],
     String? password,
   }) {
     if (password != null) {
@@ -39,7 +42,9 @@ class User {
       email: json['email']?.toString(),
       role: json['roles']?.toString(),
       soldeJetons: json['solde_jetons'] is int ? json['solde_jetons'] : null,
-
+      availableRoles: json['available_roles'] is List
+          ? List<String>.from(json['available_roles'])
+          : const [],
     );
   }
 
@@ -49,6 +54,7 @@ class User {
       'email': email,
       'roles': role,
       'solde_jetons': soldeJetons,
+      'available_roles': availableRoles,
     };
   }
 
@@ -60,6 +66,7 @@ class User {
       email: this.email,
       role: this.role,
       soldeJetons: this.soldeJetons,
+      availableRoles: this.availableRoles,
       password: newPassword,
     );
   }
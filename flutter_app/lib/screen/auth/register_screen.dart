@@ -17,14 +17,12 @@ class _RegisterScreenState extends State<RegisterScreen> {
   final _confirmPasswordController = TextEditingController();
 
   // Nouveau champ pour stocker le rôle sélectionné
-  String? _selectedRole;
+  String? _selectedRole = 'PARENT';
   var _isLoading = false;
 
+  // L'inscription publique est réservée aux parents : les autres rôles passent par une invitation
   final List<String> roles = [
     'PARENT',
-    'ORGANISATEUR',
-    'TENEUR_STAND',
-    'ELEVE',
   ];
 
   void _submit() async {
@@ -23,6 +23,7 @@ class AuthService with ChangeNotifier {
   final apiAuthority = AppConfig.getApiAuthority();
   final isSecure = AppConfig.isSecure();
   static const String _tokenKey = 'auth_token';
+  static const String _refreshTokenKey = 'refresh_token';
 
   bool get isAuth {
     return token != null;
@@ -204,7 +205,8 @@ class AuthService with ChangeNotifier {
 
         _token = responseData['token'];
         await saveToken(_token!);
-        _expiryDate = DateTime.now().add(Duration(hours: 24));
+        await _storage.write(key: _refreshTokenKey, value: responseData['refresh_token']);
+        _expiryDate = DateTime.now().add(Duration(seconds: responseData['expires_in'] ?? 900));
 
         if (responseData['user'] != null && responseData['user'] is Map<String, dynamic>) {
           _user = User.fromJson(responseData['user']);
@@ -310,7 +312,85 @@ class AuthService with ChangeNotifier {
   }
 
 
+  // Échange le refresh token contre un nouveau jeton d'accès (le refresh token est tourné)
+  Future<bool> refreshAccessToken() async {
+    final refreshToken = await _storage.read(key: _refreshTokenKey);
+    if (refreshToken == null) {
+      return false;
+    }
+
+    final url = isSecure
+        ? Uri.https(apiAuthority, '/api/token/refresh')
+        : Uri.http(apiAuthority, '/api/token/refresh');
+
+    try {
+      final response = await http.post(
+        url,
+        body: jsonEncode({'refresh_token': refreshToken}),
+        headers: {'Content-Type': 'application/json'},
+      );
+
+      if (response.statusCode != 200) {
+        return false;
+      }
+
+      final responseData = json.decode(response.body);
+      _token = responseData['token'];
+      _expiryDate = DateTime.now().add(Duration(seconds: responseData['expires_in'] ?? 900));
+      await saveToken(_token!);
+      await _storage.write(key: _refreshTokenKey, value: responseData['refresh_token']);
+      await _saveAuthData();
+      return true;
+    } catch (error) {
+      print('Erreur lors du rafraîchissement du jeton: $error');
+      return false;
+    }
+  }
+
+  // Active un autre rôle détenu par l'utilisateur ; le serveur renvoie un nouveau jeton d'accès
+  Future<void> switchRole(String role) async {
+    final url = isSecure
+        ? Uri.https(apiAuthority, '/api/users/me/active-role')
+        : Uri.http(apiAuthority, '/api/users/me/active-role');
+
+    final response = await http.post(
+      url,
+      body: jsonEncode({'role': role}),
+      headers: await _getHeaders(),
+    );
+
+    if (response.statusCode != 200) {
+      throw Exception('Impossible de changer de rôle');
+    }
+
+    final responseData = json.decode(response.body);
+    _token = responseData['token'];
+    _expiryDate = DateTime.now().add(Duration(seconds: responseData['expires_in'] ?? 900));
+    await saveToken(_token!);
+    await fetchUserDetails();
+  }
+
   Future<void> logout() async {
+    // Révoque le jeton d'accès et le refresh token côté serveur
+    final refreshToken = await _storage.read(key: _refreshTokenKey);
+    if (_token != null) {
+      final url = isSecure
+          ? Uri.https(apiAuthority, '/api/logout')
+          : Uri.http(apiAuthority, '/api/logout');
+      try {
+        await http.post(
+          url,
+          body: jsonEncode({'refresh_token': refreshToken}),
+          headers: {
+            'Authorization': 'Bearer $_token',
+            'Content-Type': 'application/json',
+          },
+        );
+      } catch (error) {
+        print('Erreur lors de la déconnexion: $error');
+      }
+    }
+
     _token = null;
     _userRole = null;
     _expiryDate = null;
@@ -355,11 +435,13 @@ class AuthService with ChangeNotifier {
 
     final expiryDate = DateTime.parse(extractedUserData['expiryDate']);
 
-    if (expiryDate.isBefore(DateTime.now())) {
-      return false;
-    }
     _token = extractedUserData['token'];
     _expiryDate = expiryDate;
+    if (expiryDate.isBefore(DateTime.now()) && !await refreshAccessToken()) {
+      _token = null;
+      _expiryDate = null;
+      return false;
+    }
     _user = User.fromJson(extractedUserData['user']);
     print('User after auto login: ${_user?.toJson()}');
     notifyListeners();
@@ -371,6 +453,11 @@ class AuthService with ChangeNotifier {
   }
 
   Future<String?> getToken() async {
+    // Rafraîchit le jeton d'accès peu avant son expiration
+    if (_expiryDate != null &&
+        _expiryDate!.isBefore(DateTime.now().add(Duration(seconds: 30)))) {
+      await refreshAccessToken();
+    }
     return await _storage.read(key: _tokenKey);
   }
 
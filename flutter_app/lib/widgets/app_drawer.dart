@@ -118,6 +118,16 @@ class AppDrawer extends StatelessWidget {
                 },
               ),
             Divider(),
+            for (final role in authService.user?.availableRoles ?? const <String>[])
+              if (role != userRole)
+                ListTile(
+                  leading: Icon(Icons.swap_horiz, color: Colors.deepOrange),
+                  title: Text('Passer en $role'),
+                  onTap: () async {
+                    await authService.switchRole(role);
+                    Navigator.pushReplacementNamed(context, '/kermesses');
+                  },
+                ),
             ListTile(
               leading: Icon(Icons.exit_to_app, color: Colors.deepOrange),
               title: Text('Déconnexion'),
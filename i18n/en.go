@@ -0,0 +1,23 @@
+package i18n
+
+// en ne contient que les textes désignés par un identifiant : les autres messages sont déjà en anglais dans le code
+var en = Bundle{
+	// E-mails
+	"email.verification.subject": "Confirm your email address",
+	"email.verification.body":    "Hello %s,\n\nTo activate your account, confirm your email address by opening this link:\n%s\n\nThis link expires in 48 hours.\n",
+
+	"email.password_reset.subject": "Reset your password",
+	"email.password_reset.body":    "Hello %s,\n\nA password reset was requested for your account. Open this link to choose a new password:\n%s\n\nThis link expires in 1 hour. If you did not make this request, ignore this message.\n",
+
+	"email.invitation.subject":  "Invitation to join the kermesse",
+	"email.invitation.kermesse": " for the kermesse “%s”",
+	"email.invitation.body":     "Hello,\n\n%s invites you to join the application with the role %s%s. Open this link to accept the invitation:\n%s\n\nThis link expires in 7 days.\n",
+
+	"email.activation.subject": "Activate your kermesse account",
+	"email.activation.body":    "Hello %s,\n\nAn account was created for you from the school's student list. To activate it, choose your password by opening this link:\n%s\n\nThis link expires in 7 days.\n",
+
+	// Notifications
+	"notification.date_format":    "2006-01-02 15:04",
+	"notification.prize_won":      "Congratulations! Your ticket %s won “%s” in the tombola “%s”. Claim your prize in the app and show your ticket's QR code to collect it.",
+	"notification.prize_deadline": " Collect it before %s.",
+}
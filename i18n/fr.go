@@ -0,0 +1,314 @@
+package i18n
+
+var fr = Bundle{
+	// Erreurs générales
+	"Invalid request format":  "Format de requête invalide",
+	"Some fields are invalid": "Certains champs sont invalides",
+	"Invalid identifier":      "Identifiant invalide",
+	"Internal server error":   "Erreur interne du serveur",
+	"Resource not found":      "Ressource introuvable",
+	"Access denied":           "Accès refusé",
+	"Route not found":         "Route introuvable",
+	"Method not allowed":      "Méthode non autorisée",
+
+	// Validation des champs
+	"This field is required":                                   "Ce champ est obligatoire",
+	"This field is not accepted":                               "Ce champ n'est pas accepté",
+	"Must be a valid email address":                            "Doit être une adresse e-mail valide",
+	"Must be at least %s":                                      "Doit valoir au moins %s",
+	"Must be at most %s":                                       "Doit valoir au plus %s",
+	"Must be after %s":                                         "Doit être postérieur à %s",
+	"Must be greater than %s":                                  "Doit être supérieur à %s",
+	"Must be greater than or equal to %s":                      "Doit être supérieur ou égal à %s",
+	"Must be in the future":                                    "Doit être dans le futur",
+	"Must be less than or equal to %s":                         "Doit être inférieur ou égal à %s",
+	"Must be one of: %s":                                       "Doit valoir l'une des valeurs : %s",
+	"Expected a value of type %s":                              "Une valeur de type %s est attendue",
+	"Invalid value":                                            "Valeur invalide",
+	"Must be a positive integer":                               "Doit être un entier positif",
+	"Must be a user ID":                                        "Doit être un identifiant d'utilisateur",
+	"Must be an RFC 3339 date":                                 "Doit être une date RFC 3339",
+	"Must be true or false":                                    "Doit valoir true ou false",
+	"Invalid or expired cursor":                                "Curseur invalide ou expiré",
+	"Password is required when an email is given":              "Le mot de passe est obligatoire lorsqu'une adresse e-mail est fournie",
+	"remise_quantite and remise_pourcent must be set together": "remise_quantite et remise_pourcent doivent être renseignés ensemble",
+	"Cannot be changed once the tombola is drawn or cancelled": "Ne peut plus être modifié une fois la tombola tirée ou annulée",
+	"Cannot be changed once tickets have been sold":            "Ne peut plus être modifié une fois des tickets vendus",
+	"Cannot be changed once the tombola is drawn":              "Ne peut plus être modifié une fois la tombola tirée",
+	"Unknown stand type":                                       "Type de stand inconnu",
+
+	// Authentification
+	"Authentication required":                              "Authentification requise",
+	"Authorization header is missing":                      "L'en-tête Authorization est absent",
+	"Authorization header must use the Bearer scheme":      "L'en-tête Authorization doit utiliser le schéma Bearer",
+	"Invalid credentials":                                  "Identifiants invalides",
+	"Invalid or expired token":                             "Jeton invalide ou expiré",
+	"Token expired":                                        "Jeton expiré",
+	"Token has been revoked":                               "Le jeton a été révoqué",
+	"Token scope does not allow this action":               "La portée du jeton ne permet pas cette action",
+	"Invalid refresh token":                                "Jeton de rafraîchissement invalide",
+	"Refresh token expired":                                "Jeton de rafraîchissement expiré",
+	"Refresh token has been revoked":                       "Le jeton de rafraîchissement a été révoqué",
+	"Invalid or expired code":                              "Code invalide ou expiré",
+	"Account temporarily locked":                           "Compte temporairement verrouillé",
+	"Too many login attempts, try again later":             "Trop de tentatives de connexion, réessayez plus tard",
+	"Email address not verified":                           "Adresse e-mail non vérifiée",
+	"Email already verified":                               "Adresse e-mail déjà vérifiée",
+	"Email already in use":                                 "Adresse e-mail déjà utilisée",
+	"No account found for this email":                      "Aucun compte ne correspond à cette adresse e-mail",
+	"Invalid or expired MFA token":                         "Jeton de double authentification invalide ou expiré",
+	"Invalid two-factor code":                              "Code de double authentification invalide",
+	"Two-factor authentication is not enabled":             "La double authentification n'est pas activée",
+	"Two-factor authentication already enabled":            "La double authentification est déjà activée",
+	"Two-factor authentication is mandatory for this role": "La double authentification est obligatoire pour ce rôle",
+
+	// Rôles et invitations
+	"Invalid role":                          "Rôle invalide",
+	"Invalid role type":                     "Type de rôle invalide",
+	"Role not held by user":                 "L'utilisateur ne détient pas ce rôle",
+	"Cannot remove the last role of a user": "Impossible de retirer le dernier rôle d'un utilisateur",
+	"Only parent accounts can self-register, other roles require an invitation": "Seuls les comptes parents peuvent s'inscrire eux-mêmes, les autres rôles nécessitent une invitation",
+	"Invalid or expired invitation":                                             "Invitation invalide ou expirée",
+	"Invitation already accepted":                                               "Invitation déjà acceptée",
+	"Invitation is no longer pending":                                           "L'invitation n'est plus en attente",
+	"kermesse_id is required for this role":                                     "kermesse_id est obligatoire pour ce rôle",
+	"stand_id is only allowed for TENEUR_STAND invitations":                     "stand_id n'est autorisé que pour les invitations TENEUR_STAND",
+	"You do not hold this stand or organise its kermesse":                       "Vous ne tenez pas ce stand et n'organisez pas sa kermesse",
+	"You are not an organiser of this kermesse":                                 "Vous n'êtes pas organisateur de cette kermesse",
+	"Only admins can invite admins":                                             "Seuls les administrateurs peuvent inviter des administrateurs",
+	"Only organisers can reassign a stand":                                      "Seuls les organisateurs peuvent réattribuer un stand",
+
+	// Familles et données personnelles
+	"You are not a guardian of this child":                          "Vous n'êtes pas responsable de cet enfant",
+	"Guardian permission does not allow this action":                "Votre autorisation de responsable ne permet pas cette action",
+	"Guardian permission does not allow funding this child":         "Votre autorisation de responsable ne permet pas de créditer cet enfant",
+	"Permission must be FULL, FUND_ONLY or VIEW_ONLY":               "L'autorisation doit valoir FULL, FUND_ONLY ou VIEW_ONLY",
+	"A child cannot be their own guardian":                          "Un enfant ne peut pas être son propre responsable",
+	"A child must keep at least one guardian with full permission":  "Un enfant doit garder au moins un responsable avec l'autorisation complète",
+	"User is neither a parent nor a student":                        "L'utilisateur n'est ni un parent ni un élève",
+	"Child accounts must have the ELEVE role":                       "Les comptes enfants doivent avoir le rôle ELEVE",
+	"Name and password are required to create the account":          "Le nom et le mot de passe sont obligatoires pour créer le compte",
+	"An erasure request is already pending for this account":        "Une demande d'effacement est déjà en attente pour ce compte",
+	"Erasure request already processed":                             "Demande d'effacement déjà traitée",
+	"Account already anonymised":                                    "Compte déjà anonymisé",
+	"No pending erasure request":                                    "Aucune demande d'effacement en attente",
+	"Only a guardian with full permission can ask for this erasure": "Seul un responsable avec l'autorisation complète peut demander cet effacement",
+
+	// Ventes, stocks et tombolas
+	"Daily spending limit exceeded":                                                "Plafond de dépenses journalier dépassé",
+	"Insufficient jeton balance":                                                   "Solde de jetons insuffisant",
+	"No stock available for this stand":                                            "Aucun stock disponible pour ce stand",
+	"Stock quantity cannot be negative":                                            "La quantité en stock ne peut pas être négative",
+	"Stand does not belong to this kermesse":                                       "Le stand n'appartient pas à cette kermesse",
+	"No lots available for the draw":                                               "Aucun lot disponible pour le tirage",
+	"No tickets available for the draw":                                            "Aucun ticket disponible pour le tirage",
+	"No winners were selected in the draw":                                         "Aucun gagnant n'a été désigné lors du tirage",
+	"Ticket sales have not opened yet":                                             "La vente de tickets n'a pas encore commencé",
+	"Ticket sales are closed for this tombola":                                     "La vente de tickets est close pour cette tombola",
+	"Not enough tickets left for this tombola":                                     "Il ne reste pas assez de tickets pour cette tombola",
+	"Ticket limit per user reached for this tombola":                               "Nombre maximal de tickets par personne atteint pour cette tombola",
+	"This tombola has been cancelled":                                              "Cette tombola a été annulée",
+	"The draw must be voided before the tombola can be cancelled":                  "Le tirage doit être annulé avant de pouvoir annuler la tombola",
+	"This prize can no longer be claimed":                                          "Ce lot ne peut plus être réclamé",
+	"This prize has already been handed over":                                      "Ce lot a déjà été remis",
+	"The claim deadline has passed":                                                "La date limite de retrait est dépassée",
+	"Invalid prize QR code for this tombola":                                       "QR code de lot invalide pour cette tombola",
+	"No claim deadline is set for this tombola":                                    "Aucune date limite de retrait n'est fixée pour cette tombola",
+	"The claim deadline has not passed yet":                                        "La date limite de retrait n'est pas encore passée",
+	"The draw has not taken place yet":                                             "Le tirage n'a pas encore eu lieu",
+	"The draw is still being revealed live":                                        "Le tirage est encore en cours de diffusion",
+	"Lots cannot change once the tombola is drawn":                                 "Les lots ne peuvent plus changer une fois la tombola tirée",
+	"A re-draw needs a new public value, different from those of the voided draws": "Un nouveau tirage exige une nouvelle valeur publique, différente de celles des tirages annulés",
+	"No seed commitment was published before ticket sales":                         "Aucune empreinte de graine n'a été publiée avant la vente des tickets",
+	"Sender and recipient IDs are required":                                        "Les identifiants de l'expéditeur et du destinataire sont obligatoires",
+
+	// Imports
+	"File is required":    "Le fichier est obligatoire",
+	"File is too large":   "Le fichier est trop volumineux",
+	"Failed to read file": "Échec de la lecture du fichier",
+	"unsupported file format, expected .csv or .xlsx": "format de fichier non pris en charge, .csv ou .xlsx attendu",
+	"file is empty":                     "le fichier est vide",
+	"missing column":                    "colonne manquante",
+	"required":                          "obligatoire",
+	"invalid email":                     "adresse e-mail invalide",
+	"must differ from the parent email": "doit être différente de l'adresse e-mail du parent",
+	"duplicate of row %d":               "doublon de la ligne %d",
+	"email already used by an account that is not a student": "adresse e-mail déjà utilisée par un compte qui n'est pas un élève",
+
+	// Ressources introuvables
+	"User not found":            "Utilisateur introuvable",
+	"Child not found":           "Enfant introuvable",
+	"Parent not found":          "Parent introuvable",
+	"Guardian not found":        "Responsable introuvable",
+	"Teneur not found":          "Teneur de stand introuvable",
+	"Kermesse not found":        "Kermesse introuvable",
+	"Stand not found":           "Stand introuvable",
+	"Stock not found":           "Stock introuvable",
+	"Tombola not found":         "Tombola introuvable",
+	"Lot not found":             "Lot introuvable",
+	"Gagnant not found":         "Gagnant introuvable",
+	"Invitation not found":      "Invitation introuvable",
+	"Message not found":         "Message introuvable",
+	"Session not found":         "Session introuvable",
+	"Erasure request not found": "Demande d'effacement introuvable",
+	"Sender not found":          "Expéditeur introuvable",
+	"Recipient not found":       "Destinataire introuvable",
+
+	// Erreurs serveur
+	"Error retrieving parent":                        "Erreur lors de la récupération du parent",
+	"Error retrieving tombola":                       "Erreur lors de la récupération de la tombola",
+	"Failed to accept invitation":                    "Échec de l'acceptation de l'invitation",
+	"Failed to adjust stock":                         "Échec de l'ajustement du stock",
+	"Failed to cancel erasure request":               "Échec de l'annulation de la demande d'effacement",
+	"Failed to cancel tombola":                       "Échec de l'annulation de la tombola",
+	"Failed to check guardian permission":            "Échec de la vérification de l'autorisation du responsable",
+	"Failed to claim prize":                          "Échec de la réclamation du lot",
+	"Failed to check permissions":                    "Échec de la vérification des autorisations",
+	"Failed to check spending limit":                 "Échec de la vérification du plafond de dépenses",
+	"Failed to commit transaction":                   "Échec de la validation de la transaction",
+	"Failed to complete child addition":              "Échec de l'ajout de l'enfant",
+	"Failed to complete jeton purchase":              "Échec de l'achat de jetons",
+	"Failed to complete jeton transfer":              "Échec du transfert de jetons",
+	"Failed to complete login":                       "Échec de la connexion",
+	"Failed to complete payment":                     "Échec du paiement",
+	"Failed to complete registration":                "Échec de l'inscription",
+	"Failed to complete ticket purchase":             "Échec de l'achat du ticket",
+	"Failed to compute spending":                     "Échec du calcul des dépenses",
+	"Failed to compute ticket sales report":          "Échec du calcul du rapport des ventes de tickets",
+	"Failed to count tickets":                        "Échec du comptage des tickets",
+	"Failed to create child user":                    "Échec de la création du compte enfant",
+	"Failed to create eleve record":                  "Échec de la création de la fiche élève",
+	"Failed to create erasure request":               "Échec de la création de la demande d'effacement",
+	"Failed to create invitation":                    "Échec de la création de l'invitation",
+	"Failed to create kermesse":                      "Échec de la création de la kermesse",
+	"Failed to create lot":                           "Échec de la création du lot",
+	"Failed to create offline consent":               "Échec de la création de l'accord hors ligne",
+	"Authorisation code already used":                "Code d'autorisation déjà utilisé",
+	"Invalid or expired customer authorisation code": "Code d'autorisation du client invalide ou expiré",
+	"Failed to create offline allowance":             "Échec de la création de l'autorisation hors ligne",
+	"Failed to create payment intent":                "Échec de la création du paiement",
+	"Failed to create stand":                         "Échec de la création du stand",
+	"Failed to create stock entry":                   "Échec de la création du stock",
+	"Failed to create ticket":                        "Échec de la création du ticket",
+	"Failed to create tombola":                       "Échec de la création de la tombola",
+	"Failed to create transaction":                   "Échec de la création de la transaction",
+	"Failed to create user":                          "Échec de la création de l'utilisateur",
+	"Failed to create user profile":                  "Échec de la création du profil utilisateur",
+	"Failed to create/update stock for stand":        "Échec de la création ou de la mise à jour du stock du stand",
+	"Failed to delete kermesse":                      "Échec de la suppression de la kermesse",
+	"Failed to delete stand":                         "Échec de la suppression du stand",
+	"Failed to delete stock":                         "Échec de la suppression du stock",
+	"Failed to delete user":                          "Échec de la suppression de l'utilisateur",
+	"Failed to disable two-factor authentication":    "Échec de la désactivation de la double authentification",
+	"Failed to enable two-factor authentication":     "Échec de l'activation de la double authentification",
+	"Failed to export data":                          "Échec de l'export des données",
+	"Failed to fetch children":                       "Échec de la récupération des enfants",
+	"Failed to fetch interactions":                   "Échec de la récupération des interactions",
+	"Failed to fetch parents":                        "Échec de la récupération des parents",
+	"Failed to fetch students":                       "Échec de la récupération des élèves",
+	"Failed to find parent":                          "Échec de la recherche du parent",
+	"Failed to generate PIN":                         "Échec de la génération du PIN",
+	"Failed to generate login code":                  "Échec de la génération du code de connexion",
+	"Failed to generate ticket number":               "Échec de la génération du numéro de ticket",
+	"Failed to generate token":                       "Échec de la génération du jeton",
+	"Failed to generate username":                    "Échec de la génération de l'identifiant",
+	"Failed to grant role":                           "Échec de l'attribution du rôle",
+	"Failed to import roster":                        "Échec de l'import de la liste des élèves",
+	"Failed to link child to parent":                 "Échec du rattachement de l'enfant au parent",
+	"Failed to mark message as read":                 "Échec du marquage du message comme lu",
+	"Failed to perform draw":                         "Échec du tirage",
+	"Failed to process erasure request":              "Échec du traitement de la demande d'effacement",
+	"Failed to process password":                     "Échec du traitement du mot de passe",
+	"Failed to publish draw commitment":              "Échec de la publication de l'empreinte du tirage",
+	"Failed to hand over prize":                      "Échec de la remise du lot",
+	"Failed to record jeton transaction":             "Échec de l'enregistrement de la transaction de jetons",
+	"Failed to record transaction":                   "Échec de l'enregistrement de la transaction",
+	"Failed to redraw unclaimed prizes":              "Échec de la remise en jeu des lots non retirés",
+	"Failed to refresh token":                        "Échec du rafraîchissement du jeton",
+	"Failed to regenerate recovery codes":            "Échec de la régénération des codes de secours",
+	"Failed to reset PIN":                            "Échec de la réinitialisation du PIN",
+	"Failed to reset password":                       "Échec de la réinitialisation du mot de passe",
+	"Failed to reset two-factor authentication":      "Échec de la réinitialisation de la double authentification",
+	"Failed to retrieve activity stands":             "Échec de la récupération des stands d'activité",
+	"Failed to retrieve audit logs":                  "Échec de la récupération du journal d'audit",
+	"Failed to retrieve auth events":                 "Échec de la récupération des événements de connexion",
+	"Failed to retrieve child":                       "Échec de la récupération de l'enfant",
+	"Failed to retrieve draw":                        "Échec de la récupération du tirage",
+	"Failed to retrieve conversation":                "Échec de la récupération de la conversation",
+	"Failed to retrieve erasure requests":            "Échec de la récupération des demandes d'effacement",
+	"Failed to retrieve gagnants":                    "Échec de la récupération des gagnants",
+	"Failed to retrieve guardians":                   "Échec de la récupération des responsables",
+	"Failed to retrieve invitations":                 "Échec de la récupération des invitations",
+	"Failed to retrieve kermesses":                   "Échec de la récupération des kermesses",
+	"Failed to retrieve lots":                        "Échec de la récupération des lots",
+	"Failed to retrieve messages":                    "Échec de la récupération des messages",
+	"Failed to retrieve sessions":                    "Échec de la récupération des sessions",
+	"Failed to retrieve tickets":                     "Échec de la récupération des tickets",
+	"Failed to retrieve stands":                      "Échec de la récupération des stands",
+	"Failed to retrieve stocks":                      "Échec de la récupération des stocks",
+	"Failed to retrieve students, parents and users": "Échec de la récupération des élèves, parents et utilisateurs",
+	"Failed to retrieve tombolas":                    "Échec de la récupération des tombolas",
+	"Failed to retrieve transaction summary":         "Échec de la récupération du récapitulatif des transactions",
+	"Failed to retrieve transactions":                "Échec de la récupération des transactions",
+	"Failed to retrieve unread messages":             "Échec de la récupération des messages non lus",
+	"Failed to retrieve user roles":                  "Échec de la récupération des rôles de l'utilisateur",
+	"Failed to retrieve user tickets":                "Échec de la récupération des tickets de l'utilisateur",
+	"Failed to retrieve users":                       "Échec de la récupération des utilisateurs",
+	"Failed to revoke invitation":                    "Échec de la révocation de l'invitation",
+	"Failed to revoke role":                          "Échec du retrait du rôle",
+	"Failed to revoke session":                       "Échec de la révocation de la session",
+	"Failed to revoke token":                         "Échec de la révocation du jeton",
+	"Failed to send message":                         "Échec de l'envoi du message",
+	"Failed to send verification email":              "Échec de l'envoi de l'e-mail de vérification",
+	"Failed to start enrollment":                     "Échec du démarrage de l'activation",
+	"Failed to switch role":                          "Échec du changement de rôle",
+	"Failed to unlock user":                          "Échec du déverrouillage de l'utilisateur",
+	"Failed to update child balance":                 "Échec de la mise à jour du solde de l'enfant",
+	"Failed to update guardians":                     "Échec de la mise à jour des responsables",
+	"Failed to update kermesse":                      "Échec de la mise à jour de la kermesse",
+	"Failed to update lot":                           "Échec de la mise à jour du lot",
+	"Failed to update parent balance":                "Échec de la mise à jour du solde du parent",
+	"Failed to update parent points":                 "Échec de la mise à jour des points du parent",
+	"Failed to update points":                        "Échec de la mise à jour des points",
+	"Failed to update spending limit":                "Échec de la mise à jour du plafond de dépenses",
+	"Failed to update stand":                         "Échec de la mise à jour du stand",
+	"Failed to update stand jetons":                  "Échec de la mise à jour des jetons du stand",
+	"Failed to update stock":                         "Échec de la mise à jour du stock",
+	"Failed to update student points":                "Échec de la mise à jour des points de l'élève",
+	"Failed to update tombola":                       "Échec de la mise à jour de la tombola",
+	"Failed to update user":                          "Échec de la mise à jour de l'utilisateur",
+	"Failed to update user balance":                  "Échec de la mise à jour du solde de l'utilisateur",
+	"Failed to verify audit logs":                    "Échec de la vérification du journal d'audit",
+	"Failed to verify email":                         "Échec de la vérification de l'adresse e-mail",
+	"Failed to void draw":                            "Échec de l'annulation du tirage",
+
+	// Confirmations
+	"User registered successfully":           "Inscription réussie",
+	"Child added successfully":               "Enfant ajouté avec succès",
+	"Payment successful":                     "Paiement effectué",
+	"Jetons purchased successfully":          "Jetons achetés avec succès",
+	"Jetons transferred successfully":        "Jetons transférés avec succès",
+	"Jetons collected successfully":          "Jetons encaissés avec succès",
+	"Points attributed successfully":         "Points attribués avec succès",
+	"Ticket purchased successfully":          "Ticket acheté avec succès",
+	"Tombola cancelled and tickets refunded": "Tombola annulée et tickets remboursés",
+
+	// E-mails
+	"email.verification.subject": "Confirmez votre adresse e-mail",
+	"email.verification.body":    "Bonjour %s,\n\nPour activer votre compte, confirmez votre adresse e-mail en ouvrant ce lien :\n%s\n\nCe lien expire dans 48 heures.\n",
+
+	"email.password_reset.subject": "Réinitialisation de votre mot de passe",
+	"email.password_reset.body":    "Bonjour %s,\n\nUne réinitialisation de mot de passe a été demandée pour votre compte. Ouvrez ce lien pour choisir un nouveau mot de passe :\n%s\n\nCe lien expire dans 1 heure. Si vous n'êtes pas à l'origine de cette demande, ignorez ce message.\n",
+
+	"email.invitation.subject":  "Invitation à rejoindre la kermesse",
+	"email.invitation.kermesse": " pour la kermesse « %s »",
+	"email.invitation.body":     "Bonjour,\n\n%s vous invite à rejoindre l'application avec le rôle %s%s. Ouvrez ce lien pour accepter l'invitation :\n%s\n\nCe lien expire dans 7 jours.\n",
+
+	"email.activation.subject": "Activez votre compte kermesse",
+	"email.activation.body":    "Bonjour %s,\n\nUn compte a été créé pour vous à partir de la liste des élèves de l'école. Pour l'activer, choisissez votre mot de passe en ouvrant ce lien :\n%s\n\nCe lien expire dans 7 jours.\n",
+
+	// Notifications
+	"notification.date_format":    "02/01/2006 à 15h04",
+	"notification.prize_won":      "Félicitations ! Votre ticket %s a gagné « %s » à la tombola « %s ». Réclamez votre lot dans l'application et présentez le QR code de votre ticket pour le retirer.",
+	"notification.prize_deadline": " Retirez-le avant le %s.",
+}
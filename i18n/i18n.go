@@ -0,0 +1,113 @@
+// Package i18n traduit les messages de l'API, des e-mails et des notifications.
+//
+// L'anglais est la langue source : les messages courts sont écrits en anglais dans le code et ce
+// texte sert de clé au catalogue français. Les textes longs (e-mails, notifications) utilisent un
+// identifiant, par exemple "email.verification.body", défini dans les deux catalogues.
+// Un message absent du catalogue est renvoyé tel quel.
+package i18n
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	French  = "fr"
+	English = "en"
+
+	// Default s'applique lorsque ni la préférence de l'utilisateur ni Accept-Language ne désignent une langue connue
+	Default = French
+
+	// ContextKey est la clé de contexte gin de la langue enregistrée par l'utilisateur connecté
+	ContextKey = "userLanguage"
+)
+
+// Bundle associe une clé de message à sa traduction ; les traductions peuvent contenir des verbes fmt
+type Bundle map[string]string
+
+var bundles = map[string]Bundle{
+	French:  fr,
+	English: en,
+}
+
+// Supported indique si la langue dispose d'un catalogue
+func Supported(lang string) bool {
+	_, ok := bundles[lang]
+	return ok
+}
+
+// Normalize réduit une étiquette de langue (fr-FR, en_GB...) à une langue du catalogue, ou "" si elle n'est pas prise en charge
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if base, _, found := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-"); found {
+		tag = base
+	}
+	if Supported(tag) {
+		return tag
+	}
+	return ""
+}
+
+// ParseAcceptLanguage renvoie la langue prise en charge de plus haute priorité dans un en-tête Accept-Language, ou ""
+func ParseAcceptLanguage(header string) string {
+	best, bestWeight := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		// À poids égal, l'ordre de l'en-tête départage
+		if lang := Normalize(tag); lang != "" && weight > bestWeight {
+			best, bestWeight = lang, weight
+		}
+	}
+	return best
+}
+
+// FromContext choisit la langue de la réponse : préférence enregistrée de l'utilisateur connecté,
+// puis Accept-Language, puis la langue par défaut
+func FromContext(c *gin.Context) string {
+	if lang := Normalize(c.GetString(ContextKey)); lang != "" {
+		return lang
+	}
+	if lang := ParseAcceptLanguage(c.GetHeader("Accept-Language")); lang != "" {
+		return lang
+	}
+	return Default
+}
+
+// ForRecipient choisit la langue d'un message adressé à un autre utilisateur (e-mail, notification) :
+// sa préférence enregistrée, à défaut la langue de la requête qui déclenche l'envoi
+func ForRecipient(c *gin.Context, preference string) string {
+	if lang := Normalize(preference); lang != "" {
+		return lang
+	}
+	return FromContext(c)
+}
+
+// Translate traduit key dans la langue demandée puis applique args
+func Translate(lang, key string, args ...interface{}) string {
+	message, ok := bundles[lang][key]
+	if !ok {
+		if message, ok = en[key]; !ok {
+			message = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// T traduit key dans la langue de la requête
+func T(c *gin.Context, key string, args ...interface{}) string {
+	return Translate(FromContext(c), key, args...)
+}
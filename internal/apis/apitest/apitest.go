@@ -0,0 +1,136 @@
+// Package apitest fournit aux tests des contrôleurs une base SQLite en mémoire et de quoi appeler un handler
+// et vérifier l'enveloppe des réponses de l'API.
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"example/hello/internal/apis/pagination"
+	"example/hello/internal/initializers"
+	"example/hello/response"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var databases atomic.Int64
+
+// OpenDatabase remplace initializers.DB, le temps du test, par une base en mémoire contenant les tables de models
+func OpenDatabase(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:apitest%d?mode=memory&cache=shared", databases.Add(1))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := initializers.DB
+	initializers.DB = db
+	t.Cleanup(func() {
+		initializers.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// Create enregistre value dans la base du test
+func Create(t *testing.T, value interface{}) {
+	t.Helper()
+	if err := initializers.DB.Create(value).Error; err != nil {
+		t.Fatalf("create %T: %v", value, err)
+	}
+}
+
+// Get appelle handler, monté sur route (par exemple /tombolas/:id/lots), pour l'URL target
+func Get(t *testing.T, route string, handler gin.HandlerFunc, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(route, handler)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
+
+// Send appelle handlers, montés sur route pour method, avec body encodé en JSON ; les premiers handlers
+// préparent le contexte (voir As)
+func Send(t *testing.T, method, route, target string, body interface{}, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("encode body: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, handlers...)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	request.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// As renseigne le contexte comme le ferait middleware.JWTProtected pour l'utilisateur userID et ses rôles
+func As(userID uint, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("userID", userID)
+		if len(roles) > 0 {
+			c.Set("userRole", roles[0])
+		}
+		c.Set("userRoles", roles)
+	}
+}
+
+// ExpectError vérifie le statut et le code d'une réponse d'erreur
+func ExpectError(t *testing.T, recorder *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Fatalf("status = %d, want %d (body %s)", recorder.Code, status, recorder.Body)
+	}
+	var body response.ErrorResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error body: %v (body %s)", err, recorder.Body)
+	}
+	if body.Error.Code != code {
+		t.Fatalf("error code = %q, want %q", body.Error.Code, code)
+	}
+}
+
+// ExpectEmptyPage vérifie une réponse 200 dont data vaut [] et dont page décrit une première page vide triée par sort
+func ExpectEmptyPage(t *testing.T, recorder *httptest.ResponseRecorder, sort string) {
+	t.Helper()
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (body %s)", recorder.Code, http.StatusOK, recorder.Body)
+	}
+	var body struct {
+		Data json.RawMessage    `json:"data"`
+		Page *response.PageMeta `json:"page"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode page: %v (body %s)", err, recorder.Body)
+	}
+	if string(body.Data) != "[]" {
+		t.Fatalf("data = %s, want []", body.Data)
+	}
+	if body.Page == nil {
+		t.Fatalf("page metadata missing (body %s)", recorder.Body)
+	}
+	want := response.PageMeta{Limit: pagination.DefaultLimit, Sort: sort}
+	if *body.Page != want {
+		t.Fatalf("page = %+v, want %+v", *body.Page, want)
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"errors"
+	"example/hello/i18n"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/mailer"
+	"example/hello/internal/models"
+	"example/hello/requests"
+	"example/hello/response"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+// sendVerificationEmail émet un jeton de vérification et l'envoie à l'utilisateur
+func sendVerificationEmail(c *gin.Context, user models.User) error {
+	token, err := services.IssueUserToken(initializers.DB, user.ID, models.UserTokenEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	mailer.SendAsync(mailer.VerificationEmail(i18n.ForRecipient(c, user.Language), user.Email, user.Name, token))
+	return nil
+}
+
+// VerifyEmail godoc
+// @Summary Verify an email address
+// @Description Confirm the user's email address with the token received by email
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/email/verify [post]
+func VerifyEmail(c *gin.Context) {
+	var req requests.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		userID, err := services.ConsumeUserToken(tx, models.UserTokenEmailVerification, req.Token)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ? AND email_verified_at IS NULL", userID).
+			Update("email_verified_at", time.Now()).Error
+	})
+	if errors.Is(err, services.ErrInvalidUserToken) {
+		response.Fail(c, response.ErrLinkInvalid)
+		return
+	}
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to verify email"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
+
+// ResendVerificationEmail godoc
+// @Summary Resend the verification email
+// @Description Send a new email verification link to the current user
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} response.SuccessResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/email/verify/resend [post]
+func ResendVerificationEmail(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		response.Fail(c, response.ErrEmailAlreadyVerified)
+		return
+	}
+
+	if err := sendVerificationEmail(c, user); err != nil {
+		response.Fail(c, response.Internal("Failed to send verification email"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Send a password reset link if an account exists for this email
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.ForgotPasswordRequest true "Account email"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	var req requests.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	// La réponse est identique que le compte existe ou non, pour ne pas révéler les adresses inscrites
+	var user models.User
+	if err := initializers.DB.Where("email = ?", req.Email).First(&user).Error; err == nil {
+		token, err := services.IssueUserToken(initializers.DB, user.ID, models.UserTokenPasswordReset, passwordResetTTL)
+		if err != nil {
+			log.Println("Erreur lors de la création du jeton de réinitialisation:", err)
+		} else {
+			mailer.SendAsync(mailer.PasswordResetEmail(i18n.ForRecipient(c, user.Language), user.Email, user.Name, token))
+		}
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
+
+// ResetPassword godoc
+// @Summary Reset the password
+// @Description Set a new password with the token received by email; every session is revoked
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/password/reset [post]
+func ResetPassword(c *gin.Context) {
+	var req requests.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to process password"))
+		return
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		userID, err := services.ConsumeUserToken(tx, models.UserTokenPasswordReset, req.Token)
+		if err != nil {
+			return err
+		}
+
+		// Le lien prouve aussi la possession de l'adresse e-mail et lève un éventuel verrouillage
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password":          string(passwordHash),
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+			"failed_logins":     0,
+			"locked_until":      nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if errors.Is(err, services.ErrInvalidUserToken) {
+		response.Fail(c, response.ErrLinkInvalid)
+		return
+	}
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to reset password"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
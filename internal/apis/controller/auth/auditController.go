@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"example/hello/internal/apis/pagination"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/response"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var auditLogList = pagination.Spec{
+	Model: &models.AuditLog{},
+	Filters: []pagination.Filter{
+		pagination.Equal("actor_id", "actor_id", pagination.ID),
+		pagination.Equal("action", "action", pagination.Text),
+		pagination.Equal("target_type", "target_type", pagination.Text),
+		pagination.Equal("target_id", "target_id", pagination.Text),
+		pagination.From("from", "created_at"),
+		pagination.To("to", "created_at"),
+	},
+	// L'ordre des identifiants est celui du chaînage
+	Sorts:        map[string]string{"id": "id"},
+	DefaultSort:  "-id",
+	DefaultLimit: 100,
+	MaxLimit:     500,
+}
+
+// GetAuditLogs godoc
+// @Summary List the audit log
+// @Description List privileged and financial operations, newest first. Each entry records the actor, the action, the target and the fields changed
+// @Tags Auth
+// @Produce json
+// @Param actor_id query int false "Filter by actor user ID"
+// @Param action query string false "Filter by action (e.g. stand.update)"
+// @Param target_type query string false "Filter by target type (e.g. stand)"
+// @Param target_id query string false "Filter by target ID"
+// @Param from query string false "Only entries at or after this date (RFC 3339)"
+// @Param to query string false "Only entries before this date (RFC 3339)"
+// @Param sort query string false "id or -id" default(-id)
+// @Param limit query int false "Page size (default 100, max 500)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.AuditLog}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/audit-logs [get]
+func GetAuditLogs(c *gin.Context) {
+	params, err := pagination.Parse(c, auditLogList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var entries []models.AuditLog
+	page, err := pagination.Find(initializers.DB, params, &entries)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve audit logs"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.PageResponse{Data: entries, Page: page})
+}
+
+// VerifyAuditLogs godoc
+// @Summary Check the integrity of the audit log
+// @Description Recompute the hash chain of the audit log and report the first entry that was altered, if any
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} response.AuditChainResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/audit-logs/verify [get]
+func VerifyAuditLogs(c *gin.Context) {
+	report, err := services.VerifyAuditChain(initializers.DB)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to verify audit logs"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.AuditChainResponse{
+		Valid:         report.Valid,
+		Checked:       report.Checked,
+		FirstBrokenID: report.FirstBrokenID,
+		Reason:        report.Reason,
+	})
+}
@@ -1,61 +1,151 @@
 package auth
 
 import (
+	"example/hello/common"
+	"example/hello/i18n"
+	"example/hello/internal/apis/services"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
+	"example/hello/internal/token"
 	"example/hello/requests"
 	"example/hello/response"
 	"fmt"
-	"os"
+	"log"
+	"math"
+	"strconv"
 	"time"
 
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 // Login godoc
 // @Summary Allow you to log in and get a JWT Token
-// @Description Login to the app
+// @Description Login to the app. Repeated failures are throttled per IP and per email, and lock the account temporarily.
+// @Description Accounts with two-factor authentication (mandatory for ADMIN and ORGANISATEUR) get a 202 with an mfa_token to complete on /api/login/mfa
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param user body requests.LoginRequest true "User credentials"
 // @Success 200 {object} response.TokenResponse
+// @Success 202 {object} response.MFAChallengeResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 401 {object} response.ErrorResponse
+// @Failure 423 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /api/login [post]
 func Login(c *gin.Context) {
 	var loginReq requests.LoginRequest
 
 	if err := c.ShouldBindJSON(&loginReq); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	ip := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+
+	// Ralentissement exponentiel par IP et par e-mail
+	if wait := services.LoginRetryAfter(ip, loginReq.Email); wait > 0 {
+		services.RecordAuthEvent(nil, loginReq.Email, ip, userAgent, models.AuthEventLoginThrottled, fmt.Sprintf("retry after %s", wait.Round(time.Second)))
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		response.Fail(c, response.ErrTooManyAttempts)
 		return
 	}
 
 	var user models.User
 	result := initializers.DB.Where("email = ?", loginReq.Email).First(&user)
 	if result.Error != nil {
-		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Invalid credentials"})
+		services.RecordLoginFailure(ip, loginReq.Email)
+		services.RecordAuthEvent(nil, loginReq.Email, ip, userAgent, models.AuthEventLoginFailed, "unknown email")
+		response.Fail(c, response.ErrInvalidCredentials)
+		return
+	}
+
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		services.RecordAuthEvent(&user.ID, user.Email, ip, userAgent, models.AuthEventLoginLocked, "")
+		response.Fail(c, response.ErrAccountLocked)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginReq.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Invalid credentials"})
+		services.RecordLoginFailure(ip, loginReq.Email)
+		registerFailedLogin(&user, ip, userAgent)
+		response.Fail(c, response.ErrInvalidCredentials)
 		return
 	}
 
-	token, err := generateJWT(user)
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		initializers.DB.Model(&user).Updates(map[string]interface{}{"failed_logins": 0, "locked_until": nil})
+	}
+	services.ResetLoginFailures(user.Email)
+
+	roles, err := services.UserRoles(initializers.DB, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to generate token"})
+		response.Fail(c, response.Internal("Failed to generate token"))
 		return
 	}
 
-	c.JSON(http.StatusOK, response.TokenResponse{Token: token})
+	// Les comptes protégés par un second facteur ne reçoivent leurs jetons qu'après /api/login/mfa
+	if user.TOTPEnabledAt != nil || services.MFARequired(roles...) {
+		mfaToken, err := services.IssueUserToken(initializers.DB, user.ID, models.UserTokenMFALogin, mfaTokenTTL)
+		if err != nil {
+			response.Fail(c, response.Internal("Failed to generate token"))
+			return
+		}
+		c.JSON(http.StatusAccepted, response.MFAChallengeResponse{
+			MFARequired:        true,
+			EnrollmentRequired: user.TOTPEnabledAt == nil,
+			MFAToken:           mfaToken,
+			ExpiresIn:          int64(mfaTokenTTL.Seconds()),
+		})
+		return
+	}
+
+	tokens, _, err := issueTokens(c, initializers.DB, user, loginReq.DeviceName, "", "")
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to generate token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// registerFailedLogin incrémente le compteur d'échecs et verrouille le compte au-delà du seuil.
+// L'incrément est fait par la base pour que les échecs simultanés s'additionnent ; un verrou expiré
+// remet le compteur à zéro avant cet échec.
+func registerFailedLogin(user *models.User, ip, userAgent string) {
+	now := time.Now()
+	if err := initializers.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"failed_logins": gorm.Expr("CASE WHEN locked_until IS NOT NULL AND locked_until <= ? THEN 1 ELSE failed_logins + 1 END", now),
+		"locked_until":  gorm.Expr("CASE WHEN locked_until <= ? THEN NULL ELSE locked_until END", now),
+	}).Error; err != nil {
+		log.Println("Erreur lors de la mise à jour du compteur d'échecs:", err)
+		return
+	}
+	if err := initializers.DB.Select("failed_logins", "locked_until").First(user, user.ID).Error; err != nil {
+		log.Println("Erreur lors de la lecture du compteur d'échecs:", err)
+		return
+	}
+
+	event := models.AuthEventLoginFailed
+	details := fmt.Sprintf("%d consecutive failures", user.FailedLogins)
+	if user.FailedLogins >= services.MaxFailedLogins && user.LockedUntil == nil {
+		lockedUntil := now.Add(services.AccountLockDelay)
+		result := initializers.DB.Model(&models.User{}).Where("id = ? AND locked_until IS NULL", user.ID).Update("locked_until", lockedUntil)
+		if result.Error != nil {
+			log.Println("Erreur lors du verrouillage du compte:", result.Error)
+		} else if result.RowsAffected > 0 {
+			user.LockedUntil = &lockedUntil
+			event = models.AuthEventAccountLocked
+			details = fmt.Sprintf("locked until %s after %d failures", lockedUntil.Format(time.RFC3339), user.FailedLogins)
+		}
+	}
+	services.RecordAuthEvent(&user.ID, user.Email, ip, userAgent, event, details)
 }
 
 func stringToRole(roleStr string) (models.Role, error) {
@@ -77,13 +167,14 @@ func stringToRole(roleStr string) (models.Role, error) {
 
 // Register godoc
 // @Summary Allow you to register as a new User
-// @Description Create a new user with the provided information
+// @Description Create a new parent account. Other roles are granted through invitations
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param user body requests.RegisterRequest true "User data"
 // @Success 201 {object} response.UserResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
 // @Failure 409 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /api/register [post]
@@ -91,7 +182,7 @@ func Register(c *gin.Context) {
 	var registerReq requests.RegisterRequest
 
 	if err := c.ShouldBindJSON(&registerReq); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
@@ -99,21 +190,21 @@ func Register(c *gin.Context) {
 	var existingUser models.User
 	result := initializers.DB.Where("email = ?", registerReq.Email).First(&existingUser)
 	if result.Error == nil {
-		c.JSON(http.StatusConflict, response.ErrorResponse{Error: "Email already in use"})
+		response.Fail(c, response.ErrEmailInUse)
 		return
 	}
 
-	// Conversion du rôle
-	role, err := stringToRole(registerReq.Role)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	// L'inscription publique ne crée que des comptes parents ; les autres rôles passent par une invitation
+	if registerReq.Role != "" && registerReq.Role != models.RoleParent.String() {
+		response.Fail(c, response.ErrRegistrationRole)
 		return
 	}
+	role := models.RoleParent
 
 	// Hachage du mot de passe
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(registerReq.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to process password"})
+		response.Fail(c, response.Internal("Failed to process password"))
 		return
 	}
 
@@ -129,70 +220,40 @@ func Register(c *gin.Context) {
 
 	if err := tx.Create(&newUser).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create user"})
+		response.Fail(c, response.Internal("Failed to create user"))
 		return
 	}
 
-	// Créer l'entrée correspondante selon le rôle
-	switch registerReq.Role {
-	case "ELEVE":
-		eleve := models.Eleve{
-			UserID: newUser.ID,
-		}
-		if err := tx.Create(&eleve).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create eleve"})
-			return
-		}
-	case "PARENT":
-		parent := models.Parent{
-			UserID: newUser.ID,
-		}
-		if err := tx.Create(&parent).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create parent"})
-			return
-		}
-
-	case "ORGANISATEUR":
-		organisateur := models.Organisateur{
-			UserID: newUser.ID,
-		}
-		if err := tx.Create(&organisateur).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create organisateur"})
-
-		}
-
-	case "TENEUR_STAND":
-		teneur := models.TeneurStand{
-			UserID: newUser.ID,
-		}
-		if err := tx.Create(&teneur).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create teneur de stand"})
-
-		}
+	// Enregistrer le rôle et créer le profil correspondant
+	if err := services.GrantRole(tx, newUser.ID, role); err != nil {
+		tx.Rollback()
+		response.Fail(c, response.Internal("Failed to create user profile"))
+		return
 	}
 
 	// Commit la transaction
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to complete registration"})
+		response.Fail(c, response.Internal("Failed to complete registration"))
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully", "userId": newUser.ID})
+	if err := sendVerificationEmail(c, newUser); err != nil {
+		log.Println("Erreur lors de l'envoi de l'e-mail de vérification:", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": i18n.T(c, "User registered successfully"), "userId": newUser.ID})
 
 }
 
 // AddChildToParent godoc
 // @Summary Ajouter un enfant au profil du parent
-// @Description Permet à un parent d'ajouter un enfant à son profil
+// @Description Permet à un parent d'ajouter un enfant à son profil.
+// @Description Sans e-mail, un identifiant et un code PIN sont générés et renvoyés une seule fois.
 // @Tags Parents
 // @Accept json
 // @Produce json
 // @Param request body requests.AddChildRequest true "Informations de l'enfant"
-// @Success 201 {object} response.SuccessResponse
+// @Success 201 {object} response.SuccessAddChildResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 401 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
@@ -202,23 +263,52 @@ func Register(c *gin.Context) {
 func AddChildToParent(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "User not authenticated"})
+		response.Fail(c, response.ErrUnauthenticated)
 		return
 	}
 
 	var req requests.AddChildRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
-	// Hachage du mot de passe
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to process password"})
+	if req.Email != "" && req.Password == "" {
+		response.Fail(c, response.InvalidField("password", "required", "Password is required when an email is given"))
 		return
 	}
 
+	// Un parent ne peut créer que des comptes élèves
+	role := models.RoleEleve
+	if req.Role != "" {
+		if r, err := stringToRole(req.Role); err != nil || r != models.RoleEleve {
+			response.Fail(c, response.ErrInvalidRole.WithMessage("Child accounts must have the ELEVE role"))
+			return
+		}
+	}
+
+	// Sans e-mail, l'enfant se connecte avec un identifiant et un code PIN ; son mot de passe reste inconnu
+	var passwordHash, pin, pinHash string
+	var err error
+	if req.Email == "" {
+		if passwordHash, err = services.UnusablePassword(); err != nil {
+			response.Fail(c, response.Internal("Failed to process password"))
+			return
+		}
+		if pin, pinHash, err = services.GenerateChildPIN(); err != nil {
+			response.Fail(c, response.Internal("Failed to generate PIN"))
+			return
+		}
+	} else {
+		// Hachage du mot de passe
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			response.Fail(c, response.Internal("Failed to process password"))
+			return
+		}
+		passwordHash = string(hash)
+	}
+
 	tx := initializers.DB.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -231,32 +321,40 @@ func AddChildToParent(c *gin.Context) {
 	if err := tx.Where("user_id = ?", userID).First(&parent).Error; err != nil {
 		tx.Rollback()
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Parent not found for this user"})
+			response.Fail(c, response.ErrParentNotFound)
 		} else {
-			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to find parent"})
+			response.Fail(c, response.Internal("Failed to find parent"))
 		}
 		return
 	}
 
-	// Conversion du rôle
-	role, err := stringToRole(req.Role)
-	if err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
-		return
-	}
-
 	// Créer un nouvel utilisateur pour l'enfant
 	childUser := models.User{
 		Name:     req.Name,
 		Email:    req.Email,
-		Password: string(passwordHash),
+		Password: passwordHash,
+		PinHash:  pinHash,
 		Roles:    role,
 	}
 
+	if req.Email == "" {
+		username, err := services.GenerateChildUsername(tx, req.Name)
+		if err != nil {
+			tx.Rollback()
+			response.Fail(c, response.Internal("Failed to generate username"))
+			return
+		}
+		childUser.Username = &username
+		if childUser.Email, err = services.PlaceholderEmail(); err != nil {
+			tx.Rollback()
+			response.Fail(c, response.Internal("Failed to create child user"))
+			return
+		}
+	}
+
 	if err := tx.Create(&childUser).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create child user"})
+		response.Fail(c, response.Internal("Failed to create child user"))
 		return
 	}
 
@@ -268,33 +366,60 @@ func AddChildToParent(c *gin.Context) {
 
 	if err := tx.Create(&eleve).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create eleve record"})
+		response.Fail(c, response.Internal("Failed to create eleve record"))
 		return
 	}
 
 	// Mise à jour de la relation Parent-Eleve
 	if err := tx.Model(&parent).Association("Enfants").Append(&eleve); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to link child to parent"})
+		response.Fail(c, response.Internal("Failed to link child to parent"))
+		return
+	}
+
+	if err := services.SetGuardian(tx, eleve.ID, parent.ID, models.GuardianFull); err != nil {
+		tx.Rollback()
+		response.Fail(c, response.Internal("Failed to link child to parent"))
+		return
+	}
+
+	if err := services.GrantRole(tx, childUser.ID, role); err != nil {
+		tx.Rollback()
+		response.Fail(c, response.Internal("Failed to create child user"))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to complete child addition"})
+		response.Fail(c, response.Internal("Failed to complete child addition"))
+		return
+	}
+
+	if childUser.Username != nil {
+		c.JSON(http.StatusCreated, response.SuccessAddChildResponse{
+			Message:  i18n.T(c, "Child added successfully"),
+			Data:     true,
+			Username: *childUser.Username,
+			PIN:      pin,
+		})
 		return
 	}
 
+	if err := sendVerificationEmail(c, childUser); err != nil {
+		log.Println("Erreur lors de l'envoi de l'e-mail de vérification:", err)
+	}
+
 	c.JSON(http.StatusCreated, response.SuccessAddChildResponse{
-		Message: "Child added successfully",
+		Message: i18n.T(c, "Child added successfully"),
 		Data:    true,
 	})
 }
 
 // @Summary Logout
-// @Description Inform the client to delete the token
+// @Description Revoke the current access token and the given refresh token (or every device with all_devices)
 // @Tags Auth
 // @Accept json
 // @Produce json
+// @Param request body requests.LogoutRequest false "Refresh token to revoke"
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer <Add access token here>)
 // @Success 200 {object} response.SuccessResponse
@@ -303,16 +428,55 @@ func AddChildToParent(c *gin.Context) {
 // @Failure 500 {object} response.ErrorResponse
 // @Router /api/logout [post]
 func Logout(c *gin.Context) {
-	// En l'absence de gestion des tokens côté serveur, rien n'est requis ici
+	userID, _ := c.Get("userID")
+
+	// Le corps est optionnel : sans refresh token seul le jeton d'accès est révoqué
+	var req requests.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, response.BindingError(err))
+			return
+		}
+	}
+
+	if err := revokeAccessToken(c); err != nil {
+		response.Fail(c, response.Internal("Failed to revoke token"))
+		return
+	}
+
+	query := initializers.DB.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if !req.AllDevices {
+		if req.RefreshToken == "" {
+			c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+			return
+		}
+		query = query.Where("token_hash = ?", common.HashToken(req.RefreshToken))
+	}
+
+	if err := query.Update("revoked_at", time.Now()).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to revoke token"))
+		return
+	}
+
 	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
 }
 
-func generateJWT(user models.User) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":   user.ID,
-		"role": user.Roles.String(),
-		"exp":  time.Now().Add(time.Hour * 24).Unix(),
-	})
-
-	return token.SignedString([]byte(os.Getenv("SECRET_KEY")))
+// generateJWT signe un jeton d'accès portant le rôle actif, tous les rôles détenus et la kermesse de rattachement
+func generateJWT(db *gorm.DB, user models.User, scope string) (string, error) {
+	roles, err := services.UserRoles(db, user)
+	if err != nil {
+		return "", err
+	}
+	kermesseID, err := services.UserKermesse(db, user.ID)
+	if err != nil {
+		return "", err
+	}
+	signed, _, err := token.Issue(token.Grant{
+		UserID:     user.ID,
+		Role:       user.Roles.String(),
+		Roles:      services.RoleNames(roles),
+		Scope:      scope,
+		KermesseID: kermesseID,
+	}, accessTokenTTL)
+	return signed, err
 }
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"errors"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/internal/token"
+	"example/hello/requests"
+	"example/hello/response"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const childLoginCodeTTL = 5 * time.Minute
+
+// LoginWithPIN godoc
+// @Summary Log in a child account with a username and PIN
+// @Description Login for child accounts created without email. The tokens are limited to spending and browsing: no messaging and no profile changes.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.PINLoginRequest true "Username and PIN"
+// @Success 200 {object} response.TokenResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 423 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/login/pin [post]
+func LoginWithPIN(c *gin.Context) {
+	var req requests.PINLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	ip := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+	username := strings.ToLower(strings.TrimSpace(req.Username))
+
+	// Un code à quatre chiffres se devine vite : même ralentissement et même verrouillage que le mot de passe
+	if wait := services.LoginRetryAfter(ip, username); wait > 0 {
+		services.RecordAuthEvent(nil, username, ip, userAgent, models.AuthEventLoginThrottled, fmt.Sprintf("retry after %s", wait.Round(time.Second)))
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		response.Fail(c, response.ErrTooManyAttempts)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.Where("username = ?", username).First(&user).Error; err != nil || user.PinHash == "" {
+		services.RecordLoginFailure(ip, username)
+		services.RecordAuthEvent(nil, username, ip, userAgent, models.AuthEventLoginFailed, "unknown username")
+		response.Fail(c, response.ErrInvalidCredentials)
+		return
+	}
+
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		services.RecordAuthEvent(&user.ID, user.Email, ip, userAgent, models.AuthEventLoginLocked, "")
+		response.Fail(c, response.ErrAccountLocked)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PinHash), []byte(req.PIN)); err != nil {
+		services.RecordLoginFailure(ip, username)
+		registerFailedLogin(&user, ip, userAgent)
+		response.Fail(c, response.ErrInvalidCredentials)
+		return
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		initializers.DB.Model(&user).Updates(map[string]interface{}{"failed_logins": 0, "locked_until": nil})
+	}
+	services.ResetLoginFailures(username)
+
+	tokens, _, err := issueTokens(c, initializers.DB, user, req.DeviceName, "", token.ScopeSpend)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to generate token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// LoginWithQRCode godoc
+// @Summary Log in a child account with a QR code issued by a parent
+// @Description Exchange a single-use login code shown as a QR code by the parent for spend-only tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.QRLoginRequest true "Login code"
+// @Success 200 {object} response.TokenResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/login/qr [post]
+func LoginWithQRCode(c *gin.Context) {
+	var req requests.QRLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var tokens response.TokenResponse
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		userID, err := services.ConsumeUserToken(tx, models.UserTokenChildLogin, req.Code)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		tokens, _, err = issueTokens(c, tx, user, req.DeviceName, "", token.ScopeSpend)
+		return err
+	})
+	if errors.Is(err, services.ErrInvalidUserToken) || errors.Is(err, gorm.ErrRecordNotFound) {
+		services.RecordAuthEvent(nil, "", c.ClientIP(), c.Request.UserAgent(), models.AuthEventLoginFailed, "invalid child login code")
+		response.Fail(c, response.ErrLoginCodeInvalid)
+		return
+	}
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to generate token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// ResetChildPIN godoc
+// @Summary Reset the PIN of a child account
+// @Description Generate a new PIN for one of the parent's children. The PIN is returned only once; an identifier is created if the child had none
+// @Tags Parents
+// @Produce json
+// @Param id path int true "Eleve ID"
+// @Success 200 {object} response.ChildPINResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/parents/me/children/{id}/pin [post]
+func ResetChildPIN(c *gin.Context) {
+	eleve, ok := loadOwnChild(c)
+	if !ok {
+		return
+	}
+
+	pin, pinHash, err := services.GenerateChildPIN()
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to generate PIN"))
+		return
+	}
+
+	user := eleve.User
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"pin_hash": pinHash, "failed_logins": 0, "locked_until": nil}
+		if user.Username == nil {
+			username, err := services.GenerateChildUsername(tx, user.Name)
+			if err != nil {
+				return err
+			}
+			updates["username"] = username
+			user.Username = &username
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		// Les appareils déjà connectés avec l'ancien code doivent se reconnecter
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND scope = ? AND revoked_at IS NULL", user.ID, token.ScopeSpend).
+			Update("revoked_at", time.Now()).Error
+	})
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to reset PIN"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ChildPINResponse{Username: *user.Username, PIN: pin})
+}
+
+// IssueChildLoginCode godoc
+// @Summary Issue a QR login code for a child
+// @Description Generate a single-use code, valid five minutes, that the child's device scans to log in with spend-only tokens
+// @Tags Parents
+// @Produce json
+// @Param id path int true "Eleve ID"
+// @Success 200 {object} response.ChildLoginCodeResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/parents/me/children/{id}/login-code [post]
+func IssueChildLoginCode(c *gin.Context) {
+	eleve, ok := loadOwnChild(c)
+	if !ok {
+		return
+	}
+
+	code, err := services.IssueUserToken(initializers.DB, eleve.UserID, models.UserTokenChildLogin, childLoginCodeTTL)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to generate login code"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ChildLoginCodeResponse{
+		Code:      code,
+		ExpiresIn: int64(childLoginCodeTTL.Seconds()),
+	})
+}
+
+// loadOwnChild charge l'élève désigné dans l'URL si le parent connecté en est tuteur avec tous les droits
+func loadOwnChild(c *gin.Context) (models.Eleve, bool) {
+	eleveID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return models.Eleve{}, false
+	}
+
+	userID, _ := c.Get("userID")
+	eleve, err := services.ChildOfParent(initializers.DB, userID, eleveID)
+	if errors.Is(err, services.ErrNotGuardian) || errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, response.ErrChildNotFound)
+		return models.Eleve{}, false
+	}
+	if errors.Is(err, services.ErrGuardianPermission) {
+		response.Fail(c, response.ErrGuardianPermission)
+		return models.Eleve{}, false
+	}
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve child"))
+		return models.Eleve{}, false
+	}
+	return eleve, true
+}
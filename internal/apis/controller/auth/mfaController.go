@@ -0,0 +1,491 @@
+package auth
+
+import (
+	"errors"
+	"example/hello/common"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/requests"
+	"example/hello/response"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaTokenTTL      = 5 * time.Minute
+	defaultMFAIssuer = "Kermesse"
+)
+
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return defaultMFAIssuer
+}
+
+// loadMFAUser retrouve l'utilisateur d'un jeton intermédiaire de connexion sans le consommer
+func loadMFAUser(c *gin.Context, mfaToken string) (*models.User, bool) {
+	userID, err := common.ParseSignedToken(string(models.UserTokenMFALogin), mfaToken)
+	if err != nil {
+		response.Fail(c, response.ErrMFATokenInvalid)
+		return nil, false
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrMFATokenInvalid)
+		return nil, false
+	}
+
+	if wait := services.LoginRetryAfter(c.ClientIP(), user.Email); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		response.Fail(c, response.ErrTooManyAttempts)
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// rejectSecondFactor répond à une erreur de l'étape MFA et compte les codes invalides comme des échecs de connexion
+func rejectSecondFactor(c *gin.Context, user *models.User, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode):
+		services.RecordLoginFailure(c.ClientIP(), user.Email)
+		services.RecordAuthEvent(&user.ID, user.Email, c.ClientIP(), c.Request.UserAgent(), models.AuthEventMFAFailed, "")
+		response.Fail(c, response.ErrMFACodeInvalid.WithStatus(http.StatusUnauthorized))
+	case errors.Is(err, services.ErrInvalidUserToken):
+		response.Fail(c, response.ErrMFATokenInvalid)
+	default:
+		response.Fail(c, response.Internal("Failed to complete login"))
+	}
+}
+
+// beginEnrollment génère un nouveau secret en attente de confirmation
+func beginEnrollment(user *models.User) (response.MFAEnrollmentResponse, error) {
+	secret, err := common.GenerateTOTPSecret()
+	if err != nil {
+		return response.MFAEnrollmentResponse{}, err
+	}
+	if err := initializers.DB.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		return response.MFAEnrollmentResponse{}, err
+	}
+
+	return response.MFAEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: common.TOTPProvisioningURI(mfaIssuer(), user.Email, secret),
+	}, nil
+}
+
+// completeEnrollment active la double authentification après un premier code valide
+func completeEnrollment(tx *gorm.DB, user *models.User, code string) ([]string, error) {
+	if err := services.VerifySecondFactor(tx, user, code, ""); err != nil {
+		return nil, err
+	}
+	if err := tx.Model(user).Update("totp_enabled_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return services.GenerateRecoveryCodes(tx, user.ID)
+}
+
+// VerifyLoginMFA godoc
+// @Summary Complete a login with a second factor
+// @Description Exchange the mfa_token returned by /api/login and a TOTP or recovery code for access tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.MFALoginRequest true "MFA token and code"
+// @Success 200 {object} response.TokenResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/login/mfa [post]
+func VerifyLoginMFA(c *gin.Context) {
+	var req requests.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	user, ok := loadMFAUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		response.Fail(c, response.ErrMFAEnrollmentRequired)
+		return
+	}
+
+	var tokens response.TokenResponse
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.VerifySecondFactor(tx, user, req.Code, req.RecoveryCode); err != nil {
+			return err
+		}
+		if _, err := services.ConsumeUserToken(tx, models.UserTokenMFALogin, req.MFAToken); err != nil {
+			return err
+		}
+
+		var err error
+		tokens, _, err = issueTokens(c, tx, *user, req.DeviceName, "", "")
+		return err
+	})
+	if err != nil {
+		rejectSecondFactor(c, user, err)
+		return
+	}
+
+	services.ResetLoginFailures(user.Email)
+	c.JSON(http.StatusOK, tokens)
+}
+
+// StartLoginMFAEnrollment godoc
+// @Summary Start a mandatory two-factor enrollment during login
+// @Description Generate a TOTP secret for an account that must enable two-factor authentication before logging in
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.MFATokenRequest true "MFA token"
+// @Success 200 {object} response.MFAEnrollmentResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/login/mfa/enroll [post]
+func StartLoginMFAEnrollment(c *gin.Context) {
+	var req requests.MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	user, ok := loadMFAUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		response.Fail(c, response.ErrMFAAlreadyEnabled)
+		return
+	}
+
+	enrollment, err := beginEnrollment(user)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to start enrollment"))
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmLoginMFAEnrollment godoc
+// @Summary Confirm a mandatory two-factor enrollment during login
+// @Description Enable two-factor authentication with a first TOTP code, then return the recovery codes and access tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.MFALoginRequest true "MFA token and TOTP code"
+// @Success 200 {object} response.MFAEnrollmentCompleteResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/login/mfa/enroll/confirm [post]
+func ConfirmLoginMFAEnrollment(c *gin.Context) {
+	var req requests.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	user, ok := loadMFAUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		response.Fail(c, response.ErrMFAAlreadyEnabled)
+		return
+	}
+
+	var result response.MFAEnrollmentCompleteResponse
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		codes, err := completeEnrollment(tx, user, req.Code)
+		if err != nil {
+			return err
+		}
+		if _, err := services.ConsumeUserToken(tx, models.UserTokenMFALogin, req.MFAToken); err != nil {
+			return err
+		}
+
+		tokens, _, err := issueTokens(c, tx, *user, req.DeviceName, "", "")
+		if err != nil {
+			return err
+		}
+		result = response.MFAEnrollmentCompleteResponse{TokenResponse: tokens, RecoveryCodes: codes}
+		return nil
+	})
+	if err != nil {
+		rejectSecondFactor(c, user, err)
+		return
+	}
+
+	services.ResetLoginFailures(user.Email)
+	c.JSON(http.StatusOK, result)
+}
+
+// StartMFAEnrollment godoc
+// @Summary Start two-factor enrollment
+// @Description Generate a TOTP secret and its provisioning URI for the current user
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} response.MFAEnrollmentResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/mfa [post]
+func StartMFAEnrollment(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		response.Fail(c, response.ErrMFAAlreadyEnabled)
+		return
+	}
+
+	enrollment, err := beginEnrollment(&user)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to start enrollment"))
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFAEnrollment godoc
+// @Summary Confirm two-factor enrollment
+// @Description Enable two-factor authentication with a first TOTP code and return the recovery codes
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.MFACodeRequest true "TOTP code"
+// @Success 200 {object} response.RecoveryCodesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/mfa/confirm [post]
+func ConfirmMFAEnrollment(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req requests.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		response.Fail(c, response.ErrMFAAlreadyEnabled)
+		return
+	}
+
+	var codes []string
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = completeEnrollment(tx, &user, req.Code)
+		return err
+	})
+	if errors.Is(err, services.ErrInvalidMFACode) {
+		response.Fail(c, response.ErrMFACodeInvalid)
+		return
+	}
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to enable two-factor authentication"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes of the current user after checking a TOTP code
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.MFACodeRequest true "TOTP code"
+// @Success 200 {object} response.RecoveryCodesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/mfa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req requests.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		response.Fail(c, response.ErrMFANotEnabled)
+		return
+	}
+
+	var codes []string
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.VerifySecondFactor(tx, &user, req.Code, ""); err != nil {
+			return err
+		}
+		var err error
+		codes, err = services.GenerateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if errors.Is(err, services.ErrInvalidMFACode) {
+		response.Fail(c, response.ErrMFACodeInvalid)
+		return
+	}
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to regenerate recovery codes"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// clearMFA supprime le secret TOTP et les codes de secours d'un utilisateur
+func clearMFA(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"totp_secret":     "",
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// DisableMFA godoc
+// @Summary Disable two-factor authentication
+// @Description Disable two-factor authentication for the current user. Not allowed for ADMIN and ORGANISATEUR accounts
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.MFACodeRequest true "TOTP code"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/mfa/disable [post]
+func DisableMFA(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req requests.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+	roles, err := services.UserRoles(initializers.DB, user)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to disable two-factor authentication"))
+		return
+	}
+	if services.MFARequired(roles...) {
+		response.Fail(c, response.ErrMFAEnrollmentRequired)
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		response.Fail(c, response.ErrMFANotEnabled)
+		return
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.VerifySecondFactor(tx, &user, req.Code, ""); err != nil {
+			return err
+		}
+		return clearMFA(tx, user.ID)
+	})
+	if errors.Is(err, services.ErrInvalidMFACode) {
+		response.Fail(c, response.ErrMFACodeInvalid)
+		return
+	}
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to disable two-factor authentication"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
+
+// ResetUserMFA godoc
+// @Summary Reset a user's two-factor authentication
+// @Description Remove the TOTP secret and recovery codes of a user who lost their device. ADMIN and ORGANISATEUR accounts must enroll again at next login
+// @Tags Auth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/{id}/mfa [delete]
+func ResetUserMFA(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+
+	if err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		return clearMFA(tx, user.ID)
+	}); err != nil {
+		response.Fail(c, response.Internal("Failed to reset two-factor authentication"))
+		return
+	}
+
+	adminID, _ := c.Get("userID")
+	services.RecordAuthEvent(&user.ID, user.Email, c.ClientIP(), c.Request.UserAgent(), models.AuthEventMFAReset, fmt.Sprintf("reset by admin %v", adminID))
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
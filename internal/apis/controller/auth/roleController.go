@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"errors"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/requests"
+	"example/hello/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SwitchActiveRole godoc
+// @Summary Switch the active role
+// @Description Make one of the roles held by the current user the active one and return a new access token
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.RoleRequest true "Role to activate"
+// @Success 200 {object} response.ActiveRoleResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/active-role [post]
+func SwitchActiveRole(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req requests.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	role, err := stringToRole(req.Role)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRole)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+
+	roles, err := services.UserRoles(initializers.DB, user)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve user roles"))
+		return
+	}
+	if !services.HasRole(roles, role) {
+		response.Fail(c, response.ErrRoleNotHeld)
+		return
+	}
+
+	if user.Roles != role {
+		if err := initializers.DB.Model(&user).Update("roles", role).Error; err != nil {
+			response.Fail(c, response.Internal("Failed to switch role"))
+			return
+		}
+		user.Roles = role
+	}
+
+	// Le refresh token reste valable : les jetons suivants reprendront le rôle actif enregistré
+	accessToken, err := generateJWT(initializers.DB, user, c.GetString("tokenScope"))
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to generate token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ActiveRoleResponse{
+		ActiveRole: user.Roles.String(),
+		Roles:      services.RoleNames(roles),
+		Token:      accessToken,
+		ExpiresIn:  int64(accessTokenTTL.Seconds()),
+	})
+}
+
+// GrantUserRole godoc
+// @Summary Grant a role to a user
+// @Description Add a role to a user and create the matching profile (parent, eleve, teneur de stand, organisateur)
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body requests.RoleRequest true "Role to grant"
+// @Success 200 {object} response.UserRolesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/{id}/roles [post]
+func GrantUserRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var req requests.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	role, err := stringToRole(req.Role)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRole)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Le rôle actif des comptes antérieurs aux rôles multiples est enregistré au passage
+		if err := services.GrantRole(tx, user.ID, user.Roles); err != nil {
+			return err
+		}
+		return services.GrantRole(tx, user.ID, role)
+	})
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to grant role"))
+		return
+	}
+
+	respondUserRoles(c, user)
+}
+
+// RevokeUserRole godoc
+// @Summary Revoke a role from a user
+// @Description Remove a role from a user. The profile is kept; if the role was active another held role becomes active
+// @Tags Users
+// @Produce json
+// @Param id path int true "User ID"
+// @Param role path string true "Role to revoke"
+// @Success 200 {object} response.UserRolesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/{id}/roles/{role} [delete]
+func RevokeUserRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	role, err := stringToRole(c.Param("role"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRole)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		return services.RevokeRole(tx, &user, role)
+	})
+	switch {
+	case errors.Is(err, services.ErrRoleNotHeld):
+		response.Fail(c, response.ErrRoleNotHeld.WithStatus(http.StatusNotFound))
+		return
+	case errors.Is(err, services.ErrLastUserRole):
+		response.Fail(c, response.ErrLastRole)
+		return
+	case err != nil:
+		response.Fail(c, response.Internal("Failed to revoke role"))
+		return
+	}
+
+	respondUserRoles(c, user)
+}
+
+func respondUserRoles(c *gin.Context, user models.User) {
+	roles, err := services.UserRoles(initializers.DB, user)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve user roles"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.UserRolesResponse{
+		UserID:     user.ID,
+		ActiveRole: user.Roles.String(),
+		Roles:      services.RoleNames(roles),
+	})
+}
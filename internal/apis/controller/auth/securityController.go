@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"example/hello/internal/apis/pagination"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/response"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockUser godoc
+// @Summary Unlock a user account
+// @Description Reset the failed login counter and lift the temporary lock of an account
+// @Tags Auth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/{id}/unlock [post]
+func UnlockUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+
+	if err := initializers.DB.Model(&user).Updates(map[string]interface{}{"failed_logins": 0, "locked_until": nil}).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to unlock user"))
+		return
+	}
+	services.ResetLoginFailures(user.Email)
+
+	adminID, _ := c.Get("userID")
+	services.RecordAuthEvent(&user.ID, user.Email, c.ClientIP(), c.Request.UserAgent(), models.AuthEventAccountUnlocked, fmt.Sprintf("unlocked by admin %v", adminID))
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
+
+var authEventList = pagination.Spec{
+	Model: &models.AuthEvent{},
+	Filters: []pagination.Filter{
+		pagination.Equal("user_id", "user_id", pagination.ID),
+		pagination.Equal("email", "email", pagination.Text),
+		pagination.Equal("ip", "ip_address", pagination.Text),
+		pagination.Equal("event", "event", pagination.Text),
+		pagination.From("from", "created_at"),
+		pagination.To("to", "created_at"),
+	},
+	Sorts:        map[string]string{"id": "id", "created_at": "created_at"},
+	DefaultSort:  "-created_at",
+	DefaultLimit: 100,
+	MaxLimit:     500,
+}
+
+// GetAuthEvents godoc
+// @Summary List suspicious login activity
+// @Description List failed, throttled and locked login attempts, newest first
+// @Tags Auth
+// @Produce json
+// @Param user_id query int false "Filter by user ID"
+// @Param email query string false "Filter by email"
+// @Param ip query string false "Filter by IP address"
+// @Param event query string false "Filter by event type"
+// @Param from query string false "Only events at or after this date (RFC 3339)"
+// @Param to query string false "Only events before this date (RFC 3339)"
+// @Param sort query string false "Sort key: id or created_at, prefixed with - for descending order" default(-created_at)
+// @Param limit query int false "Page size (default 100, max 500)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.AuthEvent}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/auth-events [get]
+func GetAuthEvents(c *gin.Context) {
+	params, err := pagination.Parse(c, authEventList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var events []models.AuthEvent
+	page, err := pagination.Find(initializers.DB, params, &events)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve auth events"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.PageResponse{Data: events, Page: page})
+}
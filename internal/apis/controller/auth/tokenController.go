@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"example/hello/common"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/requests"
+	"example/hello/response"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// randomToken génère une chaîne aléatoire utilisable dans une URL
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// issueTokens crée un jeton d'accès et un refresh token rattaché à l'appareil appelant.
+// Un familyID vide démarre une nouvelle famille de rotation ; la portée est conservée à chaque rotation.
+func issueTokens(c *gin.Context, tx *gorm.DB, user models.User, deviceName, familyID, scope string) (response.TokenResponse, *models.RefreshToken, error) {
+	accessToken, err := generateJWT(tx, user, scope)
+	if err != nil {
+		return response.TokenResponse{}, nil, err
+	}
+
+	rawRefresh, err := randomToken(32)
+	if err != nil {
+		return response.TokenResponse{}, nil, err
+	}
+
+	if familyID == "" {
+		if familyID, err = randomToken(16); err != nil {
+			return response.TokenResponse{}, nil, err
+		}
+	}
+
+	refresh := models.RefreshToken{
+		UserID:     user.ID,
+		TokenHash:  common.HashToken(rawRefresh),
+		FamilyID:   familyID,
+		Scope:      scope,
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		ExpiresAt:  time.Now().Add(refreshTokenTTL),
+	}
+	if err := tx.Create(&refresh).Error; err != nil {
+		return response.TokenResponse{}, nil, err
+	}
+
+	return response.TokenResponse{
+		Token:        accessToken,
+		RefreshToken: rawRefresh,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, &refresh, nil
+}
+
+// RefreshToken godoc
+// @Summary Refresh the access token
+// @Description Exchange a refresh token for a new access token and a rotated refresh token
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body requests.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} response.TokenResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/token/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var req requests.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var stored models.RefreshToken
+	if err := initializers.DB.Where("token_hash = ?", common.HashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+		response.Fail(c, response.ErrRefreshTokenInvalid)
+		return
+	}
+
+	now := time.Now()
+
+	// Un refresh token déjà tourné qui revient est probablement volé : toute la famille est révoquée
+	if stored.RevokedAt != nil {
+		revokeTokenFamily(stored.FamilyID)
+		response.Fail(c, response.ErrRefreshTokenRevoked)
+		return
+	}
+
+	if now.After(stored.ExpiresAt) {
+		response.Fail(c, response.ErrRefreshTokenExpired)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, stored.UserID).Error; err != nil {
+		response.Fail(c, response.ErrRefreshTokenInvalid)
+		return
+	}
+
+	deviceName := req.DeviceName
+	if deviceName == "" {
+		deviceName = stored.DeviceName
+	}
+
+	tx := initializers.DB.Begin()
+
+	// La révocation conditionnelle empêche deux rafraîchissements concurrents du même jeton
+	result := tx.Model(&models.RefreshToken{}).Where("id = ? AND revoked_at IS NULL", stored.ID).
+		Updates(map[string]interface{}{"revoked_at": now, "last_used_at": now})
+	if result.Error != nil {
+		tx.Rollback()
+		response.Fail(c, response.Internal("Failed to refresh token"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		response.Fail(c, response.ErrRefreshTokenRevoked)
+		return
+	}
+
+	tokens, refresh, err := issueTokens(c, tx, user, deviceName, stored.FamilyID, stored.Scope)
+	if err != nil {
+		tx.Rollback()
+		response.Fail(c, response.Internal("Failed to generate token"))
+		return
+	}
+
+	if err := tx.Model(&stored).Update("replaced_by_id", refresh.ID).Error; err != nil {
+		tx.Rollback()
+		response.Fail(c, response.Internal("Failed to refresh token"))
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		response.Fail(c, response.Internal("Failed to refresh token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func revokeTokenFamily(familyID string) {
+	initializers.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now())
+}
+
+// revokeAccessToken ajoute le jti du jeton courant à la liste de révocation
+func revokeAccessToken(c *gin.Context) error {
+	tokenID, ok := c.Get("tokenID")
+	if !ok {
+		return nil
+	}
+	jti, _ := tokenID.(string)
+	if jti == "" {
+		return nil
+	}
+
+	expiresAt := time.Now().Add(accessTokenTTL)
+	if exp, ok := c.Get("tokenExpiresAt"); ok {
+		if t, ok := exp.(time.Time); ok {
+			expiresAt = t
+		}
+	}
+
+	// Les entrées expirées ne servent plus à rien : on en profite pour les purger
+	initializers.DB.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+
+	return initializers.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+// GetSessions godoc
+// @Summary List active sessions
+// @Description List the devices holding an active refresh token for the current user
+// @Tags Auth
+// @Produce json
+// @Success 200 {array} response.SessionResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/sessions [get]
+func GetSessions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var tokens []models.RefreshToken
+	if err := initializers.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").Find(&tokens).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve sessions"))
+		return
+	}
+
+	sessions := make([]response.SessionResponse, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, response.SessionResponse{
+			ID:         token.ID,
+			DeviceName: token.DeviceName,
+			UserAgent:  token.UserAgent,
+			IPAddress:  token.IPAddress,
+			CreatedAt:  token.CreatedAt,
+			LastUsedAt: token.LastUsedAt,
+			ExpiresAt:  token.ExpiresAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary Revoke a session
+// @Description Revoke the refresh token of one of the current user's devices
+// @Tags Auth
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/sessions/{id} [delete]
+func RevokeSession(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	result := initializers.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		response.Fail(c, response.Internal("Failed to revoke session"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Fail(c, response.ErrSessionNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
@@ -1,31 +1,58 @@
 package gagnant
 
 import (
+	"errors"
+	"example/hello/internal/apis/controller/messages"
+	"example/hello/internal/apis/services"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
+	"example/hello/requests"
 	"example/hello/response"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetGagnants godoc
 // @Summary Get all gagnants
-// @Description Retrieve a list of all gagnantd
+// @Description Retrieve the current winners of a tombola in award order, first prize first, with the claim status of each prize (PENDING until the winner is notified at the end of the live draw, then NOTIFIED, CLAIMED, HANDED_OVER or UNCLAIMED); results of voided draws are excluded. Returns 409 while the draw is still being revealed live
 // @Tags Gagnant
 // @Produce json
 // @Param id path int true "Tombola ID"
 // @Success 200 {array} models.Gagnant
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas/{id}/gagnants [get]
 func GetWinners(c *gin.Context) {
-	tombolaID, _ := strconv.Atoi(c.Param("tombola_id"))
+	tombolaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+	var tombola models.Tombola
+	if err := initializers.DB.First(&tombola, tombolaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrTombolaNotFound)
+		} else {
+			response.Fail(c, response.Internal("Failed to retrieve gagnants"))
+		}
+		return
+	}
+	if tombola.Revealing(time.Now()) {
+		response.Fail(c, response.ErrDrawRevealing)
+		return
+	}
 	var winners []models.Gagnant
-	if err := initializers.DB.Where("tombola_id = ?", tombolaID).Preload("User").Preload("Lot").Find(&winners).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve gagnants"})
+	if err := initializers.DB.Where("tombola_id = ? AND voided_draw_id IS NULL", tombolaID).Preload("User").Preload("Lot").Order("ordre, id").Find(&winners).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve gagnants"))
 		return
 	}
 	c.JSON(http.StatusOK, winners)
@@ -40,15 +67,234 @@ func GetWinners(c *gin.Context) {
 // @Param id path int true "Gagnant ID"
 // @Success 200 {object} models.Gagnant
 // @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas/{id}/gagnants/{id} [get]
 func GetWinner(c *gin.Context) {
 	id := c.Param("id")
 	var winner models.Gagnant
-	if err := initializers.DB.Preload("User").Preload("Lot").First(&winner, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Gagnant not found"})
+	if err := initializers.DB.Preload("User").Preload("Lot").Preload("Tombola").First(&winner, id).Error; err != nil {
+		response.Fail(c, response.ErrWinnerNotFound)
+		return
+	}
+	if winner.Tombola.Revealing(time.Now()) {
+		response.Fail(c, response.ErrDrawRevealing)
 		return
 	}
 	c.JSON(http.StatusOK, winner)
 }
+
+func toPrizeResponse(gagnant models.Gagnant) response.PrizeResponse {
+	return response.PrizeResponse{
+		ID:           gagnant.ID,
+		TombolaID:    gagnant.TombolaID,
+		UserID:       gagnant.UserID,
+		LotID:        gagnant.LotID,
+		LotNom:       gagnant.Lot.Nom,
+		TicketID:     gagnant.TicketID,
+		Numero:       gagnant.Ticket.Numero,
+		Ordre:        gagnant.Ordre,
+		Statut:       string(gagnant.Statut),
+		NotifiedAt:   gagnant.NotifiedAt,
+		ClaimedAt:    gagnant.ClaimedAt,
+		HandedOverAt: gagnant.HandedOverAt,
+	}
+}
+
+// isAdmin indique si l'utilisateur détient le rôle administrateur, qu'il soit actif ou non
+func isAdmin(c *gin.Context) bool {
+	roles, _ := c.Get("userRoles")
+	names, _ := roles.([]string)
+	for _, name := range names {
+		if name == models.RoleAdmin.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// failPrize traduit les erreurs du retrait des lots
+func failPrize(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Fail(c, response.ErrWinnerNotFound)
+	case errors.Is(err, services.ErrPrizeNotClaimable):
+		response.Fail(c, response.ErrPrizeNotClaimable)
+	case errors.Is(err, services.ErrPrizeAlreadyHandedOver):
+		response.Fail(c, response.ErrPrizeAlreadyHandedOver)
+	case errors.Is(err, services.ErrClaimDeadlinePassed):
+		response.Fail(c, response.ErrClaimDeadlinePassed)
+	case errors.Is(err, services.ErrInvalidHandoverCode):
+		response.Fail(c, response.ErrInvalidHandoverCode)
+	case errors.Is(err, services.ErrDrawRevealing):
+		response.Fail(c, response.ErrDrawRevealing)
+	default:
+		log.Println("Erreur lors du retrait d'un lot:", err)
+		response.Fail(c, response.Internal(message))
+	}
+}
+
+// ClaimPrize godoc
+// @Summary Claim a prize
+// @Description Claim a prize won in a tombola before its claim deadline. Only the winner (or an admin) can claim it. The response carries the QR code to show at the prize stand; claiming again returns a fresh QR code. Prizes cannot be claimed while the draw is still being revealed live
+// @Tags Gagnant
+// @Produce json
+// @Param id path int true "Gagnant ID"
+// @Success 200 {object} response.PrizeClaimResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/tombolas/gagnants/{id}/claim [post]
+func ClaimPrize(c *gin.Context) {
+	gagnantID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var gagnant models.Gagnant
+	if err := initializers.DB.First(&gagnant, gagnantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrWinnerNotFound)
+		} else {
+			response.Fail(c, response.Internal("Failed to claim prize"))
+		}
+		return
+	}
+	if gagnant.UserID != c.GetUint("userID") && !isAdmin(c) {
+		response.Fail(c, response.ErrForbidden)
+		return
+	}
+
+	now := time.Now()
+	gagnant, err = services.ClaimPrize(initializers.DB, gagnant.ID, now)
+	if err != nil {
+		failPrize(c, err, "Failed to claim prize")
+		return
+	}
+	code, expiresAt, err := services.PrizeHandoverCode(gagnant, gagnant.Tombola, now)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to claim prize"))
+		return
+	}
+	if err := initializers.DB.Preload("Lot").Preload("Ticket").First(&gagnant, gagnant.ID).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to claim prize"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.PrizeClaimResponse{
+		Prize:       toPrizeResponse(gagnant),
+		QRCode:      code,
+		QRExpiresAt: expiresAt,
+	})
+}
+
+// HandOverPrize godoc
+// @Summary Hand over a prize
+// @Description Confirm that a prize was handed over after scanning the winner's QR code at the prize stand. The code must belong to a current winning ticket of this tombola and the claim deadline must not have passed; a prize is handed over only once
+// @Tags Gagnant
+// @Accept json
+// @Produce json
+// @Param id path int true "Tombola ID"
+// @Param handover body requests.HandOverPrizeRequest true "Scanned QR code"
+// @Success 200 {object} response.PrizeResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/tombolas/{id}/handover [post]
+func HandOverPrize(c *gin.Context) {
+	tombolaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var req requests.HandOverPrizeRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	gagnant, err := services.HandOverPrize(initializers.DB, uint(tombolaID), req.QRCode, c.GetUint("userID"), time.Now())
+	if err != nil {
+		failPrize(c, err, "Failed to hand over prize")
+		return
+	}
+
+	c.JSON(http.StatusOK, toPrizeResponse(gagnant))
+}
+
+// RedrawUnclaimedPrizes godoc
+// @Summary Re-draw unclaimed prizes
+// @Description Once the claim deadline has passed, mark the prizes that were neither claimed nor handed over as UNCLAIMED and award each of their lots to the next eligible ticket of the published draw order, so the result stays verifiable without a new seed. The new winners are notified and have until the new claim deadline to collect their prize
+// @Tags Gagnant
+// @Accept json
+// @Produce json
+// @Param id path int true "Tombola ID"
+// @Param redraw body requests.RedrawUnclaimedRequest true "New claim deadline"
+// @Success 200 {object} response.PrizeRedrawResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/tombolas/{id}/redraw [post]
+func RedrawUnclaimedPrizes(c *gin.Context) {
+	tombolaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var req requests.RedrawUnclaimedRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	now := time.Now()
+	if !req.DateLimiteRetrait.After(now) {
+		response.Fail(c, response.InvalidField("date_limite_retrait", "future", "Must be in the future"))
+		return
+	}
+
+	redraw, err := services.RedrawUnclaimedPrizes(initializers.DB, uint(tombolaID), req.DateLimiteRetrait, now)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Fail(c, response.ErrTombolaNotFound)
+		return
+	case errors.Is(err, services.ErrTombolaNotDrawn):
+		response.Fail(c, response.ErrTombolaNotDrawn)
+		return
+	case errors.Is(err, services.ErrNoClaimDeadline):
+		response.Fail(c, response.ErrNoClaimDeadline)
+		return
+	case errors.Is(err, services.ErrClaimDeadlineNotPassed):
+		response.Fail(c, response.ErrClaimDeadlineNotPassed)
+		return
+	case err != nil:
+		log.Println("Erreur lors de la remise en jeu des lots:", err)
+		response.Fail(c, response.Internal("Failed to redraw unclaimed prizes"))
+		return
+	}
+
+	messages.NotifyWinners(c, redraw.Winners)
+
+	winners := make([]response.PrizeResponse, 0, len(redraw.Winners))
+	for _, winner := range redraw.Winners {
+		winners = append(winners, toPrizeResponse(winner))
+	}
+	c.JSON(http.StatusOK, response.PrizeRedrawResponse{
+		Unclaimed:         len(redraw.Unclaimed),
+		Winners:           winners,
+		DateLimiteRetrait: req.DateLimiteRetrait,
+	})
+}
@@ -0,0 +1,459 @@
+package invitations
+
+import (
+	"errors"
+	"example/hello/common"
+	"example/hello/i18n"
+	"example/hello/internal/apis/pagination"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/mailer"
+	"example/hello/internal/models"
+	"example/hello/requests"
+	"example/hello/response"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	invitationPurpose = "INVITATION"
+	invitationTTL     = 7 * 24 * time.Hour
+)
+
+var (
+	errInvitationUsed      = errors.New("invitation already used")
+	errMissingAccountInfos = errors.New("name and password are required to create the account")
+)
+
+// stringToInvitableRole n'accepte que les rôles attribuables par invitation
+func stringToInvitableRole(roleStr string) (models.Role, error) {
+	switch roleStr {
+	case "TENEUR_STAND":
+		return models.RoleTeneurStand, nil
+	case "ORGANISATEUR":
+		return models.RoleOrganisateur, nil
+	case "ADMIN":
+		return models.RoleAdmin, nil
+	default:
+		return models.Role(0), fmt.Errorf("rôle non attribuable par invitation: %s", roleStr)
+	}
+}
+
+func isAdmin(c *gin.Context) bool {
+	roles, _ := c.Get("userRoles")
+	names, _ := roles.([]string)
+	for _, name := range names {
+		if name == models.RoleAdmin.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func toInvitationResponse(invitation models.Invitation) response.InvitationResponse {
+	return response.InvitationResponse{
+		ID:         invitation.ID,
+		Email:      invitation.Email,
+		Role:       invitation.Role.String(),
+		KermesseID: invitation.KermesseID,
+		StandID:    invitation.StandID,
+		Status:     invitation.Status(time.Now()),
+		ExpiresAt:  invitation.ExpiresAt,
+		AcceptedAt: invitation.AcceptedAt,
+		CreatedAt:  invitation.CreatedAt,
+	}
+}
+
+// CreateInvitation godoc
+// @Summary Invite a teneur de stand, an organisateur or an admin
+// @Description Send a signed invitation link bound to an email, a role and a kermesse. Organisers can only invite to the kermesses they organise, and only admins can invite admins
+// @Tags Invitations
+// @Accept json
+// @Produce json
+// @Param request body requests.CreateInvitationRequest true "Invitation"
+// @Success 201 {object} response.InvitationResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/invitations [post]
+func CreateInvitation(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	inviterID := userID.(uint)
+
+	var req requests.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	role, err := stringToInvitableRole(req.Role)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRole)
+		return
+	}
+
+	admin := isAdmin(c)
+	var kermesse models.Kermesse
+
+	if role == models.RoleAdmin {
+		if !admin {
+			response.Fail(c, response.ErrForbidden.WithMessage("Only admins can invite admins"))
+			return
+		}
+		req.KermesseID, req.StandID = nil, nil
+	} else {
+		if req.KermesseID == nil {
+			response.Fail(c, response.ErrKermesseRequired)
+			return
+		}
+		if err := initializers.DB.First(&kermesse, *req.KermesseID).Error; err != nil {
+			response.Fail(c, response.ErrKermesseNotFound)
+			return
+		}
+		if !admin {
+			organiser, err := services.IsKermesseOrganiser(initializers.DB, inviterID, kermesse.ID)
+			if err != nil {
+				response.Fail(c, response.Internal("Failed to check permissions"))
+				return
+			}
+			if !organiser {
+				response.Fail(c, response.ErrNotKermesseOrganiser)
+				return
+			}
+		}
+	}
+
+	if req.StandID != nil {
+		if role != models.RoleTeneurStand {
+			response.Fail(c, response.ErrStandNotAllowed)
+			return
+		}
+		var stand models.Stand
+		if err := initializers.DB.First(&stand, *req.StandID).Error; err != nil {
+			response.Fail(c, response.ErrStandNotFound)
+			return
+		}
+		if stand.KermesseID != kermesse.ID {
+			response.Fail(c, response.ErrStandNotInKermesse)
+			return
+		}
+	}
+
+	var inviter models.User
+	if err := initializers.DB.First(&inviter, inviterID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+
+	now := time.Now()
+	invitation := models.Invitation{
+		Email:       strings.ToLower(strings.TrimSpace(req.Email)),
+		Role:        role,
+		KermesseID:  req.KermesseID,
+		StandID:     req.StandID,
+		InvitedByID: inviterID,
+		ExpiresAt:   now.Add(invitationTTL),
+	}
+
+	var rawToken string
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// Une nouvelle invitation remplace celles encore en attente pour la même adresse et le même rôle
+		pending := tx.Model(&models.Invitation{}).
+			Where("email = ? AND role = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.Email, role)
+		if invitation.KermesseID != nil {
+			pending = pending.Where("kermesse_id = ?", *invitation.KermesseID)
+		}
+		if err := pending.Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		// Le jeton signé porte l'identifiant de l'invitation : elle est créée avant lui
+		invitation.TokenHash = fmt.Sprintf("pending-%d-%s", inviterID, now.Format(time.RFC3339Nano))
+		if err := tx.Create(&invitation).Error; err != nil {
+			return err
+		}
+
+		var err error
+		rawToken, err = common.NewSignedToken(invitationPurpose, invitation.ID, invitation.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		invitation.TokenHash = common.HashToken(rawToken)
+		return tx.Model(&invitation).Update("token_hash", invitation.TokenHash).Error
+	})
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to create invitation"))
+		return
+	}
+
+	mailer.SendAsync(mailer.InvitationEmail(i18n.FromContext(c), invitation.Email, inviter.Name, role.String(), kermesse.Nom, rawToken))
+
+	c.JSON(http.StatusCreated, toInvitationResponse(invitation))
+}
+
+var invitationList = pagination.Spec{
+	Model: &models.Invitation{},
+	Filters: []pagination.Filter{
+		pagination.Equal("kermesse_id", "kermesse_id", pagination.ID),
+		{
+			// Même règle que Invitation.Status, évaluée par la base
+			Param: "status",
+			Parse: pagination.OneOf(map[string]interface{}{"PENDING": "PENDING", "ACCEPTED": "ACCEPTED", "REVOKED": "REVOKED", "EXPIRED": "EXPIRED"}),
+			Apply: func(query *gorm.DB, value interface{}) *gorm.DB {
+				now := time.Now()
+				switch value {
+				case "ACCEPTED":
+					return query.Where("accepted_at IS NOT NULL")
+				case "REVOKED":
+					return query.Where("accepted_at IS NULL AND revoked_at IS NOT NULL")
+				case "EXPIRED":
+					return query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at < ?", now)
+				default:
+					return query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at >= ?", now)
+				}
+			},
+		},
+	},
+	Sorts:       map[string]string{"id": "id", "created_at": "created_at", "expires_at": "expires_at"},
+	DefaultSort: "-created_at",
+}
+
+// GetInvitations godoc
+// @Summary List invitations
+// @Description Admins see every invitation, organisers the ones they sent or that target their kermesses
+// @Tags Invitations
+// @Produce json
+// @Param kermesse_id query int false "Filter by kermesse"
+// @Param status query string false "Filter by status (PENDING, ACCEPTED, REVOKED, EXPIRED)"
+// @Param sort query string false "Sort key: id, created_at or expires_at, prefixed with - for descending order" default(-created_at)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]response.InvitationResponse}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/invitations [get]
+func GetInvitations(c *gin.Context) {
+	params, err := pagination.Parse(c, invitationList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	query := initializers.DB.Model(&models.Invitation{})
+
+	if !isAdmin(c) {
+		query = query.Where("invited_by_id = ? OR kermesse_id IN (?)", userID,
+			initializers.DB.Table("organisateur_kermesses").
+				Select("organisateur_kermesses.kermesse_id").
+				Joins("JOIN organisateurs ON organisateurs.id = organisateur_kermesses.organisateur_id").
+				Where("organisateurs.user_id = ?", userID))
+	}
+
+	var invitations []models.Invitation
+	page, err := pagination.Find(query, params, &invitations)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve invitations"))
+		return
+	}
+
+	result := make([]response.InvitationResponse, 0, len(invitations))
+	for _, invitation := range invitations {
+		result = append(result, toInvitationResponse(invitation))
+	}
+
+	c.JSON(http.StatusOK, response.PageResponse{Data: result, Page: page})
+}
+
+// RevokeInvitation godoc
+// @Summary Revoke a pending invitation
+// @Description Invalidate an invitation link that has not been accepted yet
+// @Tags Invitations
+// @Produce json
+// @Param id path int true "Invitation ID"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/invitations/{id} [delete]
+func RevokeInvitation(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	invitationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var invitation models.Invitation
+	if err := initializers.DB.First(&invitation, invitationID).Error; err != nil {
+		response.Fail(c, response.ErrInvitationNotFound)
+		return
+	}
+
+	allowed := isAdmin(c) || invitation.InvitedByID == userID.(uint)
+	if !allowed && invitation.KermesseID != nil {
+		allowed, err = services.IsKermesseOrganiser(initializers.DB, userID.(uint), *invitation.KermesseID)
+		if err != nil {
+			response.Fail(c, response.Internal("Failed to check permissions"))
+			return
+		}
+	}
+	if !allowed {
+		response.Fail(c, response.ErrForbidden)
+		return
+	}
+
+	result := initializers.DB.Model(&invitation).
+		Where("accepted_at IS NULL AND revoked_at IS NULL").
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		response.Fail(c, response.Internal("Failed to revoke invitation"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Fail(c, response.ErrInvitationNotPending)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
+
+// AcceptInvitation godoc
+// @Summary Accept an invitation
+// @Description Grant the invited role to the account matching the invited email, creating it if needed, and assign the kermesse or stand
+// @Tags Invitations
+// @Accept json
+// @Produce json
+// @Param request body requests.AcceptInvitationRequest true "Invitation token and, for a new account, name and password"
+// @Success 200 {object} response.AcceptInvitationResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/invitations/accept [post]
+func AcceptInvitation(c *gin.Context) {
+	var req requests.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	invitationID, err := common.ParseSignedToken(invitationPurpose, req.Token)
+	if err != nil {
+		response.Fail(c, response.ErrInvitationInvalid)
+		return
+	}
+
+	var invitation models.Invitation
+	if err := initializers.DB.Where("id = ? AND token_hash = ?", invitationID, common.HashToken(req.Token)).First(&invitation).Error; err != nil {
+		response.Fail(c, response.ErrInvitationInvalid)
+		return
+	}
+	switch invitation.Status(time.Now()) {
+	case "ACCEPTED":
+		response.Fail(c, response.ErrInvitationAccepted)
+		return
+	case "REVOKED", "EXPIRED":
+		response.Fail(c, response.ErrInvitationInvalid)
+		return
+	}
+
+	var user models.User
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("LOWER(email) = ?", invitation.Email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if req.Name == "" || len(req.Password) < 6 {
+				return errMissingAccountInfos
+			}
+			passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			// Le lien reçu par e-mail vaut vérification de l'adresse
+			now := time.Now()
+			user = models.User{
+				Name:            req.Name,
+				Email:           invitation.Email,
+				Password:        string(passwordHash),
+				Roles:           invitation.Role,
+				EmailVerifiedAt: &now,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			// Le rôle actif des comptes antérieurs aux rôles multiples est enregistré au passage
+			if err := services.GrantRole(tx, user.ID, user.Roles); err != nil {
+				return err
+			}
+		}
+
+		if err := services.GrantRole(tx, user.ID, invitation.Role); err != nil {
+			return err
+		}
+
+		switch {
+		case invitation.Role == models.RoleOrganisateur && invitation.KermesseID != nil:
+			if err := services.AddKermesseOrganiser(tx, user.ID, *invitation.KermesseID); err != nil {
+				return err
+			}
+		case invitation.Role == models.RoleTeneurStand && invitation.StandID != nil:
+			var teneur models.TeneurStand
+			if err := tx.Where("user_id = ?", user.ID).First(&teneur).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Stand{}).Where("id = ?", *invitation.StandID).Update("teneur_id", teneur.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		// La mise à jour conditionnelle empêche d'accepter deux fois la même invitation
+		result := tx.Model(&invitation).Where("accepted_at IS NULL AND revoked_at IS NULL").
+			Updates(map[string]interface{}{"accepted_at": time.Now(), "accepted_by_id": user.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvitationUsed
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errMissingAccountInfos):
+		response.Fail(c, response.ErrValidation.WithMessage("Name and password are required to create the account"))
+		return
+	case errors.Is(err, errInvitationUsed):
+		response.Fail(c, response.ErrInvitationAccepted)
+		return
+	case err != nil:
+		log.Println("Erreur lors de l'acceptation de l'invitation:", err)
+		response.Fail(c, response.Internal("Failed to accept invitation"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.AcceptInvitationResponse{
+		UserID:     user.ID,
+		Role:       invitation.Role.String(),
+		KermesseID: invitation.KermesseID,
+		StandID:    invitation.StandID,
+	})
+}
@@ -1,19 +1,23 @@
 package jetons
 
 import (
-	"github.com/stripe/stripe-go"
-    "github.com/stripe/stripe-go/paymentintent"
+	"errors"
+	"example/hello/i18n"
+	"example/hello/internal/apis/pagination"
 	"example/hello/internal/apis/services"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
 	"example/hello/requests"
 	"example/hello/response"
 	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stripe/stripe-go"
+    "github.com/stripe/stripe-go/paymentintent"
+	"gorm.io/gorm"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
-	"os"
-	"github.com/gin-gonic/gin"
 )
 
 
@@ -33,7 +37,7 @@ import (
 func CreateJetonTransaction(c *gin.Context) {
 	var req requests.CreateJetonsTransactionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format: " + err.Error()})
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
@@ -49,7 +53,7 @@ func CreateJetonTransaction(c *gin.Context) {
 	}
 
 	if err := initializers.DB.Create(&jetons_transaction).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create transaction"})
+		response.Fail(c, response.Internal("Failed to create transaction"))
 		return
 	}
 
@@ -58,7 +62,7 @@ func CreateJetonTransaction(c *gin.Context) {
 
 // PayWithJetons godoc
 // @Summary Pay for items or activities at a stand with jetons
-// @Description Allow users to pay with jetons for food, drinks, or activities at a specific stand
+// @Description Allow users to pay with jetons for food, drinks, or activities at a specific stand. user_id must be the caller or a child the caller is a full guardian of
 // @Tags JetonTransaction
 // @Accept json
 // @Produce json
@@ -66,6 +70,7 @@ func CreateJetonTransaction(c *gin.Context) {
 // @Success 200 {object} response.SuccessResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
@@ -73,20 +78,31 @@ func CreateJetonTransaction(c *gin.Context) {
 func PayWithJetons(c *gin.Context) {
 	var req requests.PaymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	// Seul le titulaire du compte, ou un tuteur avec tous les droits, dépense ses jetons
+	err := services.RequireSpender(initializers.DB, c.GetUint("userID"), req.UserID)
+	switch {
+	case errors.Is(err, services.ErrNotGuardian), errors.Is(err, services.ErrGuardianPermission):
+		response.Fail(c, response.ErrForbidden)
+		return
+	case err != nil:
+		response.Fail(c, response.Internal("Failed to check guardian permission"))
 		return
 	}
 
 	// Récupérer le stand avec son stock
 	var stand models.Stand
 	if err := initializers.DB.Preload("Stocks").First(&stand, req.StandID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Stand not found"})
+		response.Fail(c, response.ErrStandNotFound)
 		return
 	}
 
 	// Vérifier si le stand a du stock
 	if len(stand.Stocks) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No stock available for this stand"})
+		response.Fail(c, response.ErrStockExhausted)
 		return
 	}
 
@@ -99,12 +115,22 @@ func PayWithJetons(c *gin.Context) {
 	// Vérifier le solde de l'utilisateur
 	user, err := services.GetUserByID(req.UserID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		response.Fail(c, response.ErrUserNotFound)
 		return
 	}
 
 	if user.SoldeJetons < totalCost {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient jeton balance"})
+		response.Fail(c, response.ErrInsufficientBalance)
+		return
+	}
+
+	// Le plafond journalier fixé par les tuteurs s'applique aux élèves
+	if err := services.CheckSpendingLimit(initializers.DB, user.ID, totalCost); err != nil {
+		if errors.Is(err, services.ErrSpendingLimitExceeded) {
+			response.Fail(c, response.ErrDailyLimitExceeded)
+		} else {
+			response.Fail(c, response.Internal("Failed to check spending limit"))
+		}
 		return
 	}
 
@@ -115,7 +141,7 @@ func PayWithJetons(c *gin.Context) {
 	user.SoldeJetons -= totalCost
 	if err := tx.Save(&user).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user balance"})
+		response.Fail(c, response.Internal("Failed to update user balance"))
 		return
 	}
 
@@ -123,7 +149,7 @@ func PayWithJetons(c *gin.Context) {
 	stand.JetonsCollectes += int(totalCost)
 	if err := tx.Save(&stand).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stand jetons"})
+		response.Fail(c, response.Internal("Failed to update stand jetons"))
 		return
 	}
 
@@ -138,7 +164,7 @@ func PayWithJetons(c *gin.Context) {
 
 	if err := tx.Create(&transaction).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record transaction"})
+		response.Fail(c, response.Internal("Failed to record transaction"))
 		return
 	}
 
@@ -147,13 +173,13 @@ func PayWithJetons(c *gin.Context) {
     case models.StandNourriture, models.StandBoisson:
         if err := services.UpdateStandStock(tx, stand.ID, -int(req.Quantity)); err != nil {
             tx.Rollback()
-            c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update stock: %v", err)})
+            response.Fail(c, response.Internal("Failed to update stock"))
             return
         }
     case models.StandActivite:
         if err := services.UpdateStandStock(tx, stand.ID, -int(req.Quantity)); err != nil {
             tx.Rollback()
-            c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update stock: %v", err)})
+            response.Fail(c, response.Internal("Failed to update stock"))
             return
         }
 
@@ -166,7 +192,7 @@ func PayWithJetons(c *gin.Context) {
             parent.PointsAccumules += totalPoints
             if err := tx.Save(&parent).Error; err != nil {
                 tx.Rollback()
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update parent points"})
+                response.Fail(c, response.Internal("Failed to update parent points"))
                 return
             }
         } else {
@@ -177,13 +203,13 @@ func PayWithJetons(c *gin.Context) {
                 eleve.PointsAccumules += totalPoints
                 if err := tx.Save(&eleve).Error; err != nil {
                     tx.Rollback()
-                    c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update student points"})
+                    response.Fail(c, response.Internal("Failed to update student points"))
                     return
                 }
             } else {
                 // Ni parent ni élève
                 tx.Rollback()
-                c.JSON(http.StatusBadRequest, gin.H{"error": "User is neither a parent nor a student"})
+                response.Fail(c, response.ErrInvalidUserType)
                 return
             }
         }
@@ -191,12 +217,12 @@ func PayWithJetons(c *gin.Context) {
 
     // Commit de la transaction
     if err := tx.Commit().Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete payment"})
+        response.Fail(c, response.Internal("Failed to complete payment"))
         return
     }
 
     c.JSON(http.StatusOK, gin.H{
-        "message":     "Payment successful",
+        "message":     i18n.T(c, "Payment successful"),
         "new_balance": user.SoldeJetons,
         "total_cost":  totalCost,
     })
@@ -204,13 +230,14 @@ func PayWithJetons(c *gin.Context) {
 
 // BuyJetons godoc
 // @Summary Acheter des jetons avec de l'argent réel
-// @Description Permet à un utilisateur d'acheter des jetons en utilisant de l'argent réel
+// @Description Permet à l'utilisateur connecté, dont l'adresse e-mail est vérifiée, d'acheter des jetons pour son compte en utilisant de l'argent réel ; user_id doit être le sien
 // @Tags JetonTransaction
 // @Accept json
 // @Produce json
 // @Param request body requests.BuyJetonsRequest true "Détails de l'achat de jetons"
 // @Success 200 {object} response.SuccessResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
@@ -223,7 +250,23 @@ func BuyJetons(c *gin.Context) {
 		TokenAmount int   `json:"token_amount" binding:"required,gt=0"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	// Les jetons sont achetés pour son propre compte, et seuls les comptes dont l'adresse e-mail est vérifiée
+	// peuvent en acheter
+	if req.UserID != c.GetUint("userID") {
+		response.Fail(c, response.ErrForbidden)
+		return
+	}
+	buyer, err := services.GetUserByID(c.GetUint("userID"))
+	if err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+	if buyer.EmailVerifiedAt == nil {
+		response.Fail(c, response.ErrEmailNotVerified)
 		return
 	}
 
@@ -238,7 +281,7 @@ func BuyJetons(c *gin.Context) {
 
         pi, err := paymentintent.New(params)
         if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de l'intention de paiement"})
+            response.Fail(c, response.Internal("Failed to create payment intent"))
             return
         }
 
@@ -249,14 +292,14 @@ func BuyJetons(c *gin.Context) {
 	var user models.User
 	if err := tx.First(&user, req.UserID).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		response.Fail(c, response.ErrUserNotFound)
 		return
 	}
 
 	user.SoldeJetons += int64(jetonsToAdd)
 	if err := tx.Save(&user).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user balance"})
+		response.Fail(c, response.Internal("Failed to update user balance"))
 		return
 	}
 
@@ -270,17 +313,17 @@ func BuyJetons(c *gin.Context) {
 
 	if err := tx.Create(&transaction).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record transaction"})
+		response.Fail(c, response.Internal("Failed to record transaction"))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete jeton purchase"})
+		response.Fail(c, response.Internal("Failed to complete jeton purchase"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":     "Jetons purchased successfully",
+		"message":     i18n.T(c, "Jetons purchased successfully"),
 		"new_balance": user.SoldeJetons,
 		"payment_id":  pi.ClientSecret,
 		"client_secret": pi.ClientSecret,
@@ -289,13 +332,14 @@ func BuyJetons(c *gin.Context) {
 
 // AttributeJetonsToChild godoc
 // @Summary Attribuer des jetons à un enfant
-// @Description Permet à un parent de transférer des jetons à son enfant
+// @Description Permet à un tuteur (permission FULL ou FUND_ONLY) de transférer des jetons à un enfant
 // @Tags JetonTransaction
 // @Accept json
 // @Produce json
 // @Param request body requests.AttributeJetonsRequest true "Détails du transfert de jetons"
 // @Success 200 {object} response.SuccessResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
@@ -311,7 +355,7 @@ func AttributeJetonsToChild(c *gin.Context) {
 
 	// 1. Vérification des paramètres de la requête
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
@@ -322,7 +366,7 @@ func AttributeJetonsToChild(c *gin.Context) {
 	var parent models.User
 	if err := tx.First(&parent, req.ParentID).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "Parent not found"})
+		response.Fail(c, response.ErrParentNotFound)
 		return
 	}
 
@@ -330,29 +374,21 @@ func AttributeJetonsToChild(c *gin.Context) {
 	var child models.Eleve
 	if err := tx.First(&child, req.ChildID).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "Child not found"})
+		response.Fail(c, response.ErrChildNotFound)
 		return
 	}
 
-	// 5. Vérifier la relation parent-enfant
-	var parentModel models.Parent
-	if err := tx.Where("user_id = ?", req.ParentID).Preload("Enfants").First(&parentModel).Error; err != nil {
+	// 5. Vérifier que le parent est tuteur de l'enfant avec le droit de le financer
+	if err := services.RequireGuardian(tx, req.ParentID, req.ChildID, models.GuardianPermission.CanFund); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "Parent-child relationship not found"})
-		return
-	}
-
-	childFound := false
-	for _, enfant := range parentModel.Enfants {
-		if enfant.ID == req.ChildID {
-			childFound = true
-			break
+		switch {
+		case errors.Is(err, services.ErrNotGuardian):
+			response.Fail(c, response.ErrNotGuardian)
+		case errors.Is(err, services.ErrGuardianPermission):
+			response.Fail(c, response.ErrGuardianPermission.WithMessage("Guardian permission does not allow funding this child"))
+		default:
+			response.Fail(c, response.Internal("Failed to check guardian permission"))
 		}
-	}
-
-	if !childFound {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "This child is not associated with the parent"})
 		return
 	}
 
@@ -360,14 +396,14 @@ func AttributeJetonsToChild(c *gin.Context) {
 	var childUser models.User
 	if err := tx.First(&childUser, child.UserID).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "Child's user account not found"})
+		response.Fail(c, response.ErrChildNotFound)
 		return
 	}
 
 	// 7. Vérifier que le parent a suffisamment de jetons
 	if parent.SoldeJetons < req.Amount {
 		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient jetons"})
+		response.Fail(c, response.ErrInsufficientBalance)
 		return
 	}
 
@@ -377,13 +413,13 @@ func AttributeJetonsToChild(c *gin.Context) {
 
 	if err := tx.Save(&parent).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update parent balance"})
+		response.Fail(c, response.Internal("Failed to update parent balance"))
 		return
 	}
 
 	if err := tx.Save(&childUser).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update child balance"})
+		response.Fail(c, response.Internal("Failed to update child balance"))
 		return
 	}
 
@@ -397,77 +433,125 @@ func AttributeJetonsToChild(c *gin.Context) {
 
 	if err := tx.Create(&transaction).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record transaction"})
+		response.Fail(c, response.Internal("Failed to record transaction"))
 		return
 	}
 
 
 	// 10. Commit de la transaction
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete jeton transfer"})
+		response.Fail(c, response.Internal("Failed to complete jeton transfer"))
 		return
 	}
 
 	// 11. Réponse de succès
 	c.JSON(http.StatusOK, gin.H{
-		"message":            "Jetons transferred successfully",
+		"message":            i18n.T(c, "Jetons transferred successfully"),
 		"parent_new_balance": parent.SoldeJetons,
 		"child_new_balance":  childUser.SoldeJetons,
 	})
 }
 
+var transactionList = pagination.Spec{
+	Model: &models.JetonTransaction{},
+	Filters: []pagination.Filter{
+		pagination.Equal("type", "type", pagination.OneOf(map[string]interface{}{
+			string(models.TransactionTypeAchat):         models.TransactionTypeAchat,
+			string(models.TransactionTypeUtilisation):   models.TransactionTypeUtilisation,
+			string(models.TransactionTypeTransfert):     models.TransactionTypeTransfert,
+			string(models.TransactionTypeRemboursement): models.TransactionTypeRemboursement,
+		})),
+		pagination.Equal("stand_id", "stand_id", pagination.ID),
+		pagination.Equal("tombola_id", "tombola_id", pagination.ID),
+		pagination.Where("kermesse_id", "stand_id IN (SELECT id FROM stands WHERE kermesse_id = ?)", pagination.ID),
+		pagination.From("from", "date"),
+		pagination.To("to", "date"),
+	},
+	Sorts:       map[string]string{"id": "id", "date": "date", "montant": "montant"},
+	DefaultSort: "-date",
+}
+
 // GetUserTransactions godoc
 // @Summary Get user's jeton transactions
-// @Description Get all jeton transactions for a specific user
+// @Description Get a page of jeton transactions for a specific user, newest first
 // @Tags JetonTransaction
 // @Produce json
 // @Param id path int true "User ID"
-// @Success 200 {array} models.JetonTransaction
+// @Param type query string false "Filter by type (ACHAT, UTILISATION, TRANSFERT, REMBOURSEMENT)"
+// @Param stand_id query int false "Filter by stand"
+// @Param tombola_id query int false "Filter by tombola"
+// @Param kermesse_id query int false "Filter by kermesse"
+// @Param from query string false "Only transactions at or after this date (RFC 3339)"
+// @Param to query string false "Only transactions before this date (RFC 3339)"
+// @Param sort query string false "Sort key: id, date or montant, prefixed with - for descending order" default(-date)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.JetonTransaction}
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/users/{id}/jeton-transactions [get]
 func GetUserTransactions(c *gin.Context) {
-	userID, _ := strconv.Atoi(c.Param("id"))
-	var transactions []models.JetonTransaction
-	if err := initializers.DB.Where("user_id = ?", userID).Find(&transactions).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions"})
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
 		return
 	}
-	if len(transactions) == 0 {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "No transactions found for this users"})
+	if !pagination.RequireParent(c, &models.User{}, userID, response.ErrUserNotFound) {
 		return
 	}
-
-	c.JSON(http.StatusOK, transactions)
+	listTransactions(c, initializers.DB.Where("user_id = ?", userID))
 }
 
 // GetStandTransactions godoc
 // @Summary Get stand's jeton transactions
-// @Description Get all jeton transactions for a specific stand
+// @Description Get a page of jeton transactions for a specific stand, newest first
 // @Tags JetonTransaction
 // @Produce json
 // @Param id path int true "Stand ID"
-// @Success 200 {array} models.JetonTransaction
+// @Param type query string false "Filter by type (ACHAT, UTILISATION, TRANSFERT, REMBOURSEMENT)"
+// @Param from query string false "Only transactions at or after this date (RFC 3339)"
+// @Param to query string false "Only transactions before this date (RFC 3339)"
+// @Param sort query string false "Sort key: id, date or montant, prefixed with - for descending order" default(-date)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.JetonTransaction}
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/stands/{id}/jeton-transactions [get]
 func GetStandTransactions(c *gin.Context) {
-	standID, _ := strconv.Atoi(c.Param("id"))
-	var transactions []models.JetonTransaction
-	if err := initializers.DB.Where("stand_id = ?", standID).Find(&transactions).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions"})
+	standID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+	if !pagination.RequireParent(c, &models.Stand{}, standID, response.ErrStandNotFound) {
+		return
+	}
+	listTransactions(c, initializers.DB.Where("stand_id = ?", standID))
+}
+
+// listTransactions renvoie une page des transactions sélectionnées par query
+func listTransactions(c *gin.Context, query *gorm.DB) {
+	params, err := pagination.Parse(c, transactionList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
-	if len(transactions) == 0 {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "No transactions found for this stand"})
+	var transactions []models.JetonTransaction
+	page, err := pagination.Find(query, params, &transactions)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve transactions"))
 		return
 	}
-	c.JSON(http.StatusOK, transactions)
+
+	c.JSON(http.StatusOK, response.PageResponse{Data: transactions, Page: page})
 }
 
 // GetTransactionSummary godoc
@@ -483,18 +567,20 @@ func GetStandTransactions(c *gin.Context) {
 // @Router /api/jeton-transactions/summary [get]
 func GetTransactionSummary(c *gin.Context) {
 	var summary struct {
-		TotalAchats       int
-		TotalUtilisations int
-		TotalTransferts   int
+		TotalAchats         int
+		TotalUtilisations   int
+		TotalTransferts     int
+		TotalRemboursements int
 	}
 
 	if err := initializers.DB.Model(&models.JetonTransaction{}).
 		Select("SUM(CASE WHEN type = ? THEN montant ELSE 0 END) as total_achats, "+
 			"SUM(CASE WHEN type = ? THEN montant ELSE 0 END) as total_utilisations, "+
-			"SUM(CASE WHEN type = ? THEN montant ELSE 0 END) as total_transferts",
-			models.TransactionTypeAchat, models.TransactionTypeUtilisation, models.TransactionTypeTransfert).
-		Row().Scan(&summary.TotalAchats, &summary.TotalUtilisations, &summary.TotalTransferts); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transaction summary"})
+			"SUM(CASE WHEN type = ? THEN montant ELSE 0 END) as total_transferts, "+
+			"SUM(CASE WHEN type = ? THEN montant ELSE 0 END) as total_remboursements",
+			models.TransactionTypeAchat, models.TransactionTypeUtilisation, models.TransactionTypeTransfert, models.TransactionTypeRemboursement).
+		Row().Scan(&summary.TotalAchats, &summary.TotalUtilisations, &summary.TotalTransferts, &summary.TotalRemboursements); err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve transaction summary"))
 		return
 	}
 
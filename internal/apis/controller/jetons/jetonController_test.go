@@ -0,0 +1,39 @@
+package jetons
+
+import (
+	"example/hello/internal/apis/apitest"
+	"example/hello/internal/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetUserTransactions(t *testing.T) {
+	apitest.OpenDatabase(t, &models.User{}, &models.JetonTransaction{})
+	user := models.User{Name: "Alice", Email: "alice@example.com"}
+	apitest.Create(t, &user)
+
+	t.Run("unknown user", func(t *testing.T) {
+		recorder := apitest.Get(t, "/users/:id/jeton-transactions", GetUserTransactions, "/users/999/jeton-transactions")
+		apitest.ExpectError(t, recorder, http.StatusNotFound, "USER_NOT_FOUND")
+	})
+	t.Run("user without transactions", func(t *testing.T) {
+		recorder := apitest.Get(t, "/users/:id/jeton-transactions", GetUserTransactions, fmt.Sprintf("/users/%d/jeton-transactions", user.ID))
+		apitest.ExpectEmptyPage(t, recorder, "-date")
+	})
+}
+
+func TestGetStandTransactions(t *testing.T) {
+	apitest.OpenDatabase(t, &models.Stand{}, &models.JetonTransaction{})
+	stand := models.Stand{Nom: "Crêpes"}
+	apitest.Create(t, &stand)
+
+	t.Run("unknown stand", func(t *testing.T) {
+		recorder := apitest.Get(t, "/stands/:id/jeton-transactions", GetStandTransactions, "/stands/999/jeton-transactions")
+		apitest.ExpectError(t, recorder, http.StatusNotFound, "STAND_NOT_FOUND")
+	})
+	t.Run("stand without transactions", func(t *testing.T) {
+		recorder := apitest.Get(t, "/stands/:id/jeton-transactions", GetStandTransactions, fmt.Sprintf("/stands/%d/jeton-transactions", stand.ID))
+		apitest.ExpectEmptyPage(t, recorder, "-date")
+	})
+}
@@ -2,8 +2,11 @@ package kermesses
 
 import (
 	"encoding/json"
+	"example/hello/internal/apis/pagination"
+	"example/hello/internal/apis/services"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
+	"example/hello/requests"
 	"example/hello/response"
 	"io/ioutil"
 	"log"
@@ -11,6 +14,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type PlanInteractif struct {
@@ -85,11 +89,11 @@ func InitKermessePlans() error {
 
 // CreateKermesse godoc
 // @Summary Create a new kermesse
-// @Description Create a new kermesse with the provided information
+// @Description Create a new kermesse with the provided information. Unknown fields are rejected
 // @Tags Kermesse
 // @Accept json
 // @Produce json
-// @Param kermesse body models.Kermesse true "Kermesse data"
+// @Param kermesse body requests.CreateKermesseRequest true "Kermesse data"
 // @Success 201 {object} response.KermesseResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
@@ -97,17 +101,36 @@ func InitKermessePlans() error {
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/kermesses [post]
 func CreateKermesse(c *gin.Context) {
-	var newKermesse models.Kermesse
-	if err := c.ShouldBindJSON(&newKermesse); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+	var req requests.CreateKermesseRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
+	newKermesse := models.Kermesse{
+		Nom:            req.Nom,
+		Date:           req.Date,
+		Lieu:           req.Lieu,
+		PlanInteractif: req.PlanInteractif,
+	}
+
 	// Créer une tombola vide pour la kermesse
 	//newKermesse.Tombola = &models.Tombola{}
 
-	if err := initializers.DB.Create(&newKermesse).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create kermesse"})
+	// L'organisateur qui crée la kermesse en devient automatiquement organisateur
+	userID, _ := c.Get("userID")
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newKermesse).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.Organisateur{}).Where("user_id = ?", userID).Count(&count).Error; err != nil || count == 0 {
+			return err
+		}
+		return services.AddKermesseOrganiser(tx, userID.(uint), newKermesse.ID)
+	})
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to create kermesse"))
 		return
 	}
 
@@ -119,24 +142,49 @@ func CreateKermesse(c *gin.Context) {
 	})
 }
 
+var kermesseList = pagination.Spec{
+	Model: &models.Kermesse{},
+	Filters: []pagination.Filter{
+		pagination.From("from", "date"),
+		pagination.To("to", "date"),
+		pagination.Where("organisateur_id", "id IN (SELECT kermesse_id FROM organisateur_kermesses WHERE organisateur_id = ?)", pagination.ID),
+	},
+	Sorts:       map[string]string{"id": "id", "nom": "nom", "date": "date"},
+	DefaultSort: "-date",
+}
+
 // GetKermesses godoc
 // @Summary Get all kermesses
-// @Description Retrieve a list of all kermesses
+// @Description Retrieve a page of kermesses, the most recent first
 // @Tags Kermesse
 // @Produce json
-// @Success 200 {array} response.KermesseResponse
+// @Param from query string false "Only kermesses on or after this date (RFC 3339)"
+// @Param to query string false "Only kermesses before this date (RFC 3339)"
+// @Param organisateur_id query int false "Filter by organiser"
+// @Param sort query string false "Sort key: id, nom or date, prefixed with - for descending order" default(-date)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]response.KermesseResponse}
+// @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/kermesses [get]
 func GetKermesses(c *gin.Context) {
+	params, err := pagination.Parse(c, kermesseList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
 	var kermesses []models.Kermesse
-	if err := initializers.DB.Find(&kermesses).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve kermesses"})
+	page, err := pagination.Find(initializers.DB, params, &kermesses)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve kermesses"))
 		return
 	}
 
-	var kermesseResponses []response.KermesseResponse
+	kermesseResponses := make([]response.KermesseResponse, 0, len(kermesses))
 	for _, kermesse := range kermesses {
 		kermesseResponses = append(kermesseResponses, response.KermesseResponse{
 			ID:   kermesse.ID,
@@ -146,7 +194,7 @@ func GetKermesses(c *gin.Context) {
 		})
 	}
 
-	c.JSON(http.StatusOK, kermesseResponses)
+	c.JSON(http.StatusOK, response.PageResponse{Data: kermesseResponses, Page: page})
 }
 
 // GetKermesse godoc
@@ -164,7 +212,7 @@ func GetKermesse(c *gin.Context) {
 	id := c.Param("id")
 	var kermesse models.Kermesse
 	if err := initializers.DB.First(&kermesse, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Kermesse not found"})
+		response.Fail(c, response.ErrKermesseNotFound)
 		return
 	}
 
@@ -178,12 +226,12 @@ func GetKermesse(c *gin.Context) {
 
 // UpdateKermesse godoc
 // @Summary Update a kermesse
-// @Description Update details of a specific kermesse
+// @Description Partially update a kermesse (JSON Merge Patch): absent fields are kept, plan_interactif set to null is cleared. Unknown fields are rejected
 // @Tags Kermesse
 // @Accept json
 // @Produce json
 // @Param id path int true "Kermesse ID"
-// @Param kermesse body models.Kermesse true "Updated kermesse data"
+// @Param kermesse body requests.UpdateKermesseRequest true "Fields to update"
 // @Success 200 {object} response.KermesseResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
@@ -191,21 +239,33 @@ func GetKermesse(c *gin.Context) {
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/kermesses/{id} [put]
+// @Router /api/kermesses/{id} [patch]
 func UpdateKermesse(c *gin.Context) {
 	id := c.Param("id")
 	var kermesse models.Kermesse
 	if err := initializers.DB.First(&kermesse, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Kermesse not found"})
+		response.Fail(c, response.ErrKermesseNotFound)
 		return
 	}
 
-	if err := c.ShouldBindJSON(&kermesse); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+	var req requests.UpdateKermesseRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
-	if err := initializers.DB.Save(&kermesse).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to update kermesse"})
+	req.Nom.Apply(&kermesse.Nom)
+	req.Date.Apply(&kermesse.Date)
+	req.Lieu.Apply(&kermesse.Lieu)
+	if req.PlanInteractif.Set {
+		kermesse.PlanInteractif = ""
+		if req.PlanInteractif.Value != nil {
+			kermesse.PlanInteractif = *req.PlanInteractif.Value
+		}
+	}
+
+	if err := initializers.DB.Model(&kermesse).Select("nom", "date", "lieu", "plan_interactif").Updates(&kermesse).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to update kermesse"))
 		return
 	}
 
@@ -233,12 +293,12 @@ func DeleteKermesse(c *gin.Context) {
 	id := c.Param("id")
 	var kermesse models.Kermesse
 	if err := initializers.DB.First(&kermesse, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Kermesse not found"})
+		response.Fail(c, response.ErrKermesseNotFound)
 		return
 	}
 
 	if err := initializers.DB.Delete(&kermesse).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to delete kermesse"})
+		response.Fail(c, response.Internal("Failed to delete kermesse"))
 		return
 	}
 
@@ -260,13 +320,13 @@ func GetKermessePlan(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid ID format"})
+		response.Fail(c, response.ErrInvalidID)
 		return
 	}
 
 	var kermesse models.Kermesse
 	if err := initializers.DB.Preload("Stands").First(&kermesse, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Kermesse not found"})
+		response.Fail(c, response.ErrKermesseNotFound)
 		return
 	}
 
@@ -306,7 +366,7 @@ func GetKermesseStands(c *gin.Context) {
 	id := c.Param("id")
 	var stands []models.Stand
 	if err := initializers.DB.Where("kermesse_id = ?", id).Find(&stands).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve stands"})
+		response.Fail(c, response.Internal("Failed to retrieve stands"))
 		return
 	}
 
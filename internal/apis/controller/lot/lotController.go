@@ -1,121 +1,206 @@
 package lot
 
 import (
+	"errors"
+	"example/hello/internal/apis/pagination"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
+	"example/hello/requests"
 	"example/hello/response"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// checkLotsEditable vérifie que les lots de la tombola peuvent encore changer : une fois la tombola tirée,
+// la vérification du tirage et la réattribution des lots non retirés reconstruisent l'ordre des gains à partir des lots
+func checkLotsEditable(c *gin.Context, tombolaID uint) bool {
+	var tombola models.Tombola
+	if err := initializers.DB.Select("id", "status").First(&tombola, tombolaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrTombolaNotFound)
+		} else {
+			response.Fail(c, response.Internal("Error retrieving tombola"))
+		}
+		return false
+	}
+	if tombola.Status == models.TombolaDrawn {
+		response.Fail(c, response.ErrLotsLocked)
+		return false
+	}
+	return true
+}
+
 // CreateLot godoc
 // @Summary Create a new lot
-// @Description Create a new lot for a kermesse
+// @Description Create a new lot for a tombola. Lots cannot change once the tombola is drawn
 // @Tags Lot
 // @Accept json
 // @Produce json
 // @Param id path int true "Tombola ID"
-// @Param tombola body models.Lot true "Tombola data"
+// @Param lot body requests.CreateLotRequest true "Lot data"
 // @Success 201 {object} models.Lot
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas/{id}/lots [post]
 func CreateLot(c *gin.Context) {
-	tombolaID, _ := strconv.Atoi(c.Param("id"))
-	var lot models.Lot
-	if err := c.ShouldBindJSON(&lot); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+	tombolaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+	var req requests.CreateLotRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	if !checkLotsEditable(c, uint(tombolaID)) {
 		return
 	}
-	lot.TombolaID = uint(tombolaID)
+	lot := models.Lot{
+		TombolaID:   uint(tombolaID),
+		Nom:         req.Nom,
+		Description: req.Description,
+		Valeur:      req.Valeur,
+		Quantite:    req.Quantite,
+		Rang:        req.Rang,
+	}
+	if lot.Quantite == 0 {
+		lot.Quantite = 1
+	}
+	if lot.Rang == 0 {
+		lot.Rang = 1
+	}
 	if err := initializers.DB.Create(&lot).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create lot"})
+		response.Fail(c, response.Internal("Failed to create lot"))
 		return
 	}
 	c.JSON(http.StatusCreated, lot)
 }
 
+var lotList = pagination.Spec{
+	Model:       &models.Lot{},
+	Sorts:       map[string]string{"id": "id", "nom": "nom", "valeur": "valeur", "rang": "rang"},
+	DefaultSort: "rang",
+}
+
 // GetLots godoc
 // @Summary Get all lots
-// @Description Retrieve a list of all lots
+// @Description Retrieve a page of the lots of a tombola, first prize first by default. An existing tombola without lots returns an empty page
 // @Tags Lot
 // @Produce json
 // @Param id path int true "Tombola ID"
-// @Success 200 {array} response.LotResponse
+// @Param sort query string false "Sort key: id, nom, valeur or rang, prefixed with - for descending order" default(rang)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]response.LotResponse}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas/{id}/lots [get]
 func GetLots(c *gin.Context) {
-	tombolaID, _ := strconv.Atoi(c.Param("id"))
-	var lots []models.Lot
+	tombolaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
 
-	// Récupérer les lots pour la tombola donnée
-	if err := initializers.DB.Where("tombola_id = ?", tombolaID).Find(&lots).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve lots"})
+	params, err := pagination.Parse(c, lotList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	if !pagination.RequireParent(c, &models.Tombola{}, tombolaID, response.ErrTombolaNotFound) {
 		return
 	}
 
-	// Vérifier si la liste de lots est vide
-	if len(lots) == 0 {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "No lots found for this tombola"})
+	// Récupérer les lots pour la tombola donnée
+	var lots []models.Lot
+	page, err := pagination.Find(initializers.DB.Where("tombola_id = ?", tombolaID), params, &lots)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve lots"))
 		return
 	}
 
-	// Renvoyer les lots s'ils existent
-	c.JSON(http.StatusOK, lots)
+	c.JSON(http.StatusOK, response.PageResponse{Data: lots, Page: page})
 }
 
 // UpdateLot godoc
 // @Summary Update a lot
-// @Description Update details of a specific lot
+// @Description Partially update a lot (JSON Merge Patch). Unknown fields are rejected; lots cannot change once the tombola is drawn
 // @Tags Lot
 // @Accept json
 // @Produce json
 // @Param id path int true "Lot ID"
-// @Param kermesse body models.Lot true "Updated lot data"
+// @Param lot body requests.UpdateLotRequest true "Fields to update"
 // @Success 200 {object} response.LotResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas/lots/{id} [put]
+// @Router /api/tombolas/lots/{id} [patch]
 func UpdateLot(c *gin.Context) {
 	id := c.Param("id")
 	var lot models.Lot
 	if err := initializers.DB.First(&lot, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Lot not found"})
+		response.Fail(c, response.ErrLotNotFound)
+		return
+	}
+	var req requests.UpdateLotRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
-	if err := c.ShouldBindJSON(&lot); err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to update lot"})
+	if !checkLotsEditable(c, lot.TombolaID) {
+		return
+	}
+	req.Nom.Apply(&lot.Nom)
+	req.Description.Apply(&lot.Description)
+	req.Valeur.Apply(&lot.Valeur)
+	req.Quantite.Apply(&lot.Quantite)
+	req.Rang.Apply(&lot.Rang)
+	if err := initializers.DB.Model(&lot).Select("nom", "description", "valeur", "quantite", "rang").Updates(&lot).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to update lot"))
 		return
 	}
-	initializers.DB.Save(&lot)
 	c.JSON(http.StatusOK, lot)
 }
 
 // DeleteLot godoc
 // @Summary Delete a lot
-// @Description Delete a specific lot
+// @Description Delete a specific lot. Lots cannot change once the tombola is drawn
 // @Tags Lot
 // @Produce json
 // @Param id path int true "Lot ID"
 // @Success 200 {object} response.SuccessResponse
 // @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas/lots/{id} [delete]
 func DeleteLot(c *gin.Context) {
-	id := c.Param("id")
-	if err := initializers.DB.Delete(&models.Lot{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to delete kermesse"})
+	var lot models.Lot
+	if err := initializers.DB.First(&lot, c.Param("id")).Error; err != nil {
+		response.Fail(c, response.ErrLotNotFound)
+		return
+	}
+	if !checkLotsEditable(c, lot.TombolaID) {
+		return
+	}
+	if err := initializers.DB.Delete(&lot).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to delete kermesse"))
 		return
 	}
 	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
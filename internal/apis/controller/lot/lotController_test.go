@@ -0,0 +1,24 @@
+package lot
+
+import (
+	"example/hello/internal/apis/apitest"
+	"example/hello/internal/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetLots(t *testing.T) {
+	apitest.OpenDatabase(t, &models.Tombola{}, &models.Lot{})
+	tombola := models.Tombola{Nom: "Grande tombola"}
+	apitest.Create(t, &tombola)
+
+	t.Run("unknown tombola", func(t *testing.T) {
+		recorder := apitest.Get(t, "/tombolas/:id/lots", GetLots, "/tombolas/999/lots")
+		apitest.ExpectError(t, recorder, http.StatusNotFound, "TOMBOLA_NOT_FOUND")
+	})
+	t.Run("tombola without lots", func(t *testing.T) {
+		recorder := apitest.Get(t, "/tombolas/:id/lots", GetLots, fmt.Sprintf("/tombolas/%d/lots", tombola.ID))
+		apitest.ExpectEmptyPage(t, recorder, "rang")
+	})
+}
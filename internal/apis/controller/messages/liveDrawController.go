@@ -0,0 +1,341 @@
+package messages
+
+import (
+	"errors"
+	"example/hello/i18n"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/response"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// Étapes de la diffusion en direct d'un tirage
+const (
+	liveDrawStarted        = "draw_started"
+	liveDrawLotAnnounced   = "lot_announced"
+	liveDrawTicketRevealed = "ticket_revealed"
+	liveDrawFinished       = "draw_finished"
+	liveDrawVoided         = "draw_voided"
+)
+
+// liveDrawWriteTimeout écarte les spectateurs trop lents pour qu'ils ne retardent pas la diffusion
+const liveDrawWriteTimeout = 5 * time.Second
+
+// liveDrawReadLimit : le canal est en lecture seule, les messages des spectateurs sont ignorés
+const liveDrawReadLimit = 512
+
+// liveDrawQueue est le nombre d'étapes en attente d'envoi au-delà duquel un spectateur est déconnecté
+const liveDrawQueue = 32
+
+// liveDrawViewer est un spectateur : ses étapes lui sont écrites par sa propre goroutine, hors de liveDrawsMu,
+// pour qu'un spectateur lent ne retarde ni les autres canaux ni les arrivées
+type liveDrawViewer struct {
+	// userID est l'utilisateur connecté, 0 en mode affichage public
+	userID uint
+	send   chan liveDrawEvent
+}
+
+// queue met une étape en attente d'envoi ; liveDrawsMu doit être verrouillé. Un spectateur dont la file est
+// pleine est retiré du canal et déconnecté.
+func (room *liveDraw) queue(conn *websocket.Conn, viewer *liveDrawViewer, e liveDrawEvent) {
+	select {
+	case viewer.send <- e:
+	default:
+		log.Println("Spectateur trop lent, déconnecté de la diffusion du tirage")
+		room.removeViewer(conn)
+		conn.Close()
+	}
+}
+
+// removeViewer retire un spectateur et termine sa goroutine d'écriture ; liveDrawsMu doit être verrouillé
+func (room *liveDraw) removeViewer(conn *websocket.Conn) {
+	if viewer, ok := room.viewers[conn]; ok {
+		delete(room.viewers, conn)
+		close(viewer.send)
+	}
+}
+
+// liveDraw est le canal de diffusion du tirage d'une tombola
+type liveDraw struct {
+	viewers map[*websocket.Conn]*liveDrawViewer
+	// history contient les étapes déjà diffusées, rejouées aux spectateurs qui arrivent en cours de tirage
+	history []liveDrawEvent
+	// generation change à chaque nouveau tirage ou annulation : une diffusion dépassée s'arrête
+	generation int
+	// pending compte les tirages en cours d'enregistrement, dont la diffusion n'a pas encore commencé
+	pending int
+	// broadcasting reste vrai jusqu'à la dernière étape du tirage
+	broadcasting bool
+}
+
+type liveDrawEvent struct {
+	event    response.LiveDrawEvent
+	winnerID uint
+}
+
+var (
+	liveDraws = make(map[uint]*liveDraw)
+	// liveDrawsMu protège liveDraws et sérialise les écritures vers les spectateurs
+	liveDrawsMu sync.Mutex
+)
+
+// liveDrawFor renvoie le canal d'une tombola ; liveDrawsMu doit être verrouillé
+func liveDrawFor(tombolaID uint) *liveDraw {
+	room, ok := liveDraws[tombolaID]
+	if !ok {
+		room = &liveDraw{viewers: make(map[*websocket.Conn]*liveDrawViewer)}
+		liveDraws[tombolaID] = room
+	}
+	return room
+}
+
+// releaseLiveDraw supprime le canal d'une tombola qui n'a plus ni diffusion ni spectateur : le tirage terminé
+// est ensuite rejoué depuis la base ; liveDrawsMu doit être verrouillé
+func releaseLiveDraw(tombolaID uint, room *liveDraw) {
+	if liveDraws[tombolaID] == room && room.pending == 0 && !room.broadcasting && len(room.viewers) == 0 {
+		delete(liveDraws, tombolaID)
+	}
+}
+
+func sendLiveDrawEvent(conn *websocket.Conn, userID uint, e liveDrawEvent) error {
+	event := e.event
+	event.Mine = userID != 0 && userID == e.winnerID
+	conn.SetWriteDeadline(time.Now().Add(liveDrawWriteTimeout))
+	return conn.WriteJSON(event)
+}
+
+// publishLiveDrawEvent diffuse une étape à tous les spectateurs ; elle renvoie false si la diffusion est dépassée.
+// La dernière étape termine la diffusion.
+func publishLiveDrawEvent(room *liveDraw, generation int, e liveDrawEvent, last bool) bool {
+	liveDrawsMu.Lock()
+	defer liveDrawsMu.Unlock()
+	if room.generation != generation {
+		return false
+	}
+	room.history = append(room.history, e)
+	if last {
+		room.broadcasting = false
+	}
+	for conn, viewer := range room.viewers {
+		room.queue(conn, viewer, e)
+	}
+	return true
+}
+
+// liveDrawEvents décrit le déroulé d'un tirage : chaque lot est annoncé puis son ticket gagnant révélé.
+// Les gagnants sont chargés avec Lot et Ticket, dans l'ordre des gains.
+func liveDrawEvents(tombolaID uint, winners []models.Gagnant) []liveDrawEvent {
+	events := make([]liveDrawEvent, 0, 2*len(winners)+2)
+	events = append(events, liveDrawEvent{event: response.LiveDrawEvent{Type: liveDrawStarted, TombolaID: tombolaID, Total: len(winners)}})
+	for _, winner := range winners {
+		lot := response.LiveDrawEvent{
+			Type:      liveDrawLotAnnounced,
+			TombolaID: tombolaID,
+			Total:     len(winners),
+			Ordre:     winner.Ordre,
+			LotID:     winner.LotID,
+			LotNom:    winner.Lot.Nom,
+		}
+		events = append(events, liveDrawEvent{event: lot})
+		lot.Type = liveDrawTicketRevealed
+		lot.Numero = winner.Ticket.Numero
+		events = append(events, liveDrawEvent{event: lot, winnerID: winner.UserID})
+	}
+	events = append(events, liveDrawEvent{event: response.LiveDrawEvent{Type: liveDrawFinished, TombolaID: tombolaID, Total: len(winners)}})
+	return events
+}
+
+// ReserveDrawBroadcast annonce un tirage sur le point d'être enregistré : jusqu'à l'appel de la fonction
+// renvoyée, les spectateurs qui arrivent attendent la diffusion au lieu de recevoir le résultat d'un bloc.
+// BroadcastDraw doit être appelé avant cette fonction lorsque le tirage a eu lieu.
+func ReserveDrawBroadcast(tombolaID uint) func() {
+	liveDrawsMu.Lock()
+	room := liveDrawFor(tombolaID)
+	room.pending++
+	liveDrawsMu.Unlock()
+
+	return func() {
+		liveDrawsMu.Lock()
+		defer liveDrawsMu.Unlock()
+		room.pending--
+		releaseLiveDraw(tombolaID, room)
+	}
+}
+
+// BroadcastDraw diffuse en direct un tirage qui vient d'être effectué, en attendant delay entre deux étapes.
+// Les gagnants sont prévenus par la messagerie une fois le dernier ticket révélé, pour ne rien dévoiler avant.
+func BroadcastDraw(c *gin.Context, winners []models.Gagnant, delay time.Duration) {
+	if len(winners) == 0 {
+		return
+	}
+	notify := winnerNotifier(c, winners)
+	tombolaID := winners[0].TombolaID
+
+	liveDrawsMu.Lock()
+	room := liveDrawFor(tombolaID)
+	room.generation++
+	room.history = nil
+	room.broadcasting = true
+	generation := room.generation
+	liveDrawsMu.Unlock()
+
+	go func() {
+		events := liveDrawEvents(tombolaID, winners)
+		defer func() {
+			liveDrawsMu.Lock()
+			releaseLiveDraw(tombolaID, room)
+			liveDrawsMu.Unlock()
+		}()
+		for i, event := range events {
+			if i > 0 {
+				time.Sleep(delay)
+			}
+			// Un tirage annulé pendant la diffusion n'est ni terminé ni notifié
+			if !publishLiveDrawEvent(room, generation, event, i == len(events)-1) {
+				return
+			}
+		}
+		notify()
+	}()
+}
+
+// EndDrawBroadcast interrompt la diffusion d'un tirage annulé et prévient les spectateurs
+func EndDrawBroadcast(tombolaID uint) {
+	liveDrawsMu.Lock()
+	defer liveDrawsMu.Unlock()
+	room, ok := liveDraws[tombolaID]
+	if !ok {
+		return
+	}
+	room.generation++
+	room.history = nil
+	room.broadcasting = false
+	voided := liveDrawEvent{event: response.LiveDrawEvent{Type: liveDrawVoided, TombolaID: tombolaID}}
+	for conn, viewer := range room.viewers {
+		room.queue(conn, viewer, voided)
+	}
+	releaseLiveDraw(tombolaID, room)
+}
+
+// WatchDraw godoc
+// @Summary Watch a tombola draw live
+// @Description Open a read-only WebSocket that streams the draw of a tombola as it happens: draw_started, then for each award lot_announced followed by ticket_revealed with the winning ticket number, then draw_finished; draw_voided is sent if an admin voids the draw. Pacing is set when the draw is performed (reveal_delay). Viewers joining mid-draw receive the steps already shown, and viewers joining while the draw is being recorded wait for its first step; once the broadcast has ended (reveal_ends_at) the whole result is sent at once. A viewer too slow to keep up is disconnected. Messages sent by viewers are ignored. /api/tombolas/{id}/draw/live/display is the public display mode and needs no login; on /api/tombolas/{id}/draw/live, mine is set on the ticket_revealed events of the connected user's tickets
+// @Tags Tombola
+// @Produce json
+// @Param id path int true "Tombola ID"
+// @Success 101 {object} response.LiveDrawEvent "Switching Protocols, then a stream of events"
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/tombolas/{id}/draw/live [get]
+// @Router /api/tombolas/{id}/draw/live/display [get]
+func WatchDraw(c *gin.Context) {
+	tombolaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+	var tombola models.Tombola
+	if err := initializers.DB.First(&tombola, tombolaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrTombolaNotFound)
+		} else {
+			response.Fail(c, response.Internal("Failed to retrieve draw"))
+		}
+		return
+	}
+
+	// Un tirage dont la diffusion est terminée, ou qui n'a pas été diffusé depuis le démarrage du serveur, est
+	// envoyé d'un bloc ; les remplaçants des lots non retirés n'en font pas partie. Une diffusion interrompue par
+	// un redémarrage n'est pas rejouée avant l'heure à laquelle elle devait se terminer.
+	var replay []liveDrawEvent
+	if tombola.Status == models.TombolaDrawn && !tombola.Revealing(time.Now()) {
+		winners, err := services.ActiveWinners(initializers.DB, tombola.ID)
+		if err != nil {
+			response.Fail(c, response.Internal("Failed to retrieve draw"))
+			return
+		}
+		drawn := make([]models.Gagnant, 0, len(winners))
+		for _, winner := range winners {
+			if winner.RemplaceID == nil {
+				drawn = append(drawn, winner)
+			}
+		}
+		replay = liveDrawEvents(tombola.ID, drawn)
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println("Erreur lors de la mise à niveau WebSocket:", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(liveDrawReadLimit)
+	userID := c.GetUint("userID")
+
+	liveDrawsMu.Lock()
+	room := liveDrawFor(tombola.ID)
+	// Pendant l'enregistrement et la diffusion d'un tirage, seules les étapes déjà diffusées sont rejouées
+	if room.pending > 0 || room.broadcasting || len(room.history) > 0 {
+		replay = room.history
+	}
+	// La file contient toute la reprise : les étapes suivantes arrivent après elle, dans l'ordre
+	viewer := &liveDrawViewer{userID: userID, send: make(chan liveDrawEvent, len(replay)+liveDrawQueue)}
+	for _, event := range replay {
+		viewer.send <- event
+	}
+	room.viewers[conn] = viewer
+	liveDrawsMu.Unlock()
+
+	go func() {
+		for event := range viewer.send {
+			if err := sendLiveDrawEvent(conn, viewer.userID, event); err != nil {
+				log.Println("Erreur lors de la diffusion du tirage:", err)
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	// La lecture ne sert qu'à détecter la déconnexion du spectateur
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	liveDrawsMu.Lock()
+	room.removeViewer(conn)
+	releaseLiveDraw(tombola.ID, room)
+	liveDrawsMu.Unlock()
+}
+
+// ResumeWinnerNotifications prévient, au démarrage du serveur, les gagnants d'un tirage dont la diffusion a été
+// interrompue, à l'heure à laquelle elle devait se terminer
+func ResumeWinnerNotifications() {
+	pending, err := services.PendingNotifications(initializers.DB)
+	if err != nil {
+		log.Println("Erreur lors de la reprise des notifications des gagnants:", err)
+		return
+	}
+	for _, winners := range pending {
+		tombola := winners[0].Tombola
+		if tombola.DrawnByID == nil {
+			log.Printf("Gagnants de la tombola %d non prévenus : auteur du tirage inconnu", tombola.ID)
+			continue
+		}
+		notify := newWinnerNotifier(*tombola.DrawnByID, i18n.Default, winners)
+		var wait time.Duration
+		if tombola.RevealEndsAt != nil {
+			wait = time.Until(*tombola.RevealEndsAt)
+		}
+		time.AfterFunc(max(wait, 0), notify)
+	}
+}
@@ -2,17 +2,20 @@ package messages
 
 import (
 	"encoding/json"
+	"example/hello/i18n"
+	"example/hello/internal/apis/pagination"
+	"example/hello/internal/apis/services"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
 	"example/hello/response"
 	"log"
 	"net/http"
 	"strconv"
+	"sync"
     "time"
-    "fmt"
-    //"gorm.io/gorm"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
 )
 
 var upgrader = websocket.Upgrader{
@@ -23,6 +26,51 @@ var upgrader = websocket.Upgrader{
 
 var clients = make(map[uint]*websocket.Conn)
 
+// clientsMu protège clients et sérialise les écritures : une connexion n'accepte qu'un écrivain à la fois
+var clientsMu sync.Mutex
+
+// Push envoie un message à son destinataire s'il est connecté ; le message doit déjà être enregistré
+func Push(message models.Message) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if recipient, ok := clients[message.DestinataireID]; ok {
+		if err := recipient.WriteJSON(message); err != nil {
+			log.Println("Erreur lors de l'envoi WebSocket:", err)
+		}
+	}
+}
+
+// NotifyWinners annonce leur gain aux gagnants par la messagerie, de la part de l'utilisateur connecté ;
+// un échec est journalisé sans faire échouer la requête
+func NotifyWinners(c *gin.Context, winners []models.Gagnant) {
+	winnerNotifier(c, winners)()
+}
+
+// winnerNotifier prépare la notification des gagnants pour qu'elle puisse être envoyée après la fin de la requête
+func winnerNotifier(c *gin.Context, winners []models.Gagnant) func() {
+	return newWinnerNotifier(c.GetUint("userID"), i18n.FromContext(c), winners)
+}
+
+// newWinnerNotifier prévient les gagnants de la part de senderID ; fallback est la langue des destinataires
+// qui n'en ont pas choisi
+func newWinnerNotifier(senderID uint, fallback string, winners []models.Gagnant) func() {
+	return func() {
+		notifications, err := services.NotifyWinners(initializers.DB, senderID, winners, func(user models.User) string {
+			if lang := i18n.Normalize(user.Language); lang != "" {
+				return lang
+			}
+			return fallback
+		})
+		if err != nil {
+			log.Println("Erreur lors de la notification des gagnants:", err)
+			return
+		}
+		for _, notification := range notifications {
+			Push(notification)
+		}
+	}
+}
+
 // HandleWebSocket godoc
 // @Summary Établir une connexion WebSocket
 // @Description Établit une connexion WebSocket pour la messagerie en temps réel
@@ -53,13 +101,19 @@ func HandleWebSocket(c *gin.Context) {
 	}
 
 	userIDUint := uint(userID)
+	clientsMu.Lock()
 	clients[userIDUint] = conn
+	clientsMu.Unlock()
 
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
 			log.Println(err)
-			delete(clients, userIDUint)
+			clientsMu.Lock()
+			if clients[userIDUint] == conn {
+				delete(clients, userIDUint)
+			}
+			clientsMu.Unlock()
 			return
 		}
 
@@ -76,9 +130,7 @@ func HandleWebSocket(c *gin.Context) {
 		}
 
 		// Envoyer le message au destinataire s'il est connecté
-		if recipient, ok := clients[msg.DestinataireID]; ok {
-			recipient.WriteJSON(msg)
-		}
+		Push(msg)
 	}
 }
 
@@ -103,7 +155,7 @@ func SendMessage(c *gin.Context) {
     }
 
     if err := c.ShouldBindJSON(&messageInput); err != nil {
-        c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: fmt.Sprintf("Format de requête invalide: %v", err)})
+        response.Fail(c, response.BindingError(err))
         return
     }
 
@@ -112,7 +164,7 @@ func SendMessage(c *gin.Context) {
 
     // Vérification des IDs
     if messageInput.ExpediteurID == 0 || messageInput.DestinataireID == 0 {
-        c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Les IDs de l'expéditeur et du destinataire sont requis et ne peuvent pas être zéro"})
+        response.Fail(c, response.ErrValidation.WithMessage("Sender and recipient IDs are required"))
         return
     }
 
@@ -127,13 +179,13 @@ func SendMessage(c *gin.Context) {
 
     // Récupération des informations de l'expéditeur
     if err := initializers.DB.First(&message.Expediteur, message.ExpediteurID).Error; err != nil {
-        c.JSON(http.StatusNotFound, response.ErrorResponse{Error: fmt.Sprintf("Expéditeur non trouvé: %v", err)})
+        response.Fail(c, response.ErrUserNotFound.WithMessage("Sender not found"))
         return
     }
 
     // Récupération des informations du destinataire
     if err := initializers.DB.First(&message.Destinataire, message.DestinataireID).Error; err != nil {
-        c.JSON(http.StatusNotFound, response.ErrorResponse{Error: fmt.Sprintf("Destinataire non trouvé: %v", err)})
+        response.Fail(c, response.ErrUserNotFound.WithMessage("Recipient not found"))
         return
     }
 
@@ -142,20 +194,48 @@ func SendMessage(c *gin.Context) {
 
     // Insertion du message
     if err := initializers.DB.Create(&message).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: fmt.Sprintf("Échec de l'envoi du message: %v", err)})
+        response.Fail(c, response.Internal("Failed to send message"))
         return
     }
 
     c.JSON(http.StatusCreated, message)
 }
 
+var messageList = pagination.Spec{
+	Model: &models.Message{},
+	Filters: []pagination.Filter{
+		pagination.Equal("lu", "lu", pagination.Bool),
+		pagination.From("from", "date"),
+		pagination.To("to", "date"),
+		{
+			// Messages échangés avec un autre utilisateur, dans un sens ou dans l'autre
+			Param: "with",
+			Parse: pagination.ID,
+			Apply: func(query *gorm.DB, value interface{}) *gorm.DB {
+				return query.Where("expediteur_id = ? OR destinataire_id = ?", value, value)
+			},
+		},
+	},
+	Sorts:       map[string]string{"id": "id", "date": "date"},
+	DefaultSort: "-date",
+}
+
 // GetUserMessages godoc
 // @Summary Get user's messages
-// @Description Get all messages for a specific user (sent and received)
+// @Description Get a page of messages for a specific user (sent and received), newest first
 // @Tags Chat
 // @Produce json
 // @Param id path int true "User ID"
+// @Param with query int false "Only messages exchanged with this user"
+// @Param lu query bool false "Only read (true) or unread (false) messages"
+// @Param from query string false "Only messages sent at or after this date (RFC 3339)"
+// @Param to query string false "Only messages sent before this date (RFC 3339)"
+// @Param sort query string false "Sort key: id or date, prefixed with - for descending order" default(-date)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.Message}
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
@@ -163,60 +243,97 @@ func SendMessage(c *gin.Context) {
 func GetUserMessages(c *gin.Context) {
 	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid user ID"})
+		response.Fail(c, response.ErrInvalidID)
 		return
 	}
 
-	var messages []models.Message
-
-	// Récupérer les messages où l'utilisateur est soit l'expéditeur soit le destinataire
-	if err := initializers.DB.Where("expediteur_id = ? OR destinataire_id = ?", userID, userID).
-		Preload("Expediteur").Preload("Destinataire").Find(&messages).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve messages"})
+	params, err := pagination.Parse(c, messageList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	if !pagination.RequireParent(c, &models.User{}, userID, response.ErrUserNotFound) {
 		return
 	}
 
-	// Vérifier si des messages ont été trouvés
-	if len(messages) == 0 {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "No messages found for this user"})
+	// Récupérer les messages où l'utilisateur est soit l'expéditeur soit le destinataire
+	var messages []models.Message
+	query := initializers.DB.Where("expediteur_id = ? OR destinataire_id = ?", userID, userID).
+		Preload("Expediteur").Preload("Destinataire")
+	page, err := pagination.Find(query, params, &messages)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve messages"))
 		return
 	}
 
-	// Renvoie les messages
-	c.JSON(http.StatusOK, messages)
+	c.JSON(http.StatusOK, response.PageResponse{Data: messages, Page: page})
+}
+
+var conversationList = pagination.Spec{
+	Model: &models.Message{},
+	Filters: []pagination.Filter{
+		pagination.Equal("lu", "lu", pagination.Bool),
+		pagination.From("from", "date"),
+		pagination.To("to", "date"),
+	},
+	Sorts:       map[string]string{"id": "id", "date": "date"},
+	DefaultSort: "id",
 }
 
 // GetConversation godoc
 // @Summary Get conversation between two users
-// @Description Get all messages exchanged between two specific users
+// @Description Get a page of the messages exchanged between two specific users, oldest first. Users who never exchanged messages get an empty page
 // @Tags Chat
 // @Produce json
 // @Param userId1 path int true "First User ID"
 // @Param userId2 path int true "Second User ID"
-// @Success 200 {array} models.Message
+// @Param lu query bool false "Only read (true) or unread (false) messages"
+// @Param from query string false "Only messages sent at or after this date (RFC 3339)"
+// @Param to query string false "Only messages sent before this date (RFC 3339)"
+// @Param sort query string false "Sort key: id or date, prefixed with - for descending order" default(id)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.Message}
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/conversations/{userId1}/{userId2} [get]
 func GetConversation(c *gin.Context) {
-	userID1, _ := strconv.Atoi(c.Param("userId1"))
-	userID2, _ := strconv.Atoi(c.Param("userId2"))
+	userID1, err := strconv.Atoi(c.Param("userId1"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+	userID2, err := strconv.Atoi(c.Param("userId2"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	params, err := pagination.Parse(c, conversationList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	if !pagination.RequireParent(c, &models.User{}, userID1, response.ErrUserNotFound) ||
+		!pagination.RequireParent(c, &models.User{}, userID2, response.ErrUserNotFound) {
+		return
+	}
+
 	var messages []models.Message
-	if err := initializers.DB.Where(
+	query := initializers.DB.Where(
 		"(expediteur_id = ? AND destinataire_id = ?) OR (expediteur_id = ? AND destinataire_id = ?)",
 		userID1, userID2, userID2, userID1).
-		Preload("Expediteur").Preload("Destinataire").
-		Order("id ASC").Find(&messages).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrive conversation"})
+		Preload("Expediteur").Preload("Destinataire")
+	page, err := pagination.Find(query, params, &messages)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve conversation"))
 		return
 	}
 
-	if len(messages) == 0 {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "No messages found for these users"})
-		return
-	}
-	c.JSON(http.StatusOK, messages)
+	c.JSON(http.StatusOK, response.PageResponse{Data: messages, Page: page})
 }
 
 // MarkMessageAsRead godoc
@@ -235,43 +352,68 @@ func MarkMessageAsRead(c *gin.Context) {
 	messageID, _ := strconv.Atoi(c.Param("id"))
 	var message models.Message
 	if err := initializers.DB.First(&message, messageID).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Message not found"})
+		response.Fail(c, response.ErrMessageNotFound)
 		return
 	}
 
 	message.Lu = true
 	if err := initializers.DB.Save(&message).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to mark message as read"})
+		response.Fail(c, response.Internal("Failed to mark message as read"))
 		return
 	}
 
 	c.JSON(http.StatusOK, message)
 }
 
+var unreadMessageList = pagination.Spec{
+	Model: &models.Message{},
+	Filters: []pagination.Filter{
+		pagination.Equal("expediteur_id", "expediteur_id", pagination.ID),
+	},
+	Sorts:       map[string]string{"id": "id", "date": "date"},
+	DefaultSort: "date",
+}
+
 // GetUnreadMessages godoc
 // @Summary Get unread messages for a user
-// @Description Get all unread messages for a specific user
+// @Description Get a page of the unread messages received by a specific user, oldest first. A user without unread messages gets an empty page
 // @Tags Chat
 // @Produce json
 // @Param id path int true "User ID"
-// @Success 200 {array} models.Message
+// @Param expediteur_id query int false "Only messages sent by this user"
+// @Param sort query string false "Sort key: id or date, prefixed with - for descending order" default(date)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.Message}
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/users/{id}/messages/unread [get]
 func GetUnreadMessages(c *gin.Context) {
-	userID, _ := strconv.Atoi(c.Param("id"))
-	var messages []models.Message
-	if err := initializers.DB.Where("destinataire_id = ? AND lu = ?", userID, false).
-		Preload("Expediteur").Find(&messages).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve unread messages"})
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
 		return
 	}
 
-	if len(messages) == 0 {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "No messaages unread"})
+	params, err := pagination.Parse(c, unreadMessageList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
-	c.JSON(http.StatusOK, messages)
+	if !pagination.RequireParent(c, &models.User{}, userID, response.ErrUserNotFound) {
+		return
+	}
+
+	var messages []models.Message
+	query := initializers.DB.Where("destinataire_id = ? AND lu = ?", userID, false).Preload("Expediteur")
+	page, err := pagination.Find(query, params, &messages)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve unread messages"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.PageResponse{Data: messages, Page: page})
 }
@@ -0,0 +1,39 @@
+package messages
+
+import (
+	"example/hello/internal/apis/apitest"
+	"example/hello/internal/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetUserMessages(t *testing.T) {
+	apitest.OpenDatabase(t, &models.User{}, &models.Message{})
+	user := models.User{Name: "Alice", Email: "alice@example.com"}
+	apitest.Create(t, &user)
+
+	t.Run("unknown user", func(t *testing.T) {
+		recorder := apitest.Get(t, "/users/:id/messages", GetUserMessages, "/users/999/messages")
+		apitest.ExpectError(t, recorder, http.StatusNotFound, "USER_NOT_FOUND")
+	})
+	t.Run("user without messages", func(t *testing.T) {
+		recorder := apitest.Get(t, "/users/:id/messages", GetUserMessages, fmt.Sprintf("/users/%d/messages", user.ID))
+		apitest.ExpectEmptyPage(t, recorder, "-date")
+	})
+}
+
+func TestGetUnreadMessages(t *testing.T) {
+	apitest.OpenDatabase(t, &models.User{}, &models.Message{})
+	user := models.User{Name: "Alice", Email: "alice@example.com"}
+	apitest.Create(t, &user)
+
+	t.Run("unknown user", func(t *testing.T) {
+		recorder := apitest.Get(t, "/users/:id/messages/unread", GetUnreadMessages, "/users/999/messages/unread")
+		apitest.ExpectError(t, recorder, http.StatusNotFound, "USER_NOT_FOUND")
+	})
+	t.Run("user without unread messages", func(t *testing.T) {
+		recorder := apitest.Get(t, "/users/:id/messages/unread", GetUnreadMessages, fmt.Sprintf("/users/%d/messages/unread", user.ID))
+		apitest.ExpectEmptyPage(t, recorder, "date")
+	})
+}
@@ -0,0 +1,458 @@
+package offline
+
+import (
+	"errors"
+	"example/hello/common"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/requests"
+	"example/hello/response"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Durée de validité par défaut d'une autorisation hors ligne
+const defaultAllowanceDuration = 4 * time.Hour
+
+// offlineConsentTTL : le QR code d'accord du client doit être scanné au stand dans ce délai
+const offlineConsentTTL = 5 * time.Minute
+
+// offlineConsentPurpose lie le QR code d'accord du client au stand et au plafond acceptés
+func offlineConsentPurpose(standID uint, plafond int64) string {
+	return fmt.Sprintf("OFFLINE_CONSENT:%d:%d", standID, plafond)
+}
+
+// CreateOfflineConsent godoc
+// @Summary Authorise an offline spending allowance
+// @Description Issue, for the connected customer, a short-lived code to show as a QR code at a stand. The stand scans it to issue an offline allowance for this customer, this stand and this maximum amount (200 jetons at most); the code expires after 5 minutes and can be used once
+// @Tags Offline
+// @Accept json
+// @Produce json
+// @Param id path int true "Stand ID"
+// @Param request body requests.OfflineConsentRequest true "Maximum amount"
+// @Success 201 {object} response.OfflineConsentResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/stands/{id}/offline/consents [post]
+func CreateOfflineConsent(c *gin.Context) {
+	standID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var req requests.OfflineConsentRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var stand models.Stand
+	if err := initializers.DB.First(&stand, standID).Error; err != nil {
+		response.Fail(c, response.ErrStandNotFound)
+		return
+	}
+
+	expiresAt := time.Now().Add(offlineConsentTTL)
+	code, err := common.NewSignedToken(offlineConsentPurpose(stand.ID, req.Plafond), c.GetUint("userID"), expiresAt)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to create offline consent"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.OfflineConsentResponse{
+		Code:      code,
+		StandID:   stand.ID,
+		Plafond:   req.Plafond,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// Motifs de rejet renvoyés pour chaque vente synchronisée
+const (
+	reasonInvalidAllowance   = "INVALID_ALLOWANCE"
+	reasonInvalidSignature   = "INVALID_SIGNATURE"
+	reasonAllowanceExpired   = "ALLOWANCE_EXPIRED"
+	reasonAllowanceExceeded  = "ALLOWANCE_EXCEEDED"
+	reasonUnknownStock       = "UNKNOWN_STOCK"
+	reasonStockExhausted     = "STOCK_EXHAUSTED"
+	reasonInsufficientSolde  = "INSUFFICIENT_BALANCE"
+	reasonInternalError      = "INTERNAL_ERROR"
+	offlineResultStatusError = "ERROR"
+)
+
+// rejection représente une vente refusée lors de la synchronisation
+type rejection string
+
+func (r rejection) Error() string {
+	return string(r)
+}
+
+// CreateOfflineAllowance godoc
+// @Summary Issue an offline spending allowance
+// @Description Issue a signed spending allowance so a stand terminal can charge a customer while offline. Only the stand holder, an organiser of its kermesse or an admin can issue it, and only with the code the customer got from /api/stands/{id}/offline/consents for this stand and amount. The amount is capped at 200 jetons, the customer's balance and their daily spending limit
+// @Tags Offline
+// @Accept json
+// @Produce json
+// @Param id path int true "Stand ID"
+// @Param request body requests.CreateOfflineAllowanceRequest true "Allowance data"
+// @Success 201 {object} response.OfflineAllowanceResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/stands/{id}/offline/allowances [post]
+func CreateOfflineAllowance(c *gin.Context) {
+	standID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var req requests.CreateOfflineAllowanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var stand models.Stand
+	if err := initializers.DB.First(&stand, standID).Error; err != nil {
+		response.Fail(c, response.ErrStandNotFound)
+		return
+	}
+	if !checkStandManager(c, stand) {
+		return
+	}
+
+	// Le client a accepté cette autorisation en présentant son QR code : même stand, même plafond, une seule fois
+	consentUserID, err := common.ParseSignedToken(offlineConsentPurpose(stand.ID, req.Plafond), req.Consent)
+	if err != nil || consentUserID != req.UserID {
+		response.Fail(c, response.ErrInvalidOfflineConsent)
+		return
+	}
+	consentHash := common.HashToken(req.Consent)
+	var used int64
+	if err := initializers.DB.Model(&models.OfflineAllowance{}).Where("consent_hash = ?", consentHash).Count(&used).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to create offline allowance"))
+		return
+	}
+	if used > 0 {
+		response.Fail(c, response.ErrOfflineConsentUsed)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, req.UserID).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+
+	// Le plafond ne peut pas dépasser le solde disponible au moment de l'émission
+	if req.Plafond > user.SoldeJetons {
+		response.Fail(c, response.ErrInsufficientBalance)
+		return
+	}
+
+	// Ni ce qui reste du plafond journalier fixé par les tuteurs, autorisations en cours comprises
+	remaining, limited, err := services.RemainingSpendingAllowance(initializers.DB, user.ID)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to check spending limit"))
+		return
+	}
+	var outstanding int64
+	if limited {
+		if err := initializers.DB.Model(&models.OfflineAllowance{}).
+			Select("COALESCE(SUM(plafond - depense), 0)").
+			Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+			Scan(&outstanding).Error; err != nil {
+			response.Fail(c, response.Internal("Failed to check spending limit"))
+			return
+		}
+	}
+	if limited && req.Plafond > remaining-outstanding {
+		response.Fail(c, response.ErrDailyLimitExceeded)
+		return
+	}
+
+	duree := defaultAllowanceDuration
+	if req.DureeMinutes > 0 {
+		duree = time.Duration(req.DureeMinutes) * time.Minute
+	}
+
+	allowance := models.OfflineAllowance{
+		UserID:      user.ID,
+		StandID:     stand.ID,
+		Plafond:     req.Plafond,
+		ConsentHash: &consentHash,
+		// Tronquée à la seconde pour que la signature reste vérifiable côté terminal
+		ExpiresAt: time.Now().Add(duree).Truncate(time.Second),
+	}
+
+	if err := initializers.DB.Create(&allowance).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to create offline allowance"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.OfflineAllowanceResponse{
+		ID:        allowance.ID,
+		UserID:    allowance.UserID,
+		StandID:   allowance.StandID,
+		Plafond:   allowance.Plafond,
+		ExpiresAt: allowance.ExpiresAt,
+		Signature: common.SignOfflineAllowance(allowance.ID, allowance.UserID, allowance.StandID, allowance.Plafond, allowance.ExpiresAt),
+		SaleKey:   common.OfflineSaleKey(allowance.ID),
+	})
+}
+
+// checkStandManager vérifie que l'appelant tient le stand ou organise sa kermesse, sauf pour un administrateur
+func checkStandManager(c *gin.Context, stand models.Stand) bool {
+	roles, _ := c.Get("userRoles")
+	names, _ := roles.([]string)
+	for _, name := range names {
+		if name == models.RoleAdmin.String() {
+			return true
+		}
+	}
+	allowed, err := services.CanManageStand(initializers.DB, c.GetUint("userID"), stand)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to check permissions"))
+		return false
+	}
+	if !allowed {
+		response.Fail(c, response.ErrNotStandManager)
+		return false
+	}
+	return true
+}
+
+// SyncOfflineSales godoc
+// @Summary Synchronise offline sales
+// @Description Upload a batch of sales recorded offline by a stand terminal and get a per-item result. Only the stand holder, an organiser of its kermesse or an admin can upload them
+// @Tags Offline
+// @Accept json
+// @Produce json
+// @Param id path int true "Stand ID"
+// @Param request body requests.OfflineSyncRequest true "Offline sales"
+// @Success 200 {object} response.OfflineSyncResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/stands/{id}/offline/sync [post]
+func SyncOfflineSales(c *gin.Context) {
+	standID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var req requests.OfflineSyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var stand models.Stand
+	if err := initializers.DB.First(&stand, standID).Error; err != nil {
+		response.Fail(c, response.ErrStandNotFound)
+		return
+	}
+	if !checkStandManager(c, stand) {
+		return
+	}
+
+	// Les ventes sont appliquées dans l'ordre où elles ont été enregistrées sur le terminal
+	sales := req.Sales
+	sort.SliceStable(sales, func(i, j int) bool {
+		return sales[i].RecordedAt.Before(sales[j].RecordedAt)
+	})
+
+	result := response.OfflineSyncResponse{Results: make([]response.OfflineSaleResult, 0, len(sales))}
+	for _, sale := range sales {
+		item := syncOfflineSale(stand.ID, sale)
+		switch item.Statut {
+		case string(models.OfflineSaleApplied):
+			result.Applied++
+		case string(models.OfflineSaleRejected):
+			result.Rejected++
+		}
+		result.Results = append(result.Results, item)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// syncOfflineSale applique une vente dans sa propre transaction, de sorte qu'un conflit
+// n'annule pas les autres ventes du lot
+func syncOfflineSale(standID uint, req requests.OfflineSaleRequest) response.OfflineSaleResult {
+	// Une vente déjà synchronisée renvoie le résultat enregistré
+	var existing models.OfflineSale
+	err := initializers.DB.Where("stand_id = ? AND client_ref = ?", standID, req.ClientRef).First(&existing).Error
+	if err == nil {
+		return offlineSaleResult(existing, true)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("Erreur lors de la recherche de la vente hors ligne:", err)
+		return response.OfflineSaleResult{ClientRef: req.ClientRef, Statut: offlineResultStatusError, Motif: reasonInternalError}
+	}
+
+	var sale *models.OfflineSale
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var applyErr error
+		sale, applyErr = applyOfflineSale(tx, standID, req)
+		return applyErr
+	})
+
+	var rejected rejection
+	if errors.As(err, &rejected) {
+		sale = &models.OfflineSale{
+			StandID:     standID,
+			ClientRef:   req.ClientRef,
+			AllowanceID: req.AllowanceID,
+			UserID:      req.UserID,
+			StockID:     req.StockID,
+			Quantite:    req.Quantite,
+			RecordedAt:  req.RecordedAt,
+			SyncedAt:    time.Now(),
+			Statut:      models.OfflineSaleRejected,
+			Motif:       string(rejected),
+		}
+		if err := initializers.DB.Create(sale).Error; err != nil {
+			log.Println("Erreur lors de l'enregistrement de la vente rejetée:", err)
+		}
+		return offlineSaleResult(*sale, false)
+	}
+	if err != nil {
+		// Erreur technique : rien n'est enregistré pour que le terminal puisse renvoyer la vente
+		log.Println("Erreur lors de l'application de la vente hors ligne:", err)
+		return response.OfflineSaleResult{ClientRef: req.ClientRef, Statut: offlineResultStatusError, Motif: reasonInternalError}
+	}
+
+	return offlineSaleResult(*sale, false)
+}
+
+func applyOfflineSale(tx *gorm.DB, standID uint, req requests.OfflineSaleRequest) (*models.OfflineSale, error) {
+	var allowance models.OfflineAllowance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&allowance, req.AllowanceID).Error; err != nil {
+		return nil, rejection(reasonInvalidAllowance)
+	}
+
+	if allowance.StandID != standID || allowance.UserID != req.UserID || allowance.RevokedAt != nil {
+		return nil, rejection(reasonInvalidAllowance)
+	}
+
+	expectedAllowance := common.SignOfflineAllowance(allowance.ID, allowance.UserID, allowance.StandID, allowance.Plafond, allowance.ExpiresAt)
+	if !common.VerifySignature(expectedAllowance, req.AllowanceSignature) {
+		return nil, rejection(reasonInvalidSignature)
+	}
+
+	expectedSale := common.SignOfflineSale(common.OfflineSaleKey(allowance.ID), req.ClientRef, allowance.ID, req.UserID, req.StockID, req.Quantite, req.RecordedAt)
+	if !common.VerifySignature(expectedSale, req.Signature) {
+		return nil, rejection(reasonInvalidSignature)
+	}
+
+	if req.RecordedAt.After(allowance.ExpiresAt) {
+		return nil, rejection(reasonAllowanceExpired)
+	}
+
+	var stock models.Stock
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&stock, req.StockID).Error; err != nil || stock.StandID != standID {
+		return nil, rejection(reasonUnknownStock)
+	}
+
+	if stock.Quantite < req.Quantite {
+		return nil, rejection(reasonStockExhausted)
+	}
+
+	montant := int64(stock.PrixEnJetons * req.Quantite)
+	if allowance.Depense+montant > allowance.Plafond {
+		return nil, rejection(reasonAllowanceExceeded)
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, req.UserID).Error; err != nil {
+		return nil, rejection(reasonInvalidAllowance)
+	}
+
+	if user.SoldeJetons < montant {
+		return nil, rejection(reasonInsufficientSolde)
+	}
+
+	user.SoldeJetons -= montant
+	if err := tx.Save(&user).Error; err != nil {
+		return nil, err
+	}
+
+	stock.Quantite -= req.Quantite
+	if err := tx.Save(&stock).Error; err != nil {
+		return nil, err
+	}
+
+	allowance.Depense += montant
+	if err := tx.Save(&allowance).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&models.Stand{}).Where("id = ?", standID).
+		UpdateColumn("jetons_collectes", gorm.Expr("jetons_collectes + ?", montant)).Error; err != nil {
+		return nil, err
+	}
+
+	transaction := models.JetonTransaction{
+		UserID:      user.ID,
+		Montant:     montant,
+		Type:        models.TransactionTypeAchat,
+		Description: fmt.Sprintf("Achat hors ligne de %d %s au stand %d", req.Quantite, stock.NomProduit, standID),
+		StandID:     &standID,
+		Date:        req.RecordedAt,
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return nil, err
+	}
+
+	sale := models.OfflineSale{
+		StandID:       standID,
+		ClientRef:     req.ClientRef,
+		AllowanceID:   allowance.ID,
+		UserID:        user.ID,
+		StockID:       stock.ID,
+		Quantite:      req.Quantite,
+		Montant:       montant,
+		RecordedAt:    req.RecordedAt,
+		SyncedAt:      time.Now(),
+		Statut:        models.OfflineSaleApplied,
+		TransactionID: &transaction.ID,
+	}
+	if err := tx.Create(&sale).Error; err != nil {
+		return nil, err
+	}
+
+	return &sale, nil
+}
+
+func offlineSaleResult(sale models.OfflineSale, duplicate bool) response.OfflineSaleResult {
+	return response.OfflineSaleResult{
+		ClientRef:     sale.ClientRef,
+		Statut:        string(sale.Statut),
+		Motif:         sale.Motif,
+		Montant:       sale.Montant,
+		TransactionID: sale.TransactionID,
+		Duplicate:     duplicate,
+	}
+}
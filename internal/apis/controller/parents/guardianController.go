@@ -0,0 +1,392 @@
+package parents
+
+import (
+	"errors"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/requests"
+	"example/hello/response"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// authorizeGuardian laisse passer les administrateurs et organisateurs ; un parent doit être tuteur
+// de l'élève avec une permission acceptée par allowed
+func authorizeGuardian(c *gin.Context, eleveID uint, allowed func(models.GuardianPermission) bool) bool {
+	switch c.GetString("userRole") {
+	case models.RoleAdmin.String(), models.RoleOrganisateur.String():
+		return true
+	}
+
+	userID, _ := c.Get("userID")
+	err := services.RequireGuardian(initializers.DB, userID, eleveID, allowed)
+	switch {
+	case errors.Is(err, services.ErrNotGuardian):
+		response.Fail(c, response.ErrNotGuardian)
+		return false
+	case errors.Is(err, services.ErrGuardianPermission):
+		response.Fail(c, response.ErrGuardianPermission)
+		return false
+	case err != nil:
+		response.Fail(c, response.Internal("Failed to check guardian permission"))
+		return false
+	}
+	return true
+}
+
+func loadEleve(c *gin.Context) (models.Eleve, bool) {
+	eleveID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return models.Eleve{}, false
+	}
+
+	var eleve models.Eleve
+	if err := initializers.DB.First(&eleve, eleveID).Error; err != nil {
+		response.Fail(c, response.ErrChildNotFound)
+		return models.Eleve{}, false
+	}
+	return eleve, true
+}
+
+func parseGuardianPermission(permission string) (models.GuardianPermission, bool) {
+	p := models.GuardianPermission(strings.ToUpper(strings.TrimSpace(permission)))
+	return p, p.Valid()
+}
+
+func guardianError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotGuardian):
+		response.Fail(c, response.ErrGuardianNotFound)
+	case errors.Is(err, services.ErrLastFullGuardian):
+		response.Fail(c, response.ErrLastFullGuardian)
+	default:
+		response.Fail(c, response.Internal("Failed to update guardians"))
+	}
+}
+
+// GetChildGuardians godoc
+// @Summary List the guardians of a child
+// @Description List every guardian of a child with their permission (FULL, FUND_ONLY, VIEW_ONLY)
+// @Tags Parents
+// @Produce json
+// @Param id path int true "Eleve ID"
+// @Success 200 {array} response.GuardianResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/children/{id}/guardians [get]
+func GetChildGuardians(c *gin.Context) {
+	eleve, ok := loadEleve(c)
+	if !ok {
+		return
+	}
+	// Tout tuteur, quelle que soit sa permission, peut voir qui suit l'enfant
+	if !authorizeGuardian(c, eleve.ID, models.GuardianPermission.Valid) {
+		return
+	}
+
+	var guardianships []models.Guardianship
+	if err := initializers.DB.Where("eleve_id = ?", eleve.ID).Preload("Parent.User").Order("created_at").Find(&guardianships).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve guardians"))
+		return
+	}
+
+	guardians := make([]response.GuardianResponse, 0, len(guardianships))
+	for _, guardianship := range guardianships {
+		guardians = append(guardians, response.GuardianResponse{
+			ParentID:   guardianship.ParentID,
+			UserID:     guardianship.Parent.UserID,
+			Name:       guardianship.Parent.User.Name,
+			Email:      guardianship.Parent.User.Email,
+			Permission: string(guardianship.Permission),
+			CreatedAt:  guardianship.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, guardians)
+}
+
+// AddChildGuardian godoc
+// @Summary Add a guardian to a child
+// @Description Give another account (separated parent, grandparent...) access to a child. The account receives the PARENT role if needed
+// @Tags Parents
+// @Accept json
+// @Produce json
+// @Param id path int true "Eleve ID"
+// @Param request body requests.GuardianRequest true "Guardian email and permission"
+// @Success 201 {object} response.GuardianResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/children/{id}/guardians [post]
+func AddChildGuardian(c *gin.Context) {
+	eleve, ok := loadEleve(c)
+	if !ok {
+		return
+	}
+	if !authorizeGuardian(c, eleve.ID, models.GuardianPermission.CanManage) {
+		return
+	}
+
+	var req requests.GuardianRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	permission, valid := parseGuardianPermission(req.Permission)
+	if !valid {
+		response.Fail(c, response.ErrInvalidPermission)
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.Where("LOWER(email) = ?", strings.ToLower(req.Email)).First(&user).Error; err != nil {
+		response.Fail(c, response.ErrUserNotFound.WithMessage("No account found for this email"))
+		return
+	}
+	if user.ID == eleve.UserID {
+		response.Fail(c, response.ErrInvalidGuardian)
+		return
+	}
+
+	var parent models.Parent
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.GrantRole(tx, user.ID, user.Roles); err != nil {
+			return err
+		}
+		if err := services.GrantRole(tx, user.ID, models.RoleParent); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).First(&parent).Error; err != nil {
+			return err
+		}
+		if err := services.SetGuardian(tx, eleve.ID, parent.ID, permission); err != nil {
+			return err
+		}
+		if eleve.ParentID == nil {
+			return tx.Model(&eleve).Update("parent_id", parent.ID).Error
+		}
+		return nil
+	})
+	if err != nil {
+		guardianError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.GuardianResponse{
+		ParentID:   parent.ID,
+		UserID:     user.ID,
+		Name:       user.Name,
+		Email:      user.Email,
+		Permission: string(permission),
+	})
+}
+
+// UpdateChildGuardian godoc
+// @Summary Change the permission of a guardian
+// @Description Change the permission of one of the child's guardians. A child always keeps at least one FULL guardian
+// @Tags Parents
+// @Accept json
+// @Produce json
+// @Param id path int true "Eleve ID"
+// @Param parentId path int true "Parent ID"
+// @Param request body requests.GuardianPermissionRequest true "New permission"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/children/{id}/guardians/{parentId} [put]
+func UpdateChildGuardian(c *gin.Context) {
+	eleve, ok := loadEleve(c)
+	if !ok {
+		return
+	}
+	if !authorizeGuardian(c, eleve.ID, models.GuardianPermission.CanManage) {
+		return
+	}
+
+	parentID, err := strconv.Atoi(c.Param("parentId"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var req requests.GuardianPermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	permission, valid := parseGuardianPermission(req.Permission)
+	if !valid {
+		response.Fail(c, response.ErrInvalidPermission)
+		return
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Guardianship{}).Where("eleve_id = ? AND parent_id = ?", eleve.ID, parentID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return services.ErrNotGuardian
+		}
+		return services.SetGuardian(tx, eleve.ID, uint(parentID), permission)
+	})
+	if err != nil {
+		guardianError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
+
+// RemoveChildGuardian godoc
+// @Summary Remove a guardian from a child
+// @Description Remove a guardian. FULL guardians can remove anyone; any guardian can remove themselves
+// @Tags Parents
+// @Produce json
+// @Param id path int true "Eleve ID"
+// @Param parentId path int true "Parent ID"
+// @Success 200 {object} response.SuccessResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/children/{id}/guardians/{parentId} [delete]
+func RemoveChildGuardian(c *gin.Context) {
+	eleve, ok := loadEleve(c)
+	if !ok {
+		return
+	}
+
+	parentID, err := strconv.Atoi(c.Param("parentId"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	var self models.Parent
+	isSelf := initializers.DB.Where("user_id = ?", userID).First(&self).Error == nil && self.ID == uint(parentID)
+	if !isSelf && !authorizeGuardian(c, eleve.ID, models.GuardianPermission.CanManage) {
+		return
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		return services.RemoveGuardian(tx, eleve.ID, uint(parentID))
+	})
+	if err != nil {
+		guardianError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
+
+// GetChildSpendingLimit godoc
+// @Summary Get the daily spending limit of a child
+// @Description Return the daily jeton spending limit of a child, what was spent today and what remains
+// @Tags Parents
+// @Produce json
+// @Param id path int true "Eleve ID"
+// @Success 200 {object} response.SpendingLimitResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/children/{id}/spending-limit [get]
+func GetChildSpendingLimit(c *gin.Context) {
+	eleve, ok := loadEleve(c)
+	if !ok {
+		return
+	}
+	if !authorizeGuardian(c, eleve.ID, models.GuardianPermission.CanView) {
+		return
+	}
+
+	respondSpendingLimit(c, eleve)
+}
+
+// SetChildSpendingLimit godoc
+// @Summary Set the daily spending limit of a child
+// @Description Set or remove (null) the daily jeton spending limit of a child. Only FULL guardians can change it
+// @Tags Parents
+// @Accept json
+// @Produce json
+// @Param id path int true "Eleve ID"
+// @Param request body requests.SpendingLimitRequest true "Daily limit in jetons"
+// @Success 200 {object} response.SpendingLimitResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/children/{id}/spending-limit [put]
+func SetChildSpendingLimit(c *gin.Context) {
+	eleve, ok := loadEleve(c)
+	if !ok {
+		return
+	}
+	if !authorizeGuardian(c, eleve.ID, models.GuardianPermission.CanManage) {
+		return
+	}
+
+	var req requests.SpendingLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	if err := initializers.DB.Model(&eleve).Update("plafond_journalier", req.PlafondJournalier).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to update spending limit"))
+		return
+	}
+	eleve.PlafondJournalier = req.PlafondJournalier
+
+	respondSpendingLimit(c, eleve)
+}
+
+func respondSpendingLimit(c *gin.Context, eleve models.Eleve) {
+	spent, err := services.SpentToday(initializers.DB, eleve.UserID)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to compute spending"))
+		return
+	}
+
+	result := response.SpendingLimitResponse{
+		EleveID:           eleve.ID,
+		PlafondJournalier: eleve.PlafondJournalier,
+		DepenseJour:       spent,
+	}
+	if eleve.PlafondJournalier != nil {
+		remaining := *eleve.PlafondJournalier - spent
+		if remaining < 0 {
+			remaining = 0
+		}
+		result.Restant = &remaining
+	}
+
+	c.JSON(http.StatusOK, result)
+}
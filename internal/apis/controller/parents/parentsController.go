@@ -1,6 +1,7 @@
 package parents
 
 import (
+	"example/hello/internal/apis/services"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
 	"example/hello/response"
@@ -27,7 +28,7 @@ func GetChildren(c *gin.Context) {
 	id := c.Param("id")
 	var enfant models.Eleve
 	if err := initializers.DB.Preload("User").First(&enfant, id).Error; err != nil {
-        c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Enfant or associated user not found"})
+        response.Fail(c, response.ErrChildNotFound)
         return
 	}
 
@@ -54,9 +55,9 @@ func GetParentId(c *gin.Context) {
             Preload("Enfants.User").  // Précharge les informations utilisateur de chaque enfant
             First(&parent, parentID).Error; err != nil {
             if errors.Is(err, gorm.ErrRecordNotFound) {
-                c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Parent not found"})
+                response.Fail(c, response.ErrParentNotFound)
             } else {
-                c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Error retrieving parent"})
+                response.Fail(c, response.Internal("Error retrieving parent"))
             }
             return
         }
@@ -133,12 +134,22 @@ func GetChildrenForParent(c *gin.Context) {
     parentID, _ := strconv.Atoi(c.Param("id"))
 
     var parent models.Parent
-    if err := initializers.DB.Preload("Enfants").First(&parent, parentID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Parent not found"})
+    if err := initializers.DB.First(&parent, parentID).Error; err != nil {
+        response.Fail(c, response.ErrParentNotFound)
         return
     }
 
-    c.JSON(http.StatusOK, parent.Enfants)
+    // Les enfants suivis comme tuteur, y compris ceux rattachés d'abord à un autre parent
+    enfants := []models.Eleve{}
+    if err := initializers.DB.Joins("JOIN guardianships ON guardianships.eleve_id = eleves.id").
+        Where("guardianships.parent_id = ?", parent.ID).
+        Order("eleves.id").
+        Find(&enfants).Error; err != nil {
+        response.Fail(c, response.Internal("Failed to fetch children"))
+        return
+    }
+
+    c.JSON(http.StatusOK, enfants)
 }
 
 
@@ -161,14 +172,19 @@ func GetChildInteractions(c *gin.Context) {
     // D'abord, récupérez l'élève pour obtenir son UserID
     var eleve models.Eleve
     if err := initializers.DB.First(&eleve, childID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Eleve not found"})
+        response.Fail(c, response.ErrChildNotFound)
+        return
+    }
+
+    // Un tuteur qui peut seulement financer n'a pas accès à l'historique
+    if !authorizeGuardian(c, eleve.ID, models.GuardianPermission.CanView) {
         return
     }
 
     // Ensuite, utilisez le UserID de l'élève pour rechercher les transactions
     var interactions []models.JetonTransaction
     if err := initializers.DB.Where("user_id = ?", eleve.UserID).Find(&interactions).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch interactions"})
+        response.Fail(c, response.Internal("Failed to fetch interactions"))
         return
     }
 
@@ -193,16 +209,32 @@ func GetAllChildrenInteractionsForParent(c *gin.Context) {
     parentID, _ := strconv.Atoi(c.Param("id"))
 
     var parent models.Parent
-    if err := initializers.DB.Preload("Enfants").First(&parent, parentID).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Parent not found"})
+    if err := initializers.DB.First(&parent, parentID).Error; err != nil {
+        response.Fail(c, response.ErrParentNotFound)
+        return
+    }
+
+    // Un parent ne consulte que son propre suivi
+    if userID, _ := c.Get("userID"); c.GetString("userRole") == models.RoleParent.String() && userID != parent.UserID {
+        response.Fail(c, response.ErrForbidden)
+        return
+    }
+
+    guardianships, err := services.GuardedChildren(initializers.DB, parent.ID)
+    if err != nil {
+        response.Fail(c, response.Internal("Failed to fetch children"))
         return
     }
 
     var allInteractions []models.JetonTransaction
-    for _, child := range parent.Enfants {
+    for _, guardianship := range guardianships {
+        // Les enfants suivis en financement seul sont exclus de l'historique
+        if !guardianship.Permission.CanView() {
+            continue
+        }
         var interactions []models.JetonTransaction
-        if err := initializers.DB.Where("user_id = ?", child.ID).Find(&interactions).Error; err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch interactions"})
+        if err := initializers.DB.Where("user_id = ?", guardianship.Eleve.UserID).Find(&interactions).Error; err != nil {
+            response.Fail(c, response.Internal("Failed to fetch interactions"))
             return
         }
         allInteractions = append(allInteractions, interactions...)
@@ -1,16 +1,18 @@
 package stands
 
 import (
-	"fmt"
-	"net/http"
-	"strconv"
-    "gorm.io/gorm"
+	"errors"
+	"example/hello/i18n"
+	"example/hello/internal/apis/pagination"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
 	"example/hello/requests"
 	"example/hello/response"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"errors"
+    "gorm.io/gorm"
+	"net/http"
+	"strconv"
 )
 
 func stringToTypeStand(typeStandStr string) (models.StandType, error) {
@@ -28,12 +30,12 @@ func stringToTypeStand(typeStandStr string) (models.StandType, error) {
 
 // CreateStand godoc
 // @Summary Create a new stand
-// @Description Create a new stand for a kermesse
+// @Description Create a new stand for a kermesse. Jeton and point counters start at zero; unknown fields are rejected
 // @Tags Stand
 // @Accept json
 // @Produce json
-// @Param stand body models.Stand true "Stand object"
-// @Success 201 {object} models.Stand
+// @Param stand body requests.CreateStandRequest true "Stand data"
+// @Success 201 {object} response.StandResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
@@ -41,14 +43,14 @@ func stringToTypeStand(typeStandStr string) (models.StandType, error) {
 // @Router /api/stands [post]
 func CreateStand(c *gin.Context) {
 	var req requests.CreateStandRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format: " + err.Error()})
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
 	standType, err := stringToTypeStand(req.Type)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		response.Fail(c, response.InvalidField("type", "oneof", "Unknown stand type"))
 		return
 	}
 
@@ -59,12 +61,10 @@ func CreateStand(c *gin.Context) {
 		TeneurID:        req.TeneurID,
 		PositionX:       req.PositionX,
 		PositionY:       req.PositionY,
-		JetonsCollectes: req.JetonsCollectes,
-		PointsAttribues: req.PointsAttribues,
 	}
 
 	if err := initializers.DB.Create(&stand).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create stand: " + err.Error()})
+		response.Fail(c, response.Internal("Failed to create stand"))
 		return
 	}
 
@@ -97,61 +97,138 @@ func GetStand(c *gin.Context) {
 	id := c.Param("id")
 	var stand models.Stand
 	if err := initializers.DB.Preload("Stocks").First(&stand, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Stock not found"})
+		response.Fail(c, response.ErrStockNotFound)
 		return
 	}
 	c.JSON(http.StatusOK, stand)
 }
 
+var standList = pagination.Spec{
+	Model: &models.Stand{},
+	Filters: []pagination.Filter{
+		pagination.Equal("kermesse_id", "kermesse_id", pagination.ID),
+		pagination.Equal("teneur_id", "teneur_id", pagination.ID),
+		pagination.Equal("type", "type", pagination.OneOf(map[string]interface{}{
+			"NOURRITURE": models.StandNourriture,
+			"BOISSON":    models.StandBoisson,
+			"ACTIVITES":  models.StandActivite,
+		})),
+	},
+	Sorts: map[string]string{
+		"id":               "id",
+		"nom":              "nom",
+		"jetons_collectes": "jetons_collectes",
+		"points_attribues": "points_attribues",
+	},
+	DefaultSort: "id",
+}
+
 // GetStands godoc
 // @Summary Get all stands
-// @Description Retrieve a list of all stands
+// @Description Retrieve a page of stands with their stocks
 // @Tags Stand
 // @Produce json
-// @Success 200 {array} response.SuccessResponse
+// @Param kermesse_id query int false "Filter by kermesse"
+// @Param teneur_id query int false "Filter by stand holder"
+// @Param type query string false "Filter by type (NOURRITURE, BOISSON, ACTIVITES)"
+// @Param sort query string false "Sort key: id, nom, jetons_collectes or points_attribues, prefixed with - for descending order" default(id)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.Stand}
+// @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/stands [get]
 func GetAllStands(c *gin.Context) {
-    var stands []models.Stand
-    if err := initializers.DB.Preload("Stocks").Find(&stands).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Échec de la récupération des stands"})
-        return
-    }
+	params, err := pagination.Parse(c, standList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
 
-    c.JSON(http.StatusOK,stands)
+	var stands []models.Stand
+	page, err := pagination.Find(initializers.DB.Preload("Stocks"), params, &stands)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve stands"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.PageResponse{Data: stands, Page: page})
 }
 
 // UpdateStand godoc
 // @Summary Update a stand
-// @Description Update details of a specific stand
+// @Description Partially update a stand (JSON Merge Patch). jetons_collectes and points_attribues only change through sales and attributions; only organisers and admins can reassign the stand holder. Unknown fields are rejected
 // @Tags Stand
 // @Accept json
 // @Produce json
 // @Param id path int true "Stand ID"
-// @Param stand body models.Stand true "Updated stand object"
-// @Success 200 {object} models.Stand
+// @Param stand body requests.UpdateStandRequest true "Fields to update"
+// @Success 200 {object} response.StandResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/stands/{id} [put]
+// @Router /api/stands/{id} [patch]
 func UpdateStand(c *gin.Context) {
 	id := c.Param("id")
 	var stand models.Stand
 	if err := initializers.DB.First(&stand, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Stand not found"})
+		response.Fail(c, response.ErrStandNotFound)
 		return
 	}
 
-	if err := c.ShouldBindJSON(&stand); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+	var req requests.UpdateStandRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
-	initializers.DB.Save(&stand)
-	c.JSON(http.StatusOK, stand)
+	if req.TeneurID.Set && req.TeneurID.Value != stand.TeneurID {
+		if c.GetString("userRole") == models.RoleTeneurStand.String() {
+			response.Fail(c, response.ErrForbidden.WithMessage("Only organisers can reassign a stand"))
+			return
+		}
+		var teneur models.TeneurStand
+		if err := initializers.DB.First(&teneur, req.TeneurID.Value).Error; err != nil {
+			response.Fail(c, response.ErrTeneurNotFound)
+			return
+		}
+	}
+
+	if req.Type.Set {
+		standType, err := stringToTypeStand(req.Type.Value)
+		if err != nil {
+			response.Fail(c, response.InvalidField("type", "oneof", "Unknown stand type"))
+			return
+		}
+		stand.Type = standType
+	}
+	req.Nom.Apply(&stand.Nom)
+	req.TeneurID.Apply(&stand.TeneurID)
+	req.PositionX.Apply(&stand.PositionX)
+	req.PositionY.Apply(&stand.PositionY)
+
+	if err := initializers.DB.Model(&stand).Select("nom", "type", "teneur_id", "position_x", "position_y").Updates(&stand).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to update stand"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.StandResponse{
+		ID:              stand.ID,
+		Nom:             stand.Nom,
+		Type:            stand.Type.String(),
+		KermesseID:      stand.KermesseID,
+		TeneurID:        stand.TeneurID,
+		PositionX:       stand.PositionX,
+		PositionY:       stand.PositionY,
+		JetonsCollectes: stand.JetonsCollectes,
+		PointsAttribues: stand.PointsAttribues,
+	})
 }
 
 // DeleteStand godoc
@@ -168,7 +245,7 @@ func UpdateStand(c *gin.Context) {
 func DeleteStand(c *gin.Context) {
 	id := c.Param("id")
 	if err := initializers.DB.Delete(&models.Stand{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to delete stand"})
+		response.Fail(c, response.Internal("Failed to delete stand"))
 		return
 	}
 	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
@@ -181,7 +258,7 @@ func DeleteStand(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Stand ID"
-// @Param stock body models.Stock true "Stock object"
+// @Param stock body requests.CreateStockRequest true "Stock data"
 // @Success 201 {object} models.Stock
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
@@ -190,27 +267,29 @@ func DeleteStand(c *gin.Context) {
 // @Router /api/stands/{id}/stock [post]
 func ManageStock(c *gin.Context) {
 	standID := c.Param("id")
-	var stock models.Stock
-
-	// Bind the JSON input to the stock model
-	if err := c.ShouldBindJSON(&stock); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+	var req requests.CreateStockRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
 	// Parse the standID from string to uint64
 	parsedStandID, err := strconv.ParseUint(standID, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid stand ID"})
+		response.Fail(c, response.ErrInvalidID)
 		return
 	}
 
-	// Convert uint64 to uint and assign it to stock.StandID
-	stock.StandID = uint(parsedStandID)
+	stock := models.Stock{
+		StandID:      uint(parsedStandID),
+		NomProduit:   req.NomProduit,
+		Quantite:     req.Quantite,
+		PrixEnJetons: req.PrixEnJetons,
+	}
 
 	// Create the stock entry in the database
 	if err := initializers.DB.Create(&stock).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create/update stock for stand"})
+		response.Fail(c, response.Internal("Failed to create/update stock for stand"))
 		return
 	}
 
@@ -237,13 +316,13 @@ func CollectJetons(c *gin.Context) {
 		Montant int `json:"montant"`
 	}
 	if err := c.ShouldBindJSON(&jetonsData); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
 	var stand models.Stand
 	if err := initializers.DB.First(&stand, standID).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Stand not found"})
+		response.Fail(c, response.ErrStandNotFound)
 		return
 	}
 
@@ -251,7 +330,7 @@ func CollectJetons(c *gin.Context) {
 	initializers.DB.Save(&stand)
 
 	c.JSON(http.StatusOK, response.JetonCollectesResponse{
-		Message:     "Jetons collected successfully",
+		Message:     i18n.T(c, "Jetons collected successfully"),
 		TotalJetons: int64(stand.JetonsCollectes),
 	})
 }
@@ -277,7 +356,7 @@ func AttributePoints(c *gin.Context) {
         UserType string `json:"userType" binding:"required,oneof=parent student"`
     }
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+        response.Fail(c, response.BindingError(err))
         return
     }
 
@@ -293,17 +372,22 @@ func AttributePoints(c *gin.Context) {
     var userName string
     if err := updateUserPoints(tx, req.UserType, req.UserID, req.Points, &updatedPoints, &userName); err != nil {
         tx.Rollback()
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        var notFound response.APIError
+        if errors.As(err, &notFound) {
+            response.Fail(c, notFound)
+        } else {
+            response.Fail(c, response.Internal("Failed to update points"))
+        }
         return
     }
 
     if err := tx.Commit().Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+        response.Fail(c, response.Internal("Failed to commit transaction"))
         return
     }
 
     c.JSON(http.StatusOK, gin.H{
-        "message": "Points attributed successfully",
+        "message": i18n.T(c, "Points attributed successfully"),
         "userId": req.UserID,
         "userName": userName,
         "userType": req.UserType,
@@ -316,7 +400,7 @@ func updateUserPoints(tx *gorm.DB, userType string, userID uint, points int, upd
     if userType == "parent" {
         var parent models.Parent
         if err := tx.First(&parent, userID).Error; err != nil {
-            return errors.New("Parent not found")
+            return response.ErrParentNotFound
         }
         parent.PointsAccumules += points
         *updatedPoints = parent.PointsAccumules
@@ -325,7 +409,7 @@ func updateUserPoints(tx *gorm.DB, userType string, userID uint, points int, upd
     } else {
         var student models.Eleve
         if err := tx.First(&student, userID).Error; err != nil {
-            return errors.New("Student not found")
+            return response.ErrChildNotFound
         }
         student.PointsAccumules += points
         *updatedPoints = student.PointsAccumules
@@ -1,8 +1,10 @@
 package stock
 
 import (
+	"example/hello/internal/apis/pagination"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
+	"example/hello/requests"
 	"example/hello/response"
 	"net/http"
 	"strconv"
@@ -12,12 +14,12 @@ import (
 
 // CreateStock godoc
 // @Summary Create a new stock entry
-// @Description Create a new stock entry for a stand
+// @Description Create a new stock entry for a stand. Unknown fields are rejected
 // @Tags Stock
 // @Accept json
 // @Produce json
 // @Param id path int true "Stand ID"
-// @Param stock body models.Stock true "Stock data"
+// @Param stock body requests.CreateStockRequest true "Stock data"
 // @Success 201 {object} models.Stock
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
@@ -27,82 +29,135 @@ import (
 func CreateStock(c *gin.Context) {
 	standID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+		response.Fail(c, response.ErrInvalidRequest)
 		return
 	}
 
-	var stock models.Stock
-	if err := c.ShouldBindJSON(&stock); err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create stock"})
+	var req requests.CreateStockRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
-	stock.StandID = uint(standID)
+	stock := models.Stock{
+		StandID:      uint(standID),
+		NomProduit:   req.NomProduit,
+		Quantite:     req.Quantite,
+		PrixEnJetons: req.PrixEnJetons,
+	}
 
 	if err := initializers.DB.Create(&stock).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create stock entry"})
+		response.Fail(c, response.Internal("Failed to create stock entry"))
 		return
 	}
 
 	c.JSON(http.StatusCreated, stock)
 }
 
+var stockList = pagination.Spec{
+	Model: &models.Stock{},
+	Filters: []pagination.Filter{
+		pagination.Equal("stand_id", "stand_id", pagination.ID),
+		pagination.Where("kermesse_id", "stand_id IN (SELECT id FROM stands WHERE kermesse_id = ?)", pagination.ID),
+	},
+	Sorts: map[string]string{
+		"id":             "id",
+		"nom_produit":    "nom_produit",
+		"quantite":       "quantite",
+		"prix_en_jetons": "prix_en_jetons",
+	},
+	DefaultSort: "id",
+}
+
 // GetStocks godoc
 // @Summary Get all stocks
-// @Description Retrieve a list of all stocks
+// @Description Retrieve a page of stocks with their stand
 // @Tags Stock
 // @Produce json
-// @Success 200 {array} response.SuccessResponse
+// @Param stand_id query int false "Filter by stand"
+// @Param kermesse_id query int false "Filter by kermesse"
+// @Param sort query string false "Sort key: id, nom_produit, quantite or prix_en_jetons, prefixed with - for descending order" default(id)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.Stock}
+// @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/stocks [get]
 func GetAllStocks(c *gin.Context) {
-    var stocks []models.Stock
-    if err := initializers.DB.Preload("Stand").Find(&stocks).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Échec de la récupération des stocks"})
-        return
-    }
+	params, err := pagination.Parse(c, stockList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var stocks []models.Stock
+	page, err := pagination.Find(initializers.DB.Preload("Stand"), params, &stocks)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve stocks"))
+		return
+	}
 
-    c.JSON(http.StatusOK, stocks)
+	c.JSON(http.StatusOK, response.PageResponse{Data: stocks, Page: page})
+}
+
+var standStockList = pagination.Spec{
+	Model:       &models.Stock{},
+	Sorts:       stockList.Sorts,
+	DefaultSort: "id",
 }
 
 // GetStocksByStand godoc
 // @Summary Get all stock entries for a stand
-// @Description Retrieve all stock entries for a specific stand
+// @Description Retrieve a page of the stock entries of a stand. An existing stand without stock returns an empty page
 // @Tags Stock
 // @Produce json
 // @Param id path int true "Stand ID"
-// @Success 200 {array} models.Stock
+// @Param sort query string false "Sort key: id, nom_produit, quantite or prix_en_jetons, prefixed with - for descending order" default(id)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.Stock}
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/stands/{id}/stocks [get]
 func GetStocksByStand(c *gin.Context) {
 	standID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid stand ID"})
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	params, err := pagination.Parse(c, standStockList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	if !pagination.RequireParent(c, &models.Stand{}, standID, response.ErrStandNotFound) {
 		return
 	}
 
 	var stocks []models.Stock
-	if err := initializers.DB.Where("stand_id = ?", standID).Find(&stocks).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "No stocks found for this stand"})
+	page, err := pagination.Find(initializers.DB.Where("stand_id = ?", standID), params, &stocks)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve stocks"))
 		return
 	}
 
-	c.JSON(http.StatusOK, stocks)
+	c.JSON(http.StatusOK, response.PageResponse{Data: stocks, Page: page})
 }
 
 // UpdateStock godoc
 // @Summary Update a stock entry
-// @Description Update an existing stock entry
+// @Description Partially update a stock entry (JSON Merge Patch). The quantity only changes through /stocks/{id}/adjust; unknown fields are rejected
 // @Tags Stock
 // @Accept json
 // @Produce json
 // @Param id path int true "Stock ID"
-// @Param stock body models.Stock true "Updated stock data"
+// @Param stock body requests.UpdateStockRequest true "Fields to update"
 // @Success 200 {object} models.Stock
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
@@ -110,21 +165,26 @@ func GetStocksByStand(c *gin.Context) {
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/stocks/{id} [put]
+// @Router /api/stocks/{id} [patch]
 func UpdateStock(c *gin.Context) {
 	id := c.Param("id")
 	var stock models.Stock
 	if err := initializers.DB.First(&stock, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Stock not found"})
+		response.Fail(c, response.ErrStockNotFound)
 		return
 	}
 
-	if err := c.ShouldBindJSON(&stock); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+	var req requests.UpdateStockRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
-	if err := initializers.DB.Save(&stock).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to update stock"})
+	req.NomProduit.Apply(&stock.NomProduit)
+	req.PrixEnJetons.Apply(&stock.PrixEnJetons)
+
+	if err := initializers.DB.Model(&stock).Select("nom_produit", "prix_en_jetons").Updates(&stock).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to update stock"))
 		return
 	}
 
@@ -148,12 +208,12 @@ func DeleteStock(c *gin.Context) {
 	id := c.Param("id")
 	var stock models.Stock
 	if err := initializers.DB.First(&stock, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Stock not found"})
+		response.Fail(c, response.ErrStockNotFound)
 		return
 	}
 
 	if err := initializers.DB.Delete(&stock).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to delete stock"})
+		response.Fail(c, response.Internal("Failed to delete stock"))
 		return
 	}
 
@@ -179,7 +239,7 @@ func AdjustStock(c *gin.Context) {
 	id := c.Param("id")
 	var stock models.Stock
 	if err := initializers.DB.First(&stock, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Stock not found"})
+		response.Fail(c, response.ErrStockNotFound)
 		return
 	}
 
@@ -187,18 +247,18 @@ func AdjustStock(c *gin.Context) {
 		Quantite int `json:"quantite"`
 	}
 	if err := c.ShouldBindJSON(&adjustment); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
 	stock.Quantite += adjustment.Quantite
 	if stock.Quantite < 0 {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Stock quantity cannot be negative"})
+		response.Fail(c, response.ErrInvalidQuantity)
 		return
 	}
 
 	if err := initializers.DB.Save(&stock).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to ajust stock"})
+		response.Fail(c, response.Internal("Failed to adjust stock"))
 		return
 	}
 
@@ -1,22 +1,31 @@
 package tombola
 
 import (
+	"errors"
 	"example/hello/common"
+	"example/hello/i18n"
+	"example/hello/internal/apis/controller/messages"
+	"example/hello/internal/apis/pagination"
+	"example/hello/internal/apis/services"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
 	"example/hello/requests"
 	"example/hello/response"
-	"fmt"
-	"math/rand"
+	"log"
+    "gorm.io/gorm"
 	"net/http"
 	"strconv"
 	"time"
-	"errors"
-    "gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
 )
 
+// defaultPrixTicket est le prix en jetons d'un ticket lorsque l'organisateur n'en fixe pas
+const defaultPrixTicket = 2
+
+// defaultRevealDelay sépare les étapes de la diffusion en direct d'un tirage lorsque l'organisateur n'en fixe pas
+const defaultRevealDelay = 3 * time.Second
+
 // CreateTombola godoc
 // @Summary Create a new tombola
 // @Description Create a new tombola for a kermesse
@@ -24,8 +33,8 @@ import (
 // @Accept json
 // @Produce json
 // @Param id path int true "Kermesse ID"
-// @Param tombola body models.Tombola true "Tombola data"
-// @Success 201 {object} models.Tombola
+// @Param tombola body requests.CreateTombolaRequest true "Tombola data"
+// @Success 201 {object} response.TombolaResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
@@ -33,47 +42,115 @@ import (
 // @Router /api/kermesses/{id}/tombolas [post]
 func CreateTombola(c *gin.Context) {
 	var req requests.CreateTombolaRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format: " + err.Error()})
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	// L'empreinte de la graine du tirage est publiée dès la création, avant la vente des tickets
+	seed, commitment, err := common.NewDrawSeed()
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to create tombola"))
 		return
 	}
 
 	tombola := models.Tombola{
-		Nom:        req.Nom,
-		KermesseID: req.KermesseID,
+		Nom:               req.Nom,
+		KermesseID:        req.KermesseID,
+		Status:            models.TombolaOpen,
+		PrixTicket:        req.PrixTicket,
+		VenteDebut:        req.VenteDebut,
+		VenteFin:          req.VenteFin,
+		MaxTicketsParUser: req.MaxTicketsParUser,
+		MaxTickets:        req.MaxTickets,
+		RemiseQuantite:    req.RemiseQuantite,
+		RemisePourcent:    req.RemisePourcent,
+		RegleGain:         models.PrizeRule(req.RegleGain),
+		DateLimiteRetrait: req.DateLimiteRetrait,
+		DrawCommitment:    commitment,
+		DrawSeed:          seed,
+	}
+	if tombola.PrixTicket == 0 {
+		tombola.PrixTicket = defaultPrixTicket
+	}
+	if tombola.RegleGain == "" {
+		tombola.RegleGain = models.PrizeRuleNone
+	}
+	if err := checkTicketSettings(tombola); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
 	}
 
 	if err := initializers.DB.Create(&tombola).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create tombola: " + err.Error()})
+		response.Fail(c, response.Internal("Failed to create tombola"))
 		return
 	}
 
-	c.JSON(http.StatusCreated, response.StandResponse{
-		ID:         tombola.ID,
-		Nom:        tombola.Nom,
-		KermesseID: tombola.KermesseID,
-	})
+	c.JSON(http.StatusCreated, toTombolaResponse(tombola))
 
 }
 
+func toTombolaResponse(tombola models.Tombola) response.TombolaResponse {
+	return response.TombolaResponse{
+		ID:                tombola.ID,
+		Nom:               tombola.Nom,
+		KermesseID:        tombola.KermesseID,
+		Status:            string(tombola.Status),
+		PrixTicket:        tombola.PrixTicket,
+		VenteDebut:        tombola.VenteDebut,
+		VenteFin:          tombola.VenteFin,
+		MaxTicketsParUser: tombola.MaxTicketsParUser,
+		MaxTickets:        tombola.MaxTickets,
+		RemiseQuantite:    tombola.RemiseQuantite,
+		RemisePourcent:    tombola.RemisePourcent,
+		RegleGain:         string(tombola.RegleGain),
+		DateLimiteRetrait: tombola.DateLimiteRetrait,
+		JetonsCollectes:   tombola.JetonsCollectes,
+		CancelledAt:       tombola.CancelledAt,
+		DrawCommitment:    tombola.DrawCommitment,
+		DrawnAt:           tombola.DrawnAt,
+	}
+}
+
+var tombolaList = pagination.Spec{
+	Model: &models.Tombola{},
+	Filters: []pagination.Filter{
+		pagination.Equal("kermesse_id", "kermesse_id", pagination.ID),
+	},
+	Sorts:       map[string]string{"id": "id", "nom": "nom"},
+	DefaultSort: "id",
+}
+
 // GetTombolas godoc
 // @Summary Get all tombola
-// @Description Retrieve a list of all tombola
+// @Description Retrieve a page of tombolas with their lots. Tickets are listed by /api/tombolas/tickets?tombola_id=
 // @Tags Tombola
 // @Produce json
-// @Success 200 {array} response.SuccessResponse
+// @Param kermesse_id query int false "Filter by kermesse"
+// @Param sort query string false "Sort key: id or nom, prefixed with - for descending order" default(id)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.Tombola}
+// @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas [get]
 func GetAllTombolas(c *gin.Context) {
-    var tombolas []models.Tombola
-    if err := initializers.DB.Preload("Lots").Preload("Tickets").Find(&tombolas).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Échec de la récupération des tombolas"})
-        return
-    }
+	params, err := pagination.Parse(c, tombolaList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var tombolas []models.Tombola
+	page, err := pagination.Find(initializers.DB.Preload("Lots"), params, &tombolas)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve tombolas"))
+		return
+	}
 
-    c.JSON(http.StatusOK,tombolas)
+	c.JSON(http.StatusOK, response.PageResponse{Data: tombolas, Page: page})
 }
 
 // GetTombola godoc
@@ -93,9 +170,9 @@ func GetTombola(c *gin.Context) {
 
     if err := initializers.DB.First(&tombola, id).Error; err != nil {
         if errors.Is(err, gorm.ErrRecordNotFound) {
-            c.JSON(http.StatusNotFound, gin.H{"error": "Tombola not found"})
+            response.Fail(c, response.ErrTombolaNotFound)
         } else {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving tombola"})
+            response.Fail(c, response.Internal("Error retrieving tombola"))
         }
         return
     }
@@ -109,50 +186,198 @@ func GetTombola(c *gin.Context) {
     }
 
     // Chargez les lots et les tickets
-    initializers.DB.Model(&tombola).Association("Lots").Find(&tombola.Lots)
+    initializers.DB.Model(&tombola).Order("rang, id").Association("Lots").Find(&tombola.Lots)
     initializers.DB.Model(&tombola).Association("Tickets").Find(&tombola.Tickets)
 
     // Créez une réponse personnalisée
     response := gin.H{
-        "id":         tombola.ID,
-        "Nom":        tombola.Nom,
-        "KermesseID": tombola.KermesseID,
-        "Lots":       tombola.Lots,
-        "Tickets":    tombola.Tickets,
+        "id":              tombola.ID,
+        "Nom":             tombola.Nom,
+        "KermesseID":      tombola.KermesseID,
+        "Lots":            tombola.Lots,
+        "Tickets":         tombola.Tickets,
+        "status":          tombola.Status,
+        "prix_ticket":          tombola.PrixTicket,
+        "vente_debut":          tombola.VenteDebut,
+        "vente_fin":            tombola.VenteFin,
+        "max_tickets_par_user": tombola.MaxTicketsParUser,
+        "max_tickets":          tombola.MaxTickets,
+        "remise_quantite":      tombola.RemiseQuantite,
+        "remise_pourcent":      tombola.RemisePourcent,
+        "regle_gain":           tombola.RegleGain,
+        "date_limite_retrait":  tombola.DateLimiteRetrait,
+        "jetons_collectes":     tombola.JetonsCollectes,
+        "cancelled_at":         tombola.CancelledAt,
+        "draw_commitment": tombola.DrawCommitment,
+        "drawn_at":        tombola.DrawnAt,
     }
 
     c.JSON(http.StatusOK, response)
 }
 
-// generateTicketNumber génère un numéro de ticket unique
-func generateTicketNumber() (string, error) {
-	// Préfixe pour indiquer que c'est un ticket
-	prefix := "T-"
+// UpdateTombola godoc
+// @Summary Update a tombola
+// @Description Partially update a tombola (JSON Merge Patch): name, ticket price, sales window, caps, bulk discount, prize rule (fixed once drawn) and prize claim deadline. Ticket price, caps and bulk discount are fixed once a ticket has been sold; they and the sales window are fixed once the tombola is drawn or cancelled. null clears a date or a cap; unknown fields are rejected
+// @Tags Tombola
+// @Accept json
+// @Produce json
+// @Param id path int true "Tombola ID"
+// @Param tombola body requests.UpdateTombolaRequest true "Fields to update"
+// @Success 200 {object} models.Tombola
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/tombolas/{id} [put]
+// @Router /api/tombolas/{id} [patch]
+func UpdateTombola(c *gin.Context) {
+	var tombola models.Tombola
+	if err := initializers.DB.First(&tombola, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrTombolaNotFound)
+		} else {
+			response.Fail(c, response.Internal("Error retrieving tombola"))
+		}
+		return
+	}
+	var req requests.UpdateTombolaRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	before := tombola
+	req.Nom.Apply(&tombola.Nom)
+	req.PrixTicket.Apply(&tombola.PrixTicket)
+	req.VenteDebut.Apply(&tombola.VenteDebut)
+	req.VenteFin.Apply(&tombola.VenteFin)
+	req.MaxTicketsParUser.Apply(&tombola.MaxTicketsParUser)
+	req.MaxTickets.Apply(&tombola.MaxTickets)
+	req.RemiseQuantite.Apply(&tombola.RemiseQuantite)
+	req.RemisePourcent.Apply(&tombola.RemisePourcent)
+	if req.RegleGain.Set {
+		// La règle fait partie des données du tirage publié : elle ne change plus une fois les gagnants désignés
+		if tombola.Status == models.TombolaDrawn && models.PrizeRule(req.RegleGain.Value) != tombola.RegleGain {
+			response.Fail(c, response.InvalidField("regle_gain", "immutable", "Cannot be changed once the tombola is drawn"))
+			return
+		}
+		tombola.RegleGain = models.PrizeRule(req.RegleGain.Value)
+	}
+	req.DateLimiteRetrait.Apply(&tombola.DateLimiteRetrait)
+	var sold int64
+	if err := initializers.DB.Model(&models.Ticket{}).Where("tombola_id = ?", tombola.ID).Count(&sold).Error; err != nil {
+		response.Fail(c, response.Internal("Error retrieving tombola"))
+		return
+	}
+	if err := checkSalesChanges(before, tombola, sold > 0); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	if err := checkTicketSettings(tombola); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
 
-	// Récupère l'horodatage actuel en nanosecondes
-	timestamp := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := initializers.DB.Model(&tombola).
+		Select("nom", "prix_ticket", "vente_debut", "vente_fin", "max_tickets_par_user", "max_tickets", "remise_quantite", "remise_pourcent", "regle_gain", "date_limite_retrait").
+		Updates(&tombola).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to update tombola"))
+		return
+	}
+	c.JSON(http.StatusOK, tombola)
+}
 
-	// Génère une portion aléatoire (par exemple, un nombre aléatoire de 4 chiffres)
-	randomNumber, err := common.GenerateRandomNumber(1000, 9999)
-	if err != nil {
-		return "", err
+// checkSpender vérifie que l'appelant peut dépenser les jetons de userID
+func checkSpender(c *gin.Context, userID uint) bool {
+	err := services.RequireSpender(initializers.DB, c.GetUint("userID"), userID)
+	switch {
+	case errors.Is(err, services.ErrNotGuardian), errors.Is(err, services.ErrGuardianPermission):
+		response.Fail(c, response.ErrForbidden)
+		return false
+	case err != nil:
+		response.Fail(c, response.Internal("Failed to check guardian permission"))
+		return false
 	}
+	return true
+}
+
+// checkSalesChanges refuse les changements des conditions de vente qui ne valent plus pour les tickets déjà vendus :
+// le prix, les plafonds et la remise sont figés dès le premier ticket, et toute la vente l'est une fois la tombola
+// tirée ou annulée
+func checkSalesChanges(before, after models.Tombola, sold bool) error {
+	if after.Status == models.TombolaDrawn || after.Status == models.TombolaCancelled {
+		if !sameTime(before.VenteDebut, after.VenteDebut) {
+			return response.InvalidField("vente_debut", "immutable", "Cannot be changed once the tombola is drawn or cancelled")
+		}
+		if !sameTime(before.VenteFin, after.VenteFin) {
+			return response.InvalidField("vente_fin", "immutable", "Cannot be changed once the tombola is drawn or cancelled")
+		}
+	}
+	pricing := []struct {
+		field   string
+		changed bool
+	}{
+		{"prix_ticket", before.PrixTicket != after.PrixTicket},
+		{"max_tickets", !sameCap(before.MaxTickets, after.MaxTickets)},
+		{"max_tickets_par_user", !sameCap(before.MaxTicketsParUser, after.MaxTicketsParUser)},
+		{"remise_quantite", before.RemiseQuantite != after.RemiseQuantite},
+		{"remise_pourcent", before.RemisePourcent != after.RemisePourcent},
+	}
+	for _, p := range pricing {
+		if !p.changed {
+			continue
+		}
+		if after.Status == models.TombolaDrawn || after.Status == models.TombolaCancelled {
+			return response.InvalidField(p.field, "immutable", "Cannot be changed once the tombola is drawn or cancelled")
+		}
+		if sold {
+			return response.InvalidField(p.field, "immutable", "Cannot be changed once tickets have been sold")
+		}
+	}
+	return nil
+}
 
-	// Retourne le numéro de ticket sous la forme "T-<timestamp>-<randomNumber>"
-	return fmt.Sprintf("%s%s-%d", prefix, timestamp, randomNumber), nil
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameCap(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// checkTicketSettings vérifie les règles de vente qui portent sur plusieurs champs, après création ou mise à jour
+func checkTicketSettings(tombola models.Tombola) error {
+	if tombola.VenteDebut != nil && tombola.VenteFin != nil && !tombola.VenteFin.After(*tombola.VenteDebut) {
+		return response.InvalidField("vente_fin", "gtfield", "Must be after %s", "vente_debut")
+	}
+	if tombola.VenteFin != nil && tombola.DateLimiteRetrait != nil && !tombola.DateLimiteRetrait.After(*tombola.VenteFin) {
+		return response.InvalidField("date_limite_retrait", "gtfield", "Must be after %s", "vente_fin")
+	}
+	if (tombola.RemiseQuantite > 0) != (tombola.RemisePourcent > 0) {
+		return response.InvalidField("remise_pourcent", "required_with", "remise_quantite and remise_pourcent must be set together")
+	}
+	return nil
 }
 
 // BuyTicket godoc
-// @Summary Buy a ticket for tombola
-// @Description Buy a ticket for a specific tombola
+// @Summary Buy tickets for tombola
+// @Description Buy one or more tickets for a specific tombola at its ticket price, with its bulk discount. user_id must be the caller or a child the caller is a full guardian of. Sales must be open (sales window, tombola not drawn) and the tombola and per-user caps must leave room for all the tickets
 // @Tags Ticket
 // @Accept json
 // @Produce json
 // @Param id path int true "Tombola ID"
-// @Param ticket body models.Ticket true "Ticket purchase data"
+// @Param ticket body requests.BuyTicketRequest true "Ticket purchase data"
 // @Success 201 {object} models.Ticket
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
@@ -160,320 +385,647 @@ func generateTicketNumber() (string, error) {
 func BuyTicket(c *gin.Context) {
 	tombolaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid tombola ID"})
+		response.Fail(c, response.ErrInvalidID)
 		return
 	}
 
-	var purchase struct {
-		UserID uint `json:"user_id" binding:"required"`
-	}
-	if err := c.ShouldBindJSON(&purchase); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	var req requests.BuyTicketRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
-
-	const prixTicket = 2 // Prix fixe du ticket en jetons
-
-	// Vérifier le solde de jetons de l'utilisateur
-	var user models.User
-	if err := initializers.DB.First(&user, purchase.UserID).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "User not found"})
+	if !checkSpender(c, req.UserID) {
 		return
 	}
-
-	if user.SoldeJetons < prixTicket {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Insufficient jeton balance"})
-		return
+	if req.Quantite == 0 {
+		req.Quantite = 1
 	}
 
-	// Générer un numéro de ticket unique
-	numero, err := generateTicketNumber()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to generate ticket number"})
+	var tombola models.Tombola
+	if err := initializers.DB.First(&tombola, tombolaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrTombolaNotFound)
+		} else {
+			response.Fail(c, response.Internal("Error retrieving tombola"))
+		}
 		return
 	}
-
-	// Commencer une transaction
-	tx := initializers.DB.Begin()
-
-	// Créer le ticket
-	tombolaIDUint := uint(tombolaID)
-	ticket := models.Ticket{
-		TombolaID:    &tombolaIDUint,
-		UserID:       purchase.UserID,
-		Numero:       numero,
-		EstGagnant:   false,
-		PrixEnJetons: prixTicket, // Assigner le prix fixe au ticket
+	// Les tombolas créées avant le tirage vérifiable publient leur empreinte avant le premier ticket vendu
+	if tombola.DrawCommitment == "" && tombola.Status == models.TombolaOpen {
+		if err := publishDrawCommitment(&tombola); err != nil {
+			response.Fail(c, response.Internal("Failed to publish draw commitment"))
+			return
+		}
 	}
 
-	if err := tx.Create(&ticket).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create ticket"})
+	purchase, err := services.BuyTickets(initializers.DB, tombola.ID, req.UserID, req.Quantite, time.Now())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Fail(c, response.ErrTombolaNotFound)
 		return
-	}
-
-	// Mettre à jour le solde de jetons de l'utilisateur
-	user.SoldeJetons -= prixTicket
-	if err := tx.Save(&user).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to update user balance"})
+	case errors.Is(err, services.ErrBuyerNotFound):
+		response.Fail(c, response.ErrUserNotFound)
 		return
-	}
-
-	// Enregistrer la transaction de jetons
-	jetonTransaction := models.JetonTransaction{
-		UserID:      user.ID,
-		Montant:     prixTicket,
-		Type:        "ACHAT",
-		Description: fmt.Sprintf("Achat d'un ticket pour la tombola %d", tombolaID),
-	}
-
-	if err := tx.Create(&jetonTransaction).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to record jeton transaction"})
+	case errors.Is(err, services.ErrTicketSalesNotOpen):
+		response.Fail(c, response.ErrTicketSalesNotOpen)
 		return
-	}
-
-	// Commit de la transaction
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to complete ticket purchase"})
+	case errors.Is(err, services.ErrTicketSalesClosed):
+		response.Fail(c, response.ErrTicketSalesClosed)
+		return
+	case errors.Is(err, services.ErrTicketsSoldOut):
+		response.Fail(c, response.ErrTicketsSoldOut)
+		return
+	case errors.Is(err, services.ErrTicketLimitReached):
+		response.Fail(c, response.ErrTicketLimitReached)
+		return
+	case errors.Is(err, services.ErrInsufficientBalance):
+		response.Fail(c, response.ErrInsufficientBalance)
+		return
+	case errors.Is(err, services.ErrSpendingLimitExceeded):
+		response.Fail(c, response.ErrDailyLimitExceeded)
+		return
+	case err != nil:
+		log.Println("Erreur lors de l'achat de tickets:", err)
+		response.Fail(c, response.Internal("Failed to complete ticket purchase"))
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message":     "Ticket acheter avec succès",
-		"ticket":      ticket,
-		"new_balance": user.SoldeJetons,
+		"message": i18n.T(c, "Ticket purchased successfully"),
+		// ticket reste le premier ticket acheté, pour les clients qui n'en achètent qu'un
+		"ticket":      purchase.Tickets[0],
+		"tickets":     purchase.Tickets,
+		"total_price": purchase.Total,
+		"new_balance": purchase.NewBalance,
 	})
 }
 
 
+var ticketList = pagination.Spec{
+	Model: &models.Ticket{},
+	Filters: []pagination.Filter{
+		pagination.Equal("tombola_id", "tombola_id", pagination.ID),
+		pagination.Equal("user_id", "user_id", pagination.ID),
+		pagination.Equal("est_gagnant", "est_gagnant", pagination.Bool),
+		pagination.Where("kermesse_id", "tombola_id IN (SELECT id FROM tombolas WHERE kermesse_id = ?)", pagination.ID),
+	},
+	Sorts:       map[string]string{"id": "id", "numero": "numero"},
+	DefaultSort: "id",
+}
+
 // GetTickets godoc
 // @Summary Get all tickets
-// @Description Retrieve a list of all tickets
+// @Description Retrieve a page of tickets
 // @Tags Ticket
 // @Produce json
-// @Success 200 {array} response.AllTicketsResponse
+// @Param tombola_id query int false "Filter by tombola"
+// @Param user_id query int false "Filter by buyer"
+// @Param kermesse_id query int false "Filter by kermesse"
+// @Param est_gagnant query bool false "Only winning (true) or losing (false) tickets"
+// @Param sort query string false "Sort key: id or numero, prefixed with - for descending order" default(id)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]response.AllTicketsResponse}
+// @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas/tickets [get]
 func GetAllTickets(c *gin.Context) {
+	params, err := pagination.Parse(c, ticketList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
 
-    var tickets []models.Ticket
-    var totalCount int64
-
-    query := initializers.DB
-
-    	if err := initializers.DB.Find(&tickets).Error; err != nil {
-    		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve users"})
-    		return
-    	}
-
-    // Compter le nombre total de tickets
-    if err := query.Model(&models.Ticket{}).Count(&totalCount).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to count tickets"})
-        return
-    }
+	var tickets []models.Ticket
+	page, err := pagination.Find(initializers.DB, params, &tickets)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve tickets"))
+		return
+	}
 
-    var ticketResponses []response.AllTicketsResponse
-    for _, ticket := range tickets {
-        ticketResponses = append(ticketResponses, response.AllTicketsResponse{
-            ID:         ticket.ID,
-            Numero:     ticket.Numero,
-            EstGagnant: ticket.EstGagnant,
-            TombolaID:  ticket.TombolaID,
-            UserID:     ticket.UserID,
-        })
-    }
+	ticketResponses := make([]response.AllTicketsResponse, 0, len(tickets))
+	for _, ticket := range tickets {
+		ticketResponses = append(ticketResponses, response.AllTicketsResponse{
+			ID:           ticket.ID,
+			Numero:       ticket.Numero,
+			EstGagnant:   ticket.EstGagnant,
+			PrixEnJetons: ticket.PrixEnJetons,
+			TombolaID:    ticket.TombolaID,
+			UserID:       ticket.UserID,
+		})
+	}
 
-    c.JSON(http.StatusOK, gin.H{
-        "tickets":     ticketResponses,
-        "totalCount":  totalCount,
-    })
+	c.JSON(http.StatusOK, response.PageResponse{Data: ticketResponses, Page: page})
 }
 
+var userTicketList = pagination.Spec{
+	Model: &models.Ticket{},
+	Filters: []pagination.Filter{
+		pagination.Equal("est_gagnant", "est_gagnant", pagination.Bool),
+	},
+	Sorts:       map[string]string{"id": "id", "numero": "numero"},
+	DefaultSort: "id",
+}
 
 // GetTicketsByUser godoc
 // @Summary Get a specific ticket for user
-// @Description Retrieve details of a specific ticket of tombola
+// @Description Retrieve a page of the tickets a user bought for a tombola. A user without tickets gets an empty page
 // @Tags Ticket
 // @Produce json
 // @Param id path int true "Tombola ID"
 // @Param userId path int true "User ID"
-// @Success 200 {object} models.Ticket
+// @Param est_gagnant query bool false "Only winning (true) or losing (false) tickets"
+// @Param sort query string false "Sort key: id or numero, prefixed with - for descending order" default(id)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]models.Ticket}
+// @Failure 400 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas/{id}/user/{userId}/tickets [get]
 func GetUserTickets(c *gin.Context) {
 	tombolaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid tombola ID"})
+		response.Fail(c, response.ErrInvalidID)
 		return
 	}
 
 	userID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid user ID"})
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	params, err := pagination.Parse(c, userTicketList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	if !pagination.RequireParent(c, &models.Tombola{}, tombolaID, response.ErrTombolaNotFound) ||
+		!pagination.RequireParent(c, &models.User{}, userID, response.ErrUserNotFound) {
 		return
 	}
 
 	var tickets []models.Ticket
-	if err := initializers.DB.Where("tombola_id = ? AND user_id = ?", tombolaID, userID).Find(&tickets).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve user tickets"})
+	query := initializers.DB.Where("tombola_id = ? AND user_id = ?", tombolaID, userID)
+	page, err := pagination.Find(query, params, &tickets)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve user tickets"))
 		return
 	}
 
-	if len(tickets) == 0 {
-    		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "No tickets found for this user"})
-    		return
-    	}
+	c.JSON(http.StatusOK, response.PageResponse{Data: tickets, Page: page})
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"tickets": tickets,
-		"count":   len(tickets),
-	})
+var kermesseTombolaList = pagination.Spec{
+	Model:       &models.Tombola{},
+	Sorts:       map[string]string{"id": "id", "nom": "nom"},
+	DefaultSort: "id",
 }
 
 // GetKermesseTombolas godoc
 // @Summary Get all tombolas of a kermesse
-// @Description Retrieve a list of all tombolas for a specific kermesse
+// @Description Retrieve a page of the tombolas of a kermesse. An existing kermesse without tombolas returns an empty page
 // @Tags Tombola
 // @Produce json
 // @Param id path int true "Kermesse ID"
-// @Success 200 {array} response.TombolaResponse
+// @Param sort query string false "Sort key: id or nom, prefixed with - for descending order" default(id)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]response.TombolaResponse}
+// @Failure 400 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/kermesses/{id}/tombolas [get]
 func GetKermesseTombolas(c *gin.Context) {
-	id := c.Param("id")
-	var tombolas []models.Tombola
-	if err := initializers.DB.Where("kermesse_id = ?", id).Find(&tombolas).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve tombolas"})
+	kermesseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	params, err := pagination.Parse(c, kermesseTombolaList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+	if !pagination.RequireParent(c, &models.Kermesse{}, kermesseID, response.ErrKermesseNotFound) {
 		return
 	}
 
-	if len(tombolas) == 0 {
-    		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "No tombolas found for this kermesse"})
-    		return
-    	}
+	var tombolas []models.Tombola
+	page, err := pagination.Find(initializers.DB.Where("kermesse_id = ?", kermesseID), params, &tombolas)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve tombolas"))
+		return
+	}
 
-	var tombolasResponses []response.TombolaResponse
+	tombolasResponses := make([]response.TombolaResponse, 0, len(tombolas))
 	for _, tombola := range tombolas {
-		tombolasResponses = append(tombolasResponses, response.TombolaResponse{
-			ID:   tombola.ID,
-			Nom:  tombola.Nom,
-			KermesseID: tombola.KermesseID,
-		})
+		tombolasResponses = append(tombolasResponses, toTombolaResponse(tombola))
 	}
 
-	c.JSON(http.StatusOK, tombolasResponses)
+	c.JSON(http.StatusOK, response.PageResponse{Data: tombolasResponses, Page: page})
 }
 
 
 // PerformDraw godoc
 // @Summary Perform tombola draw
-// @Description Perform the draw for a tombola and assign winners
+// @Description Perform the verifiable draw of a tombola in one transaction and assign winners. The public value, announced to the participants at draw time, is mixed with the seed committed before ticket sales; ticket sales close and the seed is published by /api/tombolas/{id}/draw/verification. The draw is streamed live on /api/tombolas/{id}/draw/live with reveal_delay seconds (default 3) between steps, and the winners are notified through the app messaging once the last ticket is revealed; until then (reveal_ends_at) the winners, the verification and the prize claims are withheld, and the notification is resumed if the server restarts. Calling it again returns the existing winners; only an admin can void a draw (/api/tombolas/{id}/draw/void), and the re-draw then needs a public value not used by any voided draw
 // @Tags Tombola
+// @Accept json
 // @Produce json
 // @Param id path int true "Tombola ID"
+// @Param draw body requests.DrawRequest true "Public value of the draw"
 // @Success 200 {object} models.Tombola
+// @Failure 400 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/tombolas/{id}/draw [post]
 func PerformDraw(c *gin.Context) {
-	id := c.Param("id")
-	var tombola models.Tombola
-	if err := initializers.DB.Preload("Lots").Preload("Tickets").First(&tombola, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "Tombola not found"})
+	tombolaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
 		return
 	}
 
-	// Vérifier s'il y a des tickets et des lots
-	if len(tombola.Tickets) == 0 {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "No tickets available for the draw"})
+	var req requests.DrawRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
-	if len(tombola.Lots) == 0 {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "No lots available for the draw"})
-		return
+
+	delay := defaultRevealDelay
+	if req.RevealDelay != nil {
+		delay = time.Duration(*req.RevealDelay) * time.Second
 	}
 
-	// Effectuer le tirage
-	winners, err := performDrawLogic(&tombola)
-   if err != nil {
-   		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: fmt.Sprintf("Failed to perform draw: %v", err)})
-   		return
-   	}
+	// Effectuer le tirage ; les spectateurs qui arrivent entre-temps attendent sa diffusion
+	release := messages.ReserveDrawBroadcast(uint(tombolaID))
+	defer release()
+	winners, drawn, err := services.DrawTombola(initializers.DB, uint(tombolaID), c.GetUint("userID"), req.PublicValue, delay)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Fail(c, response.ErrTombolaNotFound)
+		return
+	case errors.Is(err, services.ErrTombolaCancelled):
+		response.Fail(c, response.ErrTombolaCancelled)
+		return
+	case errors.Is(err, services.ErrDrawNotCommitted):
+		response.Fail(c, response.ErrDrawNotCommitted)
+		return
+	case errors.Is(err, services.ErrPublicValueReused):
+		response.Fail(c, response.ErrPublicValueReused)
+		return
+	case errors.Is(err, services.ErrNoTicketsSold):
+		response.Fail(c, response.ErrNoTicketsSold)
+		return
+	case errors.Is(err, services.ErrNoLotsAvailable):
+		response.Fail(c, response.ErrNoLotsAvailable)
+		return
+	case err != nil:
+		log.Println("Erreur lors du tirage de la tombola:", err)
+		response.Fail(c, response.Internal("Failed to perform draw"))
+		return
+	}
 
 	if len(winners) == 0 {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "No winners were selected in the draw"})
+		response.Fail(c, response.Internal("No winners were selected in the draw"))
 		return
 	}
+	// Un tirage déjà effectué n'est pas diffusé une seconde fois
+	if drawn {
+		messages.BroadcastDraw(c, winners, delay)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"winners": winners})
 }
 
-func performDrawLogic(tombola *models.Tombola) ([]models.Gagnant, error) {
-    var winners []models.Gagnant
-    availableTickets := tombola.Tickets
-    availableLots := tombola.Lots
+// VoidDraw godoc
+// @Summary Void a tombola draw
+// @Description Void the draw of a tombola with a reason. Its winners are kept as voided results, the winning tickets are reset and a new seed commitment is published; ticket sales stay closed until the tombola is drawn again
+// @Tags Tombola
+// @Accept json
+// @Produce json
+// @Param id path int true "Tombola ID"
+// @Param void body requests.VoidDrawRequest true "Reason for voiding the draw"
+// @Success 200 {object} response.VoidedDrawResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/tombolas/{id}/draw/void [post]
+func VoidDraw(c *gin.Context) {
+	tombolaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
 
-    // Mélanger les tickets
-    shuffleTickets(availableTickets)
+	var req requests.VoidDrawRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
 
-    for _, lot := range availableLots {
-        if len(availableTickets) == 0 {
-            break // Plus de tickets disponibles
-        }
+	voided, err := services.VoidDraw(initializers.DB, uint(tombolaID), c.GetUint("userID"), req.Reason)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Fail(c, response.ErrTombolaNotFound)
+		return
+	case errors.Is(err, services.ErrTombolaNotDrawn):
+		response.Fail(c, response.ErrTombolaNotDrawn)
+		return
+	case err != nil:
+		log.Println("Erreur lors de l'annulation du tirage:", err)
+		response.Fail(c, response.Internal("Failed to void draw"))
+		return
+	}
+	messages.EndDrawBroadcast(uint(tombolaID))
 
-        // Sélectionner le premier ticket (qui est maintenant aléatoire grâce au mélange)
-        winningTicket := availableTickets[0]
-        availableTickets = availableTickets[1:] // Retirer le ticket gagnant
+	c.JSON(http.StatusOK, toVoidedDrawResponse(voided))
+}
 
-        winner := models.Gagnant{
-            UserID:    winningTicket.UserID,
-            TicketID:  winningTicket.ID,
-            LotID:     lot.ID,
-            TombolaID: tombola.ID,
-        }
+// CancelTombola godoc
+// @Summary Cancel a tombola
+// @Description Cancel a tombola that has not been drawn (a draw must be voided first) and refund every buyer the jetons paid for their tickets. Each refund is recorded as a REMBOURSEMENT jeton transaction linked to the tombola; ticket sales and the draw are closed for good
+// @Tags Tombola
+// @Accept json
+// @Produce json
+// @Param id path int true "Tombola ID"
+// @Param cancel body requests.CancelTombolaRequest true "Reason for cancelling the tombola"
+// @Success 200 {object} response.TombolaRefundResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/tombolas/{id}/cancel [post]
+func CancelTombola(c *gin.Context) {
+	tombolaID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
 
-          // Créer le gagnant et précharger toutes les relations
-                if err := initializers.DB.Create(&winner).Error; err != nil {
-                    return nil, fmt.Errorf("failed to create winner: %v", err)
-                }
-
-        // Précharger toutes les relations pour le gagnant
-               if err := initializers.DB.Preload("User").
-                   Preload("Tombola").
-                   Preload("Lot").
-                   Preload("Ticket").
-                   First(&winner, winner.ID).Error; err != nil {
-                   return nil, fmt.Errorf("failed to load winner relations: %v", err)
-               }
-
-        winners = append(winners, winner)
-
-        // Marquer le ticket comme gagnant
-        winningTicket.EstGagnant = true
-        if err := initializers.DB.Save(&winningTicket).Error; err != nil {
-            return nil, fmt.Errorf("failed to update winning ticket: %v", err)
-        }
-    }
+	var req requests.CancelTombolaRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
 
-    return winners, nil
+	refund, err := services.CancelTombola(initializers.DB, uint(tombolaID), req.Reason, time.Now())
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.Fail(c, response.ErrTombolaNotFound)
+		return
+	case errors.Is(err, services.ErrTombolaCancelled):
+		response.Fail(c, response.ErrTombolaCancelled)
+		return
+	case errors.Is(err, services.ErrTombolaDrawn):
+		response.Fail(c, response.ErrTombolaAlreadyDrawn)
+		return
+	case err != nil:
+		log.Println("Erreur lors de l'annulation de la tombola:", err)
+		response.Fail(c, response.Internal("Failed to cancel tombola"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TombolaRefundResponse{
+		Message:         i18n.T(c, "Tombola cancelled and tickets refunded"),
+		TombolaID:       uint(tombolaID),
+		BuyersRefunded:  refund.Buyers,
+		TicketsRefunded: refund.Tickets,
+		JetonsRefunded:  refund.Total,
+	})
+}
+
+// GetTombolaSalesReport godoc
+// @Summary Get tombola ticket sales report
+// @Description Get the ticket sales of a tombola: tickets sold and refunded, distinct buyers, jetons collected and refunded according to the jeton ledger, net revenue and purchases per day
+// @Tags Tombola
+// @Produce json
+// @Param id path int true "Tombola ID"
+// @Success 200 {object} response.TombolaSalesReportResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/tombolas/{id}/sales-report [get]
+func GetTombolaSalesReport(c *gin.Context) {
+	var tombola models.Tombola
+	if err := initializers.DB.First(&tombola, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrTombolaNotFound)
+		} else {
+			response.Fail(c, response.Internal("Error retrieving tombola"))
+		}
+		return
+	}
+
+	report, err := services.TombolaSalesReport(initializers.DB, tombola.ID)
+	if err != nil {
+		log.Println("Erreur lors du calcul des ventes de la tombola:", err)
+		response.Fail(c, response.Internal("Failed to compute ticket sales report"))
+		return
+	}
+
+	days := make([]response.DailyTicketSalesResponse, 0, len(report.ParJour))
+	for _, day := range report.ParJour {
+		days = append(days, response.DailyTicketSalesResponse{Jour: day.Jour, Achats: day.Achats, Jetons: day.Jetons})
+	}
+
+	c.JSON(http.StatusOK, response.TombolaSalesReportResponse{
+		TombolaID:         tombola.ID,
+		Nom:               tombola.Nom,
+		Status:            string(tombola.Status),
+		PrixTicket:        tombola.PrixTicket,
+		TicketsVendus:     report.TicketsVendus,
+		TicketsRembourses: report.TicketsRembourses,
+		Acheteurs:         report.Acheteurs,
+		JetonsEncaisses:   report.JetonsEncaisses,
+		JetonsRembourses:  report.JetonsRembourses,
+		RecetteNette:      report.JetonsEncaisses - report.JetonsRembourses,
+		ParJour:           days,
+	})
+}
+
+// VerifyDraw godoc
+// @Summary Verify a tombola draw
+// @Description Publish the seed and the inputs of a completed draw, recompute the winners and compare them with the recorded ones. Algorithm sha256-fisher-yates-v2: commitment = SHA-256(seed); key = SHA-256(seed + ":" + public_value); ticket_ids are shuffled by Fisher-Yates (i from n-1 down to 1, j uniform in [0, i]) using the 8-byte big-endian words of SHA-256(key || 8-byte big-endian counter), rejecting the words above the largest multiple of i+1; award_lot_ids lists the lots by rank then ID, each repeated by its quantity, and the k-th award goes to the first shuffled ticket not yet picked whose group in ticket_groups has not won yet (groups only apply when prize_rule is ONE_PER_USER or ONE_PER_FAMILY). Prizes left unclaimed after the claim deadline are re-drawn by continuing the same walk: a winner with replaces_ordre k' and ordre k holds the k-th picked ticket and takes over the lot of award k'. Voided draws are listed with their seed. Returns 409 while the draw is still being revealed live. After a void the seed is re-committed when the draw is voided, that is after ticket sales closed: recommitted_at gives that date, and the re-draw must use a public value announced afterwards and different from those of the voided draws. No authentication required
+// @Tags Tombola
+// @Produce json
+// @Param id path int true "Tombola ID"
+// @Success 200 {object} response.DrawVerificationResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/tombolas/{id}/draw/verification [get]
+func VerifyDraw(c *gin.Context) {
+	var tombola models.Tombola
+	if err := services.LoadDrawInputs(initializers.DB).First(&tombola, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Fail(c, response.ErrTombolaNotFound)
+		} else {
+			response.Fail(c, response.Internal("Failed to retrieve draw"))
+		}
+		return
+	}
+	// La graine reste secrète tant que le tirage n'a pas eu lieu
+	if tombola.Status != models.TombolaDrawn || tombola.DrawnAt == nil {
+		response.Fail(c, response.ErrTombolaNotDrawn)
+		return
+	}
+	// Elle ne l'est qu'une fois la diffusion en direct terminée : elle permettrait de calculer les gagnants
+	if tombola.Revealing(time.Now()) {
+		response.Fail(c, response.ErrDrawRevealing)
+		return
+	}
+
+	var recorded []models.Gagnant
+	if err := initializers.DB.Where("tombola_id = ? AND voided_draw_id IS NULL", tombola.ID).Order("ordre").Find(&recorded).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve draw"))
+		return
+	}
+	var voidedDraws []models.VoidedDraw
+	if err := initializers.DB.Where("tombola_id = ?", tombola.ID).Order("id").Find(&voidedDraws).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve draw"))
+		return
+	}
+
+	// Chaque gain enregistré est comparé au gain recalculé de même ordre
+	recordedAwards := make(map[int]models.Gagnant, len(recorded))
+	recordedByID := make(map[uint]models.Gagnant, len(recorded))
+	var replacements []models.Gagnant
+	for _, gagnant := range recorded {
+		recordedByID[gagnant.ID] = gagnant
+		if gagnant.RemplaceID != nil {
+			replacements = append(replacements, gagnant)
+		} else {
+			recordedAwards[gagnant.Ordre] = gagnant
+		}
+	}
+
+	drawn := services.DrawWinningTickets(&tombola, tombola.DrawPublicValue)
+	winners := make([]response.DrawWinnerResponse, 0, len(drawn)+len(replacements))
+	match := len(drawn)+len(replacements) == len(recorded)
+	for _, winner := range drawn {
+		winners = append(winners, response.DrawWinnerResponse{
+			Ordre:    winner.Ordre,
+			LotID:    winner.Lot.ID,
+			TicketID: winner.Ticket.ID,
+			Numero:   winner.Ticket.Numero,
+		})
+		if award, ok := recordedAwards[winner.Ordre]; !ok || award.TicketID != winner.Ticket.ID || award.LotID != winner.Lot.ID {
+			match = false
+		}
+	}
+
+	// Les remplaçants des lots non retirés sont les tickets suivants du même tirage
+	if len(replacements) > 0 {
+		numeros := make(map[uint]string, len(tombola.Tickets))
+		for _, ticket := range tombola.Tickets {
+			numeros[ticket.ID] = ticket.Numero
+		}
+		picks := services.DrawPicks(&tombola, tombola.DrawPublicValue, len(recorded))
+		for _, replacement := range replacements {
+			replaced, ok := recordedByID[*replacement.RemplaceID]
+			winner := response.DrawWinnerResponse{
+				Ordre:         replacement.Ordre,
+				LotID:         replaced.LotID,
+				ReplacesOrdre: replaced.Ordre,
+			}
+			if replacement.Ordre >= 1 && replacement.Ordre <= len(picks) {
+				winner.TicketID = picks[replacement.Ordre-1]
+				winner.Numero = numeros[winner.TicketID]
+			}
+			winners = append(winners, winner)
+			if !ok || replaced.Statut != models.PrizeUnclaimed || replacement.TicketID != winner.TicketID || replacement.LotID != replaced.LotID {
+				match = false
+			}
+		}
+	}
+
+	ticketIDs := make([]uint, 0, len(tombola.Tickets))
+	var ticketGroups []uint
+	for _, ticket := range tombola.Tickets {
+		ticketIDs = append(ticketIDs, ticket.ID)
+		if tombola.RegleGain.Restricted() {
+			ticketGroups = append(ticketGroups, ticket.GroupeTirage)
+		}
+	}
+	lotIDs := make([]uint, 0, len(tombola.Lots))
+	for _, lot := range tombola.Lots {
+		lotIDs = append(lotIDs, lot.ID)
+	}
+	awards := services.AwardedLots(tombola.Lots)
+	awardLotIDs := make([]uint, 0, len(awards))
+	for _, lot := range awards {
+		awardLotIDs = append(awardLotIDs, lot.ID)
+	}
+	voidedResponses := make([]response.VoidedDrawResponse, 0, len(voidedDraws))
+	var recommittedAt *time.Time
+	for _, voided := range voidedDraws {
+		voidedResponses = append(voidedResponses, toVoidedDrawResponse(voided))
+		// L'empreinte en vigueur a été publiée lors de la dernière annulation
+		voidedAt := voided.VoidedAt
+		recommittedAt = &voidedAt
+	}
+
+	c.JSON(http.StatusOK, response.DrawVerificationResponse{
+		TombolaID:       tombola.ID,
+		Algorithm:       common.DrawAlgorithm,
+		Commitment:      tombola.DrawCommitment,
+		Seed:            tombola.DrawSeed,
+		PublicValue:     tombola.DrawPublicValue,
+		DrawnAt:         *tombola.DrawnAt,
+		TicketIDs:       ticketIDs,
+		LotIDs:          lotIDs,
+		AwardLotIDs:     awardLotIDs,
+		PrizeRule:       string(tombola.RegleGain),
+		TicketGroups:    ticketGroups,
+		Winners:         winners,
+		CommitmentValid: common.DrawCommitment(tombola.DrawSeed) == tombola.DrawCommitment,
+		WinnersMatch:    match,
+		VoidedDraws:     voidedResponses,
+		RecommittedAt:   recommittedAt,
+	})
 }
 
-// shuffleTickets mélange la slice de tickets en utilisant l'algorithme de Fisher-Yates
-func shuffleTickets(tickets []models.Ticket) {
-	rand.Seed(time.Now().UnixNano())
-	for i := len(tickets) - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
-		tickets[i], tickets[j] = tickets[j], tickets[i]
+func toVoidedDrawResponse(voided models.VoidedDraw) response.VoidedDrawResponse {
+	return response.VoidedDrawResponse{
+		ID:          voided.ID,
+		Commitment:  voided.Commitment,
+		Seed:        voided.Seed,
+		PublicValue: voided.PublicValue,
+		DrawnAt:     voided.DrawnAt,
+		Reason:      voided.Reason,
+		VoidedByID:  voided.VoidedByID,
+		VoidedAt:    voided.VoidedAt,
+	}
+}
+
+// publishDrawCommitment tire la graine d'une tombola qui n'en a pas encore ; une requête concurrente
+// ayant déjà publié la sienne la conserve
+func publishDrawCommitment(tombola *models.Tombola) error {
+	seed, commitment, err := common.NewDrawSeed()
+	if err != nil {
+		return err
 	}
+	return initializers.DB.Model(&models.Tombola{}).
+		Where("id = ? AND (draw_commitment IS NULL OR draw_commitment = '')", tombola.ID).
+		Updates(map[string]interface{}{"draw_commitment": commitment, "draw_seed": seed}).Error
 }
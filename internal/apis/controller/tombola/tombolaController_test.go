@@ -0,0 +1,80 @@
+package tombola
+
+import (
+	"example/hello/internal/apis/apitest"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetUserTickets(t *testing.T) {
+	apitest.OpenDatabase(t, &models.User{}, &models.Tombola{}, &models.Ticket{})
+	user := models.User{Name: "Alice", Email: "alice@example.com"}
+	apitest.Create(t, &user)
+	tombola := models.Tombola{Nom: "Grande tombola"}
+	apitest.Create(t, &tombola)
+
+	t.Run("unknown tombola", func(t *testing.T) {
+		recorder := apitest.Get(t, "/tombolas/:id/user/:userId/tickets", GetUserTickets, fmt.Sprintf("/tombolas/999/user/%d/tickets", user.ID))
+		apitest.ExpectError(t, recorder, http.StatusNotFound, "TOMBOLA_NOT_FOUND")
+	})
+	t.Run("unknown user", func(t *testing.T) {
+		recorder := apitest.Get(t, "/tombolas/:id/user/:userId/tickets", GetUserTickets, fmt.Sprintf("/tombolas/%d/user/999/tickets", tombola.ID))
+		apitest.ExpectError(t, recorder, http.StatusNotFound, "USER_NOT_FOUND")
+	})
+	t.Run("user without tickets", func(t *testing.T) {
+		recorder := apitest.Get(t, "/tombolas/:id/user/:userId/tickets", GetUserTickets, fmt.Sprintf("/tombolas/%d/user/%d/tickets", tombola.ID, user.ID))
+		apitest.ExpectEmptyPage(t, recorder, "id")
+	})
+}
+
+func TestGetKermesseTombolas(t *testing.T) {
+	apitest.OpenDatabase(t, &models.Kermesse{}, &models.Tombola{})
+	kermesse := models.Kermesse{Nom: "Kermesse de juin"}
+	apitest.Create(t, &kermesse)
+
+	t.Run("unknown kermesse", func(t *testing.T) {
+		recorder := apitest.Get(t, "/kermesses/:id/tombolas", GetKermesseTombolas, "/kermesses/999/tombolas")
+		apitest.ExpectError(t, recorder, http.StatusNotFound, "KERMESSE_NOT_FOUND")
+	})
+	t.Run("kermesse without tombolas", func(t *testing.T) {
+		recorder := apitest.Get(t, "/kermesses/:id/tombolas", GetKermesseTombolas, fmt.Sprintf("/kermesses/%d/tombolas", kermesse.ID))
+		apitest.ExpectEmptyPage(t, recorder, "id")
+	})
+}
+
+func TestUpdateTombola(t *testing.T) {
+	apitest.OpenDatabase(t, &models.User{}, &models.Tombola{}, &models.Ticket{})
+	user := models.User{Name: "Alice", Email: "alice@example.com"}
+	apitest.Create(t, &user)
+	tombola := models.Tombola{Nom: "Grande tombola", PrixTicket: 2}
+	apitest.Create(t, &tombola)
+	target := fmt.Sprintf("/tombolas/%d", tombola.ID)
+
+	t.Run("empty patch", func(t *testing.T) {
+		recorder := apitest.Send(t, http.MethodPatch, "/tombolas/:id", target, map[string]interface{}{}, UpdateTombola)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d (body %s)", recorder.Code, http.StatusOK, recorder.Body)
+		}
+	})
+	t.Run("cap change before any sale", func(t *testing.T) {
+		recorder := apitest.Send(t, http.MethodPatch, "/tombolas/:id", target, map[string]interface{}{"max_tickets": 100}, UpdateTombola)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d (body %s)", recorder.Code, http.StatusOK, recorder.Body)
+		}
+		var updated models.Tombola
+		if err := initializers.DB.First(&updated, tombola.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if updated.MaxTickets == nil || *updated.MaxTickets != 100 {
+			t.Fatalf("max_tickets = %v, want 100", updated.MaxTickets)
+		}
+	})
+	t.Run("price change after a sale", func(t *testing.T) {
+		apitest.Create(t, &models.Ticket{TombolaID: &tombola.ID, UserID: user.ID, Numero: "T-1", PrixEnJetons: 2})
+		recorder := apitest.Send(t, http.MethodPatch, "/tombolas/:id", target, map[string]interface{}{"prix_ticket": 3}, UpdateTombola)
+		apitest.ExpectError(t, recorder, http.StatusBadRequest, "VALIDATION_FAILED")
+	})
+}
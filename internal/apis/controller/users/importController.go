@@ -0,0 +1,129 @@
+package users
+
+import (
+	"example/hello/common"
+	"example/hello/i18n"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/mailer"
+	"example/hello/response"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxRosterFileSize = 5 << 20
+
+// ImportRoster godoc
+// @Summary Import students and parents from the school roster
+// @Description Upload a CSV or XLSX file with the columns parent_name, parent_email, student_name and optionally student_email.
+// @Description Without commit=true the import is a dry run: every row is validated and the counts are computed, but nothing is saved.
+// @Description With commit=true the file is imported in a single transaction, only if no row has an error, and activation emails are sent.
+// @Tags Users
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Roster file (.csv or .xlsx)"
+// @Param commit query bool false "Save the import instead of running a dry run"
+// @Success 200 {object} response.RosterImportResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 422 {object} response.RosterImportResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/import [post]
+func ImportRoster(c *gin.Context) {
+	commit := c.Query("commit") == "true"
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.Fail(c, response.ErrFileRequired)
+		return
+	}
+	if fileHeader.Size > maxRosterFileSize {
+		response.Fail(c, response.ErrFileTooLarge)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.Fail(c, response.ErrFileUnreadable)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxRosterFileSize))
+	if err != nil {
+		response.Fail(c, response.ErrFileUnreadable)
+		return
+	}
+
+	rows, err := common.ReadSpreadsheet(fileHeader.Filename, data)
+	if err != nil {
+		response.Fail(c, response.ErrFileUnreadable.WithMessage(err.Error()))
+		return
+	}
+
+	parsed, rowErrors := services.ParseRoster(rows)
+
+	// Le simulacre passe par le même code que l'import réel, puis la transaction est annulée
+	tx := initializers.DB.Begin()
+	result, conflicts, err := services.ImportRoster(tx, parsed)
+	if err != nil {
+		tx.Rollback()
+		log.Println("Erreur lors de l'import de la liste des élèves:", err)
+		response.Fail(c, response.Internal("Failed to import roster"))
+		return
+	}
+	rowErrors = append(rowErrors, conflicts...)
+	sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+
+	report := response.RosterImportResponse{
+		DryRun:           !commit,
+		Rows:             countRows(parsed, rowErrors),
+		ParentsCreated:   result.ParentsCreated,
+		ParentsExisting:  result.ParentsExisting,
+		StudentsCreated:  result.StudentsCreated,
+		StudentsExisting: result.StudentsExisting,
+		Errors:           make([]response.RosterRowError, 0, len(rowErrors)),
+	}
+	for _, rowError := range rowErrors {
+		report.Errors = append(report.Errors, response.RosterRowError{Row: rowError.Row, Field: rowError.Field, Message: i18n.T(c, rowError.Message, rowError.Args...)})
+	}
+
+	if !commit || len(rowErrors) > 0 {
+		tx.Rollback()
+		status := http.StatusOK
+		if commit {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, report)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		response.Fail(c, response.Internal("Failed to import roster"))
+		return
+	}
+	report.Committed = true
+
+	for _, activation := range result.Activations {
+		mailer.SendAsync(mailer.ActivationEmail(i18n.ForRecipient(c, activation.User.Language), activation.User.Email, activation.User.Name, activation.Token))
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// countRows compte les lignes de données du fichier, valides ou rejetées
+func countRows(parsed []services.RosterRow, rowErrors []services.RosterRowError) int {
+	lines := make(map[int]bool, len(parsed))
+	for _, row := range parsed {
+		lines[row.Line] = true
+	}
+	for _, rowError := range rowErrors {
+		lines[rowError.Row] = true
+	}
+	return len(lines)
+}
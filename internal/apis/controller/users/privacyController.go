@@ -0,0 +1,322 @@
+package users
+
+import (
+	"errors"
+	"example/hello/internal/apis/pagination"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/requests"
+	"example/hello/response"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func toErasureRequestResponse(request models.ErasureRequest) response.ErasureRequestResponse {
+	return response.ErasureRequestResponse{
+		ID:            request.ID,
+		UserID:        request.UserID,
+		RequestedByID: request.RequestedByID,
+		Reason:        request.Reason,
+		Status:        string(request.Status),
+		Motif:         request.Motif,
+		ProcessedAt:   request.ProcessedAt,
+		CreatedAt:     request.CreatedAt,
+	}
+}
+
+// ExportMyData godoc
+// @Summary Download a copy of my personal data
+// @Description Return a zip archive of JSON files with the profile, children, jeton transactions, tickets, wins, messages, sessions and login events of the current user
+// @Tags Users
+// @Produce application/zip
+// @Success 200 {file} file
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/export [get]
+func ExportMyData(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	archive, err := services.BuildUserExport(initializers.DB, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("Erreur lors de l'export des données personnelles:", err)
+		response.Fail(c, response.Internal("Failed to export data"))
+		return
+	}
+
+	filename := fmt.Sprintf("kermesse-export-%d-%s.zip", userID, time.Now().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// createErasureRequest enregistre une demande en attente ; une seule demande peut être en cours par compte
+func createErasureRequest(c *gin.Context, userID uint, reason string) {
+	request := models.ErasureRequest{
+		UserID:        userID,
+		RequestedByID: c.GetUint("userID"),
+		Reason:        reason,
+		Status:        models.ErasurePending,
+	}
+
+	var conflict bool
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var pending int64
+		if err := tx.Model(&models.ErasureRequest{}).Where("user_id = ? AND status = ?", userID, models.ErasurePending).Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			conflict = true
+			return nil
+		}
+		return tx.Create(&request).Error
+	})
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to create erasure request"))
+		return
+	}
+	if conflict {
+		response.Fail(c, response.ErrErasurePending)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toErasureRequestResponse(request))
+}
+
+// RequestErasure godoc
+// @Summary Ask for the erasure of my account
+// @Description Create an erasure request for the current user. Once approved by an administrator, personal data is anonymised; financial records are kept for accounting
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body requests.ErasureRequest false "Reason"
+// @Success 201 {object} response.ErasureRequestResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/erasure-request [post]
+func RequestErasure(c *gin.Context) {
+	var req requests.ErasureRequest
+	_ = c.ShouldBindJSON(&req)
+
+	createErasureRequest(c, c.GetUint("userID"), req.Reason)
+}
+
+// CancelErasure godoc
+// @Summary Cancel my pending erasure request
+// @Description Cancel the erasure request of the current user while it has not been processed
+// @Tags Users
+// @Produce json
+// @Success 200 {object} response.SuccessResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/users/me/erasure-request [delete]
+func CancelErasure(c *gin.Context) {
+	result := initializers.DB.Model(&models.ErasureRequest{}).
+		Where("user_id = ? AND status = ?", c.GetUint("userID"), models.ErasurePending).
+		Update("status", models.ErasureCancelled)
+	if result.Error != nil {
+		response.Fail(c, response.Internal("Failed to cancel erasure request"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Fail(c, response.ErrNotFound.WithMessage("No pending erasure request"))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SuccessResponse{Data: true})
+}
+
+// RequestChildErasure godoc
+// @Summary Ask for the erasure of a child account
+// @Description Create an erasure request for a child. Only guardians with FULL permission can ask for it
+// @Tags Parents
+// @Accept json
+// @Produce json
+// @Param id path int true "Eleve ID"
+// @Param request body requests.ErasureRequest false "Reason"
+// @Success 201 {object} response.ErasureRequestResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/children/{id}/erasure-request [post]
+func RequestChildErasure(c *gin.Context) {
+	eleveID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var eleve models.Eleve
+	if err := initializers.DB.First(&eleve, eleveID).Error; err != nil {
+		response.Fail(c, response.ErrChildNotFound)
+		return
+	}
+
+	err = services.RequireGuardian(initializers.DB, c.GetUint("userID"), eleve.ID, models.GuardianPermission.CanManage)
+	switch {
+	case errors.Is(err, services.ErrNotGuardian), errors.Is(err, services.ErrGuardianPermission):
+		response.Fail(c, response.ErrGuardianPermission.WithMessage("Only a guardian with full permission can ask for this erasure"))
+		return
+	case err != nil:
+		response.Fail(c, response.Internal("Failed to check guardian permission"))
+		return
+	}
+
+	var req requests.ErasureRequest
+	_ = c.ShouldBindJSON(&req)
+
+	createErasureRequest(c, eleve.UserID, req.Reason)
+}
+
+var erasureRequestList = pagination.Spec{
+	Model: &models.ErasureRequest{},
+	Filters: []pagination.Filter{
+		pagination.Equal("status", "status", pagination.OneOf(map[string]interface{}{
+			string(models.ErasurePending):   models.ErasurePending,
+			string(models.ErasureCompleted): models.ErasureCompleted,
+			string(models.ErasureRejected):  models.ErasureRejected,
+			string(models.ErasureCancelled): models.ErasureCancelled,
+		})),
+		pagination.Equal("user_id", "user_id", pagination.ID),
+	},
+	Sorts:       map[string]string{"id": "id", "created_at": "created_at"},
+	DefaultSort: "-created_at",
+}
+
+// GetErasureRequests godoc
+// @Summary List erasure requests
+// @Description List the erasure requests, most recent first, optionally filtered by status (PENDING, COMPLETED, REJECTED, CANCELLED)
+// @Tags Users
+// @Produce json
+// @Param status query string false "Status"
+// @Param user_id query int false "Filter by the account to erase"
+// @Param sort query string false "Sort key: id or created_at, prefixed with - for descending order" default(-created_at)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]response.ErasureRequestResponse}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/erasure-requests [get]
+func GetErasureRequests(c *gin.Context) {
+	params, err := pagination.Parse(c, erasureRequestList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var erasureRequests []models.ErasureRequest
+	page, err := pagination.Find(initializers.DB, params, &erasureRequests)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve erasure requests"))
+		return
+	}
+
+	result := make([]response.ErasureRequestResponse, 0, len(erasureRequests))
+	for _, request := range erasureRequests {
+		result = append(result, toErasureRequestResponse(request))
+	}
+	c.JSON(http.StatusOK, response.PageResponse{Data: result, Page: page})
+}
+
+// ProcessErasureRequest godoc
+// @Summary Approve or reject an erasure request
+// @Description Approving anonymises the account: name, email, credentials, sessions, login traces and message contents are erased.
+// @Description Jeton transactions, tickets, wins and offline sales are kept and stay linked to the anonymised account.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path int true "Erasure request ID"
+// @Param request body requests.ProcessErasureRequest true "Decision"
+// @Success 200 {object} response.ErasureRequestResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security Bearer
+// @Param Authorization header string true "Insert your access token" default(Bearer )
+// @Router /api/erasure-requests/{id}/process [post]
+func ProcessErasureRequest(c *gin.Context) {
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, response.ErrInvalidID)
+		return
+	}
+
+	var req requests.ProcessErasureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	var request models.ErasureRequest
+	if err := initializers.DB.First(&request, requestID).Error; err != nil {
+		response.Fail(c, response.ErrErasureNotFound)
+		return
+	}
+
+	status := models.ErasureRejected
+	if *req.Approve {
+		status = models.ErasureCompleted
+	}
+	now := time.Now()
+	adminID := c.GetUint("userID")
+
+	var alreadyProcessed bool
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		// La mise à jour conditionnelle empêche de traiter deux fois la même demande
+		result := tx.Model(&models.ErasureRequest{}).
+			Where("id = ? AND status = ?", request.ID, models.ErasurePending).
+			Updates(map[string]interface{}{"status": status, "motif": req.Motif, "processed_by_id": adminID, "processed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			alreadyProcessed = true
+			return nil
+		}
+		if status == models.ErasureCompleted {
+			return services.AnonymiseUser(tx, request.UserID)
+		}
+		return nil
+	})
+	switch {
+	case alreadyProcessed:
+		response.Fail(c, response.ErrErasureProcessed)
+		return
+	case errors.Is(err, services.ErrAlreadyErased):
+		response.Fail(c, response.ErrAlreadyErased)
+		return
+	case err != nil:
+		log.Println("Erreur lors de l'anonymisation du compte:", err)
+		response.Fail(c, response.Internal("Failed to process erasure request"))
+		return
+	}
+
+	request.Status = status
+	request.Motif = req.Motif
+	request.ProcessedByID = &adminID
+	request.ProcessedAt = &now
+	c.JSON(http.StatusOK, toErasureRequestResponse(request))
+}
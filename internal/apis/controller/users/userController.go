@@ -1,37 +1,96 @@
 package users
 
 import (
+	"example/hello/internal/apis/pagination"
+	"example/hello/internal/apis/services"
 	"example/hello/internal/initializers"
 	"example/hello/internal/models"
+	"example/hello/requests"
 	"example/hello/response"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+func stringToRole(roleStr string) (models.Role, error) {
+	switch roleStr {
+	case "ELEVE":
+		return models.RoleEleve, nil
+	case "PARENT":
+		return models.RoleParent, nil
+	case "TENEUR_STAND":
+		return models.RoleTeneurStand, nil
+	case "ORGANISATEUR":
+		return models.RoleOrganisateur, nil
+	case "ADMIN":
+		return models.RoleAdmin, nil
+	default:
+		return models.Role(0), fmt.Errorf("rôle invalide: %s", roleStr)
+	}
+}
+
 // CreateUser godoc
 // @Summary Create a new user
-// @Description Create a new user with the provided information
+// @Description Create a new user with the provided information. Unknown fields are rejected
 // @Tags Users
 // @Accept json
 // @Produce json
-// @Param user body models.User true "User data"
+// @Param user body requests.CreateUserRequest true "User data"
 // @Success 201 {object} response.UserResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer Add access token here)
 // @Router /api/users [post]
 func CreateUser(c *gin.Context) {
-	var newUser models.User
-	if err := c.ShouldBindJSON(&newUser); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+	var req requests.CreateUserRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
+	role, err := stringToRole(req.Role)
+	if err != nil {
+		response.Fail(c, response.ErrInvalidRole)
+		return
+	}
+
+	var existing int64
+	if err := initializers.DB.Model(&models.User{}).Where("LOWER(email) = LOWER(?)", req.Email).Count(&existing).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to create user"))
+		return
+	}
+	if existing > 0 {
+		response.Fail(c, response.ErrEmailInUse)
 		return
 	}
 
-	if err := initializers.DB.Create(&newUser).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to create user"})
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to process password"))
+		return
+	}
+
+	newUser := models.User{
+		Name:     req.Name,
+		Email:    req.Email,
+		Password: string(passwordHash),
+		Roles:    role,
+	}
+
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		return services.GrantRole(tx, newUser.ID, newUser.Roles)
+	})
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to create user"))
 		return
 	}
 
@@ -43,24 +102,52 @@ func CreateUser(c *gin.Context) {
 	})
 }
 
+// userList : les rôles filtrés sont tous ceux détenus, pas seulement le rôle actif
+var userList = pagination.Spec{
+	Model: &models.User{},
+	Filters: []pagination.Filter{
+		pagination.Where("role", "users.id IN (SELECT user_id FROM user_roles WHERE role = ?)", pagination.OneOf(map[string]interface{}{
+			"ELEVE":        models.RoleEleve,
+			"PARENT":       models.RoleParent,
+			"TENEUR_STAND": models.RoleTeneurStand,
+			"ORGANISATEUR": models.RoleOrganisateur,
+			"ADMIN":        models.RoleAdmin,
+		})),
+	},
+	Sorts:       map[string]string{"id": "id", "name": "name", "email": "email"},
+	DefaultSort: "id",
+}
+
 // GetUsers godoc
 // @Summary Get all users
-// @Description Retrieve a list of all users
+// @Description Retrieve a page of users
 // @Tags Users
 // @Produce json
-// @Success 200 {array} response.UserResponse
+// @Param role query string false "Filter by held role (ELEVE, PARENT, TENEUR_STAND, ORGANISATEUR, ADMIN)"
+// @Param sort query string false "Sort key: id, name or email, prefixed with - for descending order" default(id)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param cursor query string false "Cursor returned in page.next_cursor"
+// @Success 200 {object} response.PageResponse{data=[]response.UserResponse}
+// @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/users [get]
 func GetUsers(c *gin.Context) {
+	params, err := pagination.Parse(c, userList)
+	if err != nil {
+		response.Fail(c, response.BindingError(err))
+		return
+	}
+
 	var users []models.User
-	if err := initializers.DB.Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to retrieve users"})
+	page, err := pagination.Find(initializers.DB, params, &users)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve users"))
 		return
 	}
 
-	var userResponses []response.UserResponse
+	userResponses := make([]response.UserResponse, 0, len(users))
 	for _, user := range users {
 		userResponses = append(userResponses, response.UserResponse{
 			ID:    user.ID,
@@ -70,7 +157,7 @@ func GetUsers(c *gin.Context) {
 		})
 	}
 
-	c.JSON(http.StatusOK, userResponses)
+	c.JSON(http.StatusOK, response.PageResponse{Data: userResponses, Page: page})
 }
 
 // GetUser godoc
@@ -87,7 +174,13 @@ func GetUser(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	var user models.User
 	if err := initializers.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "User not found"})
+		response.Fail(c, response.ErrUserNotFound)
+		return
+	}
+
+	roles, err := services.UserRoles(initializers.DB, user)
+	if err != nil {
+		response.Fail(c, response.Internal("Failed to retrieve user roles"))
 		return
 	}
 
@@ -97,16 +190,20 @@ func GetUser(c *gin.Context) {
 		Email: user.Email,
 		Roles: user.Roles.String(),
 		SoldeJetons : user.SoldeJetons,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		MFAEnabled: user.TOTPEnabledAt != nil,
+		AvailableRoles: services.RoleNames(roles),
+		Language: user.Language,
 	})
 }
 
 // UpdateUser godoc
-// @Summary Update current user info
-// @Description Update information for the currently authenticated user
+// @Description Partially update the currently authenticated user (JSON Merge Patch). Only the name and the preferred language (fr, en, or null to follow Accept-Language) can be changed here; unknown fields are rejected
+// @Description Partially update the currently authenticated user (JSON Merge Patch). Only the name can be changed here; unknown fields are rejected
 // @Tags Users
 // @Accept json
 // @Produce json
-// @Param user body models.User true "Updated user data"
+// @Param user body requests.UpdateMeRequest true "Fields to update"
 // @Success 200 {object} response.UserResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
@@ -114,21 +211,31 @@ func GetUser(c *gin.Context) {
 // @Security Bearer
 // @Param Authorization header string true "Insert your access token" default(Bearer )
 // @Router /api/users/me [put]
+// @Router /api/users/me [patch]
 func UpdateUser(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	var user models.User
 	if err := initializers.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "User not found"})
+		response.Fail(c, response.ErrUserNotFound)
 		return
 	}
 
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request format"})
+	var req requests.UpdateMeRequest
+	if err := requests.BindStrict(c, &req); err != nil {
+		response.Fail(c, response.BindingError(err))
 		return
 	}
 
-	if err := initializers.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to update user"})
+	req.Name.Apply(&user.Name)
+	if req.Language.Set {
+		user.Language = ""
+		if req.Language.Value != nil {
+			user.Language = *req.Language.Value
+		}
+	}
+
+	if err := initializers.DB.Model(&user).Select("name", "language").Updates(&user).Error; err != nil {
+		response.Fail(c, response.Internal("Failed to update user"))
 		return
 	}
 
@@ -137,6 +244,7 @@ func UpdateUser(c *gin.Context) {
 		Name:  user.Name,
 		Email: user.Email,
 		Roles: user.Roles.String(),
+		Language: user.Language,
 	})
 }
 
@@ -156,18 +264,18 @@ func UpdateUser(c *gin.Context) {
 func DeleteUser(c *gin.Context) {
 	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid user ID"})
+		response.Fail(c, response.ErrInvalidID)
 		return
 	}
 
 	var user models.User
 	if err := initializers.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "User not found"})
+		response.Fail(c, response.ErrUserNotFound)
 		return
 	}
 
 	if err := initializers.DB.Delete(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to delete user"})
+		response.Fail(c, response.Internal("Failed to delete user"))
 		return
 	}
 
@@ -204,7 +312,7 @@ func GetUsersForPointsAttribution(c *gin.Context) {
         Select("parents.id, parents.user_id, parents.points_accumules, users.name").
         Joins("JOIN users ON users.id = parents.user_id").
         Scan(&parents).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to fetch parents"})
+        response.Fail(c, response.Internal("Failed to fetch parents"))
         return
     }
 
@@ -212,7 +320,7 @@ func GetUsersForPointsAttribution(c *gin.Context) {
         Select("eleves.id, eleves.user_id, eleves.points_accumules, users.name").
         Joins("JOIN users ON users.id = eleves.user_id").
         Scan(&students).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "Failed to fetch students"})
+        response.Fail(c, response.Internal("Failed to fetch students"))
         return
     }
 
@@ -258,13 +366,13 @@ func GetUsersForPointsAttribution(c *gin.Context) {
 func GetActivityStandsForUser(c *gin.Context) {
     userID, err := strconv.Atoi(c.Param("id"))
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        response.Fail(c, response.ErrInvalidID)
         return
     }
 
     userType := c.Query("userType")
     if userType != "parent" && userType != "student" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user type"})
+        response.Fail(c, response.ErrInvalidUserType)
         return
     }
 
@@ -282,7 +390,7 @@ func GetActivityStandsForUser(c *gin.Context) {
     }
 
     if err := query.Distinct().Find(&stands).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve activity stands"})
+        response.Fail(c, response.Internal("Failed to retrieve activity stands"))
         return
     }
 
@@ -302,7 +410,7 @@ func GetActivityStandsForUser(c *gin.Context) {
 func GetAllStudentsWithParentsAndUsers(c *gin.Context) {
     var students []models.Eleve
     if err := initializers.DB.Preload("Parent").Preload("Parent.User").Preload("User").Find(&students).Error; err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Échec de la récupération des élèves, parents et utilisateurs"})
+        response.Fail(c, response.Internal("Failed to retrieve students, parents and users"))
         return
     }
 
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"example/hello/internal/apis/services"
+	"example/hello/internal/initializers"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Taille maximale des corps de requête et de réponse conservés pour le journal
+const maxAuditBody = 64 << 10
+
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	if w.body.Len() < maxAuditBody {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	if w.body.Len() < maxAuditBody {
+		w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Audit journalise une opération sur la cible désignée par le paramètre :id de la route,
+// avec l'état de la cible avant et après le handler. targetType vide : seule la requête est conservée.
+// À placer après JWTProtected pour connaître l'auteur.
+func Audit(action, targetType string) gin.HandlerFunc {
+	return audit(action, targetType, func(c *gin.Context) string { return c.Param("id") }, false)
+}
+
+// AuditCreate journalise une création : l'identifiant de la cible est lu dans la réponse du handler
+func AuditCreate(action, targetType string) gin.HandlerFunc {
+	return audit(action, targetType, nil, true)
+}
+
+// AuditSelf journalise une opération de l'utilisateur connecté sur son propre compte
+func AuditSelf(action string) gin.HandlerFunc {
+	return audit(action, "user", func(c *gin.Context) string { return strconv.FormatUint(uint64(c.GetUint("userID")), 10) }, false)
+}
+
+func audit(action, targetType string, target func(*gin.Context) string, create bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestBody := readAuditedBody(c)
+
+		targetID := ""
+		var before map[string]interface{}
+		if !create {
+			targetID = target(c)
+			before = services.AuditSnapshot(initializers.DB, targetType, targetID)
+		}
+
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		// Seules les opérations abouties sont journalisées ; les refus d'accès relèvent des événements d'authentification
+		status := writer.Status()
+		if status >= 400 {
+			return
+		}
+
+		if create {
+			targetID = idFromResponse(writer.body.Bytes())
+		}
+		after := services.AuditSnapshot(initializers.DB, targetType, targetID)
+
+		entry := services.AuditEntry{
+			ActorRole:  c.GetString("userRole"),
+			Action:     action,
+			TargetType: targetType,
+			TargetID:   targetID,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			StatusCode: status,
+			Request:    requestBody,
+		}
+		if actorID, ok := c.Get("userID"); ok {
+			if id, ok := actorID.(uint); ok {
+				entry.ActorID = &id
+			}
+		}
+		services.RecordAudit(entry, before, after)
+	}
+}
+
+// readAuditedBody lit le corps JSON de la requête et le remet en place pour le handler
+func readAuditedBody(c *gin.Context) []byte {
+	// application/json et application/merge-patch+json
+	if c.Request.Body == nil || !strings.HasSuffix(c.ContentType(), "json") {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBody+1))
+	rest := c.Request.Body
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), rest))
+	if err != nil || len(data) > maxAuditBody {
+		return nil
+	}
+	return data
+}
+
+// idFromResponse cherche l'identifiant de l'objet créé dans une réponse JSON
+func idFromResponse(body []byte) string {
+	var document map[string]interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return ""
+	}
+	for _, key := range []string{"id", "ID"} {
+		if value, ok := document[key]; ok {
+			if number, ok := value.(float64); ok {
+				return fmt.Sprintf("%d", int64(number))
+			}
+		}
+	}
+	return ""
+}
@@ -1,53 +1,102 @@
 package middleware
 
 import (
-	"fmt"
-	"net/http"
+	"errors"
+	"example/hello/i18n"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"example/hello/internal/token"
+	"example/hello/response"
 	"strings"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
-// JWTProtected crée un middleware qui protège les routes en vérifiant le JWT
-func JWTProtected(secretKey string) gin.HandlerFunc {
+// JWTProtected crée un middleware qui protège les routes en vérifiant le JWT.
+// Les jetons restreints (portée "spend" des comptes enfants) ne sont acceptés
+// que sur les routes qui listent explicitement l'une de leurs portées.
+func JWTProtected(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
-			c.Abort()
+			response.Fail(c, response.ErrUnauthenticated.WithMessage("Authorization header is missing"))
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(secretKey), nil
-		})
+		tokenString, found := strings.CutPrefix(authHeader, "Bearer ")
+		if !found || strings.TrimSpace(tokenString) == "" {
+			response.Fail(c, response.ErrUnauthenticated.WithMessage("Authorization header must use the Bearer scheme"))
+			return
+		}
 
+		claims, err := token.Parse(strings.TrimSpace(tokenString))
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			if errors.Is(err, token.ErrExpired) {
+				response.Fail(c, response.ErrTokenExpired)
+			} else {
+				response.Fail(c, response.ErrTokenInvalid)
+			}
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			userID := uint(claims["id"].(float64))
-			role := claims["role"].(string)
-			c.Set("userID", userID)
-			c.Set("userRole", role)
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+		if isTokenRevoked(claims.ID) {
+			response.Fail(c, response.ErrTokenRevoked)
 			return
 		}
 
+		if claims.Scope != "" && !scopeAllowed(claims.Scopes(), scopes) {
+			response.Fail(c, response.ErrTokenScope)
+			return
+		}
+
+		c.Set("userID", claims.UserID)
+		c.Set("userRole", claims.Role)
+		c.Set("userRoles", claims.AllRoles())
+		c.Set("tokenID", claims.ID)
+		c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
+		c.Set("tokenScope", claims.Scope)
+		if claims.KermesseID != nil {
+			c.Set("kermesseID", *claims.KermesseID)
+		}
+		if language := userLanguage(claims.UserID); language != "" {
+			c.Set(i18n.ContextKey, language)
+		}
+
 		c.Next()
 	}
 }
 
+func scopeAllowed(tokenScopes, routeScopes []string) bool {
+	for _, tokenScope := range tokenScopes {
+		for _, routeScope := range routeScopes {
+			if tokenScope == routeScope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isTokenRevoked vérifie si le jti figure dans la liste des jetons révoqués
+func isTokenRevoked(jti string) bool {
+	var count int64
+	if err := initializers.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		// En cas d'erreur de base de données, on refuse le jeton par précaution
+		return true
+	}
+	return count > 0
+}
+
+// userLanguage lit la langue enregistrée par l'utilisateur ; elle n'est pas dans le JWT pour
+// s'appliquer dès qu'elle est modifiée
+func userLanguage(userID uint) string {
+	var languages []string
+	if err := initializers.DB.Model(&models.User{}).Where("id = ?", userID).Limit(1).Pluck("language", &languages).Error; err != nil || len(languages) == 0 {
+		return ""
+	}
+	return languages[0]
+}
+
 // Fonction utilitaire pour vérifier si un rôle est présent dans la liste des rôles requis
 /*func contains(roles []string, role string) bool {
 	for _, r := range roles {
@@ -58,30 +107,30 @@ func JWTProtected(secretKey string) gin.HandlerFunc {
 	return false
 }*/
 
+// RBACMiddleware autorise l'accès si l'un des rôles détenus par l'utilisateur figure dans la liste
 func RBACMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userRole, exists := c.Get("userRole")
+		userRoles, exists := c.Get("userRoles")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
-			c.Abort()
+			response.Fail(c, response.ErrUnauthenticated)
 			return
 		}
 
-		role, ok := userRole.(string)
+		roles, ok := userRoles.([]string)
 		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid role type"})
-			c.Abort()
+			response.Fail(c, response.Internal("Invalid role type"))
 			return
 		}
 
-		for _, allowedRole := range allowedRoles {
-			if role == allowedRole {
-				c.Next()
-				return
+		for _, role := range roles {
+			for _, allowedRole := range allowedRoles {
+				if role == allowedRole {
+					c.Next()
+					return
+				}
 			}
 		}
 
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		c.Abort()
+		response.Fail(c, response.ErrForbidden)
 	}
 }
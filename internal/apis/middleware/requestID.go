@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"example/hello/response"
+	"log"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Identifiant fourni par un proxy ou par l'application mobile ; tout autre format est remplacé
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+// RequestID attribue à chaque requête un identifiant, renvoyé dans l'en-tête X-Request-ID et
+// dans les erreurs, pour rapprocher un signalement des journaux du serveur
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			buf := make([]byte, 16)
+			if _, err := rand.Read(buf); err != nil {
+				log.Println("Erreur lors de la génération de l'identifiant de requête:", err)
+			}
+			requestID = hex.EncodeToString(buf)
+		}
+
+		c.Set(response.RequestIDKey, requestID)
+		c.Header(requestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// Recovery transforme une panique en erreur INTERNAL_ERROR dans l'enveloppe commune
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		log.Printf("Panique pendant la requête %s: %v", c.GetString(response.RequestIDKey), recovered)
+		response.Fail(c, response.ErrInternal)
+	})
+}
@@ -0,0 +1,381 @@
+// Package pagination fournit la pagination par curseur, les filtres et le tri communs aux listes de l'API.
+//
+// Chaque liste déclare un Spec : les filtres acceptés, les clés de tri et leurs colonnes. Les
+// paramètres limit, cursor et sort sont communs ; tout autre paramètre doit être un filtre déclaré.
+// Le curseur est opaque : il encode la clé de tri ainsi que la valeur de tri et l'identifiant du
+// dernier élément renvoyé, pour reprendre la liste juste après lui même si des lignes sont ajoutées.
+package pagination
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"example/hello/internal/initializers"
+	"example/hello/response"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Parser convertit la valeur d'un filtre ; une erreur InvalidValue précise le message renvoyé au client
+type Parser func(raw string) (interface{}, error)
+
+// Filter est un paramètre de requête accepté par une liste
+type Filter struct {
+	Param string
+	Parse Parser
+	Apply func(query *gorm.DB, value interface{}) *gorm.DB
+}
+
+// Spec décrit une liste paginée
+type Spec struct {
+	// Model est le modèle listé ; il donne le type des colonnes de tri
+	Model   interface{}
+	Filters []Filter
+	// Sorts associe une clé du paramètre sort à sa colonne ; l'identifiant départage les égalités
+	Sorts map[string]string
+	// DefaultSort est la clé de tri par défaut, préfixée par "-" pour un tri décroissant
+	DefaultSort  string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Params est le résultat de la lecture des paramètres d'une liste, à passer à Find
+type Params struct {
+	filters []appliedFilter
+	sort    string
+	field   *schema.Field
+	primary *schema.Field
+	table   string
+	desc    bool
+	limit   int
+	after   *cursor
+}
+
+type appliedFilter struct {
+	filter Filter
+	value  interface{}
+}
+
+type cursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v,omitempty"`
+	ID    uint64          `json:"id"`
+	value interface{}
+}
+
+// InvalidValue est renvoyée par un Parser ; Message peut être un format complété par Args
+type InvalidValue struct {
+	Code    string
+	Message string
+	Args    []interface{}
+}
+
+func (e InvalidValue) Error() string {
+	return fmt.Sprintf(e.Message, e.Args...)
+}
+
+// Parse lit les paramètres limit, cursor, sort et les filtres de la requête
+func Parse(c *gin.Context, spec Spec) (Params, error) {
+	// Un Spec invalide est une erreur de programmation : la panique est convertie en INTERNAL_ERROR
+	stmt := &gorm.Statement{DB: initializers.DB}
+	if err := stmt.Parse(spec.Model); err != nil {
+		panic(fmt.Sprintf("pagination: %v", err))
+	}
+	params := Params{primary: stmt.Schema.PrioritizedPrimaryField, table: stmt.Schema.Table}
+
+	known := map[string]bool{"limit": true, "cursor": true, "sort": true}
+	for _, filter := range spec.Filters {
+		known[filter.Param] = true
+	}
+	unknown := make([]string, 0)
+	for param := range c.Request.URL.Query() {
+		if !known[param] {
+			unknown = append(unknown, param)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return Params{}, response.InvalidField(unknown[0], "unknown", "This field is not accepted")
+	}
+
+	for _, filter := range spec.Filters {
+		raw := strings.TrimSpace(c.Query(filter.Param))
+		if raw == "" {
+			continue
+		}
+		value, err := filter.Parse(raw)
+		if err != nil {
+			var invalid InvalidValue
+			if errors.As(err, &invalid) {
+				return Params{}, response.InvalidField(filter.Param, invalid.Code, invalid.Message, invalid.Args...)
+			}
+			return Params{}, response.InvalidField(filter.Param, "invalid", "Invalid value")
+		}
+		params.filters = append(params.filters, appliedFilter{filter: filter, value: value})
+	}
+
+	params.limit = spec.DefaultLimit
+	if params.limit == 0 {
+		params.limit = DefaultLimit
+	}
+	maxLimit := spec.MaxLimit
+	if maxLimit == 0 {
+		maxLimit = MaxLimit
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return Params{}, response.InvalidField("limit", "gt", "Must be a positive integer")
+		}
+		params.limit = min(limit, maxLimit)
+	}
+
+	params.sort = spec.DefaultSort
+	if raw := c.Query("sort"); raw != "" {
+		params.sort = raw
+	}
+	key, desc := strings.CutPrefix(params.sort, "-")
+	column, ok := spec.Sorts[key]
+	if !ok {
+		return Params{}, response.InvalidField("sort", "oneof", "Must be one of: %s", sortKeys(spec.Sorts))
+	}
+	params.desc = desc
+	params.field = stmt.Schema.LookUpField(column)
+	if params.field == nil || params.primary == nil {
+		panic(fmt.Sprintf("pagination: unknown column %q in %s", column, stmt.Schema.Name))
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		after, err := decodeCursor(raw, params)
+		if err != nil {
+			return Params{}, response.InvalidField("cursor", "invalid", "Invalid or expired cursor")
+		}
+		params.after = after
+	}
+
+	return params, nil
+}
+
+// Find applique les filtres, le tri et le curseur à query puis charge une page dans dest (pointeur vers une slice du modèle)
+func Find(query *gorm.DB, params Params, dest interface{}) (response.PageMeta, error) {
+	page := response.PageMeta{Limit: params.limit, Sort: params.sort}
+
+	for _, applied := range params.filters {
+		query = applied.filter.Apply(query, applied.value)
+	}
+
+	operator := ">"
+	if params.desc {
+		operator = "<"
+	}
+	sortColumn := params.column(params.field)
+	idColumn := params.column(params.primary)
+
+	if params.after != nil {
+		if params.field == params.primary {
+			query = query.Where(gorm.Expr("? "+operator+" ?", idColumn, params.after.ID))
+		} else {
+			query = query.Where(gorm.Expr("(?, ?) "+operator+" (?, ?)", sortColumn, idColumn, params.after.value, params.after.ID))
+		}
+	}
+	if params.field != params.primary {
+		query = query.Order(clause.OrderByColumn{Column: sortColumn, Desc: params.desc})
+	}
+	query = query.Order(clause.OrderByColumn{Column: idColumn, Desc: params.desc})
+
+	if err := query.Limit(params.limit + 1).Find(dest).Error; err != nil {
+		return page, err
+	}
+
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() > params.limit {
+		rows.Set(rows.Slice(0, params.limit))
+		page.HasMore = true
+
+		last := reflect.Indirect(rows.Index(rows.Len() - 1))
+		next, err := encodeCursor(params, last)
+		if err != nil {
+			return page, err
+		}
+		page.NextCursor = next
+	}
+	if rows.IsNil() {
+		// Une page vide est renvoyée comme [] et non null
+		rows.Set(reflect.MakeSlice(rows.Type(), 0, 0))
+	}
+
+	return page, nil
+}
+
+// RequireParent vérifie que la ressource dont on liste les éléments existe et répond notFound sinon.
+// Une liste vide n'est une erreur que lorsque le parent est absent.
+func RequireParent(c *gin.Context, model interface{}, id interface{}, notFound response.APIError) bool {
+	var count int64
+	if err := initializers.DB.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		response.Fail(c, response.ErrInternal)
+		return false
+	}
+	if count == 0 {
+		response.Fail(c, notFound)
+		return false
+	}
+	return true
+}
+
+// column qualifie la colonne par sa table, les requêtes pouvant comporter des jointures
+func (p Params) column(field *schema.Field) clause.Column {
+	return clause.Column{Table: p.table, Name: field.DBName}
+}
+
+func encodeCursor(params Params, last reflect.Value) (string, error) {
+	ctx := context.Background()
+	id, _ := params.primary.ValueOf(ctx, last)
+	after := cursor{Sort: params.sort}
+
+	switch value := id.(type) {
+	case uint:
+		after.ID = uint64(value)
+	case uint64:
+		after.ID = value
+	default:
+		return "", fmt.Errorf("pagination: unsupported primary key type %T", id)
+	}
+
+	if params.field != params.primary {
+		value, _ := params.field.ValueOf(ctx, last)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		after.Value = raw
+	}
+
+	raw, err := json.Marshal(after)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(raw string, params Params) (*cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var after cursor
+	if err := json.Unmarshal(decoded, &after); err != nil {
+		return nil, err
+	}
+	// Un curseur n'est valable que pour le tri qui l'a produit
+	if after.Sort != params.sort || after.ID == 0 {
+		return nil, errors.New("cursor does not match the requested sort")
+	}
+	if params.field != params.primary {
+		value := reflect.New(params.field.FieldType)
+		if err := json.Unmarshal(after.Value, value.Interface()); err != nil {
+			return nil, err
+		}
+		after.value = value.Elem().Interface()
+	}
+	return &after, nil
+}
+
+func sortKeys(sorts map[string]string) string {
+	keys := make([]string, 0, len(sorts))
+	for key := range sorts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, " ")
+}
+
+// Equal filtre sur l'égalité d'une colonne
+func Equal(param, column string, parse Parser) Filter {
+	return Where(param, column+" = ?", parse)
+}
+
+// Where filtre avec une clause SQL à un seul paramètre, par exemple une sous-requête
+func Where(param, clause string, parse Parser) Filter {
+	return Filter{
+		Param: param,
+		Parse: parse,
+		Apply: func(query *gorm.DB, value interface{}) *gorm.DB {
+			return query.Where(clause, value)
+		},
+	}
+}
+
+// From garde les lignes dont la date est postérieure ou égale au paramètre (RFC 3339)
+func From(param, column string) Filter {
+	return Where(param, column+" >= ?", Time)
+}
+
+// To garde les lignes dont la date est strictement antérieure au paramètre (RFC 3339)
+func To(param, column string) Filter {
+	return Where(param, column+" < ?", Time)
+}
+
+// ID accepte un identifiant numérique
+func ID(raw string) (interface{}, error) {
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || id == 0 {
+		return nil, InvalidValue{Code: "numeric", Message: "Must be a positive integer"}
+	}
+	return uint(id), nil
+}
+
+// Text accepte la valeur telle quelle
+func Text(raw string) (interface{}, error) {
+	return raw, nil
+}
+
+// Bool accepte true ou false
+func Bool(raw string) (interface{}, error) {
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, InvalidValue{Code: "boolean", Message: "Must be true or false"}
+	}
+	return value, nil
+}
+
+// Time accepte une date RFC 3339
+func Time(raw string) (interface{}, error) {
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, InvalidValue{Code: "datetime", Message: "Must be an RFC 3339 date"}
+	}
+	return value, nil
+}
+
+// OneOf accepte les clés de values, sans tenir compte de la casse, et renvoie la valeur associée
+func OneOf(values map[string]interface{}) Parser {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	allowed := strings.Join(names, " ")
+
+	return func(raw string) (interface{}, error) {
+		value, ok := values[strings.ToUpper(raw)]
+		if !ok {
+			return nil, InvalidValue{Code: "oneof", Message: "Must be one of: %s", Args: []interface{}{allowed}}
+		}
+		return value, nil
+	}
+}
@@ -3,10 +3,12 @@ package router
 import (
 	"example/hello/internal/apis/controller/auth"
 	"example/hello/internal/apis/controller/gagnant"
+	"example/hello/internal/apis/controller/invitations"
 	"example/hello/internal/apis/controller/jetons"
 	"example/hello/internal/apis/controller/kermesses"
 	"example/hello/internal/apis/controller/lot"
 	"example/hello/internal/apis/controller/messages"
+	"example/hello/internal/apis/controller/offline"
 	"example/hello/internal/apis/controller/payment"
 	"example/hello/internal/apis/controller/stands"
 	"example/hello/internal/apis/controller/stock"
@@ -14,158 +16,213 @@ import (
 	"example/hello/internal/apis/controller/users"
 	"example/hello/internal/apis/controller/parents"
 	"example/hello/internal/apis/middleware"
-	"os"
+	"example/hello/internal/token"
 
 	"github.com/gin-gonic/gin"
 )
 
 func PublicRoutes(r *gin.Engine) {
-	secretKey := os.Getenv("SECRET_KEY")
 	api := r.Group("/api")
 	{
 		api.POST("/register", auth.Register)
 		api.POST("/login", auth.Login)
-		api.POST("/logout", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), auth.Logout)
+		api.POST("/login/pin", auth.LoginWithPIN)
+		api.POST("/login/qr", auth.LoginWithQRCode)
+		api.POST("/login/mfa", auth.VerifyLoginMFA)
+		api.POST("/login/mfa/enroll", auth.StartLoginMFAEnrollment)
+		api.POST("/login/mfa/enroll/confirm", auth.ConfirmLoginMFAEnrollment)
+		api.POST("/token/refresh", auth.RefreshToken)
+		api.POST("/email/verify", auth.VerifyEmail)
+		api.POST("/email/verify/resend", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), auth.ResendVerificationEmail)
+		api.POST("/password/forgot", auth.ForgotPassword)
+		api.POST("/password/reset", auth.ResetPassword)
+		api.POST("/logout", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), auth.Logout)
 	}
 
 }
 
 func UserRoutes(r *gin.Engine) {
-	secretKey := os.Getenv("SECRET_KEY")
-
 	api := r.Group("/api")
 	{
-		api.POST("/users", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN"), users.CreateUser)
-		api.GET("/users", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN"), users.GetUsers)
-		api.GET("/users/me", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), users.GetUser)
-		api.PUT("/users/me", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), users.UpdateUser)
-		api.DELETE("/users/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN"), users.DeleteUser)
-		api.GET("/users/:id/jeton-transactions", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "ORGANISATEUR"), jetons.GetUserTransactions)
-		api.GET("/users/:id/messages", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "TENEUR_STAND", "ORGANISATEUR"), messages.GetUserMessages)
-		api.GET("/users/:id/messages/unread", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "TENEUR_STAND", "ORGANISATEUR"), messages.GetUnreadMessages)
-		api.GET("/conversations/:userId1/:userId2", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "TENEUR_STAND", "ORGANISATEUR"), messages.GetConversation)
-		api.GET("/users/for-points-attribution", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("TENEUR_STAND"), users.GetUsersForPointsAttribution)
-		api.GET("/users/activity-stands", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("TENEUR_STAND"), users.GetUsersForPointsAttribution)
-		api.GET("/users/parents/students", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN","ORGANISATEUR"), users.GetAllStudentsWithParentsAndUsers)
-		api.POST("/parents/me/children", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "PARENT"), auth.AddChildToParent)
+		api.POST("/users", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), middleware.AuditCreate("user.create", "user"), users.CreateUser)
+		api.GET("/users", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), users.GetUsers)
+		api.GET("/users/me", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), users.GetUser)
+		api.PUT("/users/me", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.AuditSelf("user.update"), users.UpdateUser)
+		api.PATCH("/users/me", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.AuditSelf("user.update"), users.UpdateUser)
+		api.GET("/users/me/sessions", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), auth.GetSessions)
+		api.DELETE("/users/me/sessions/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.Audit("session.revoke", "session"), auth.RevokeSession)
+		api.POST("/users/me/mfa", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.AuditSelf("mfa.enroll"), auth.StartMFAEnrollment)
+		api.POST("/users/me/mfa/confirm", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.AuditSelf("mfa.confirm"), auth.ConfirmMFAEnrollment)
+		api.POST("/users/me/mfa/recovery-codes", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.AuditSelf("mfa.regenerate_recovery_codes"), auth.RegenerateRecoveryCodes)
+		api.POST("/users/me/mfa/disable", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.AuditSelf("mfa.disable"), auth.DisableMFA)
+		api.POST("/users/me/active-role", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.AuditSelf("user.switch_role"), auth.SwitchActiveRole)
+		api.GET("/users/me/export", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), users.ExportMyData)
+		api.POST("/users/me/erasure-request", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.AuditCreate("erasure_request.create", "erasure_request"), users.RequestErasure)
+		api.DELETE("/users/me/erasure-request", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), middleware.AuditSelf("erasure_request.cancel"), users.CancelErasure)
+		api.GET("/erasure-requests", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), users.GetErasureRequests)
+		api.POST("/erasure-requests/:id/process", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), middleware.Audit("erasure_request.process", "erasure_request"), users.ProcessErasureRequest)
+		api.POST("/users/import", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "ORGANISATEUR"), middleware.Audit("user.import", ""), users.ImportRoster)
+		api.DELETE("/users/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), middleware.Audit("user.delete", "user"), users.DeleteUser)
+		api.POST("/users/:id/unlock", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), middleware.Audit("user.unlock", "user"), auth.UnlockUser)
+		api.DELETE("/users/:id/mfa", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), middleware.Audit("user.reset_mfa", "user"), auth.ResetUserMFA)
+		api.POST("/users/:id/roles", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), middleware.Audit("user.grant_role", "user"), auth.GrantUserRole)
+		api.DELETE("/users/:id/roles/:role", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), middleware.Audit("user.revoke_role", "user"), auth.RevokeUserRole)
+		api.GET("/auth-events", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), auth.GetAuthEvents)
+		api.GET("/audit-logs", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), auth.GetAuditLogs)
+		api.GET("/audit-logs/verify", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), auth.VerifyAuditLogs)
+		api.GET("/users/:id/jeton-transactions", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "ORGANISATEUR"), jetons.GetUserTransactions)
+		api.GET("/users/:id/messages", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "TENEUR_STAND", "ORGANISATEUR"), messages.GetUserMessages)
+		api.GET("/users/:id/messages/unread", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "TENEUR_STAND", "ORGANISATEUR"), messages.GetUnreadMessages)
+		api.GET("/conversations/:userId1/:userId2", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "TENEUR_STAND", "ORGANISATEUR"), messages.GetConversation)
+		api.GET("/users/for-points-attribution", middleware.JWTProtected(), middleware.RBACMiddleware("TENEUR_STAND"), users.GetUsersForPointsAttribution)
+		api.GET("/users/activity-stands", middleware.JWTProtected(), middleware.RBACMiddleware("TENEUR_STAND"), users.GetUsersForPointsAttribution)
+		api.GET("/users/parents/students", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN","ORGANISATEUR"), users.GetAllStudentsWithParentsAndUsers)
+		api.POST("/parents/me/children", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "PARENT"), middleware.Audit("eleve.create", ""), auth.AddChildToParent)
+		api.POST("/parents/me/children/:id/pin", middleware.JWTProtected(), middleware.RBACMiddleware("PARENT"), middleware.Audit("eleve.reset_pin", "eleve"), auth.ResetChildPIN)
+		api.POST("/parents/me/children/:id/login-code", middleware.JWTProtected(), middleware.RBACMiddleware("PARENT"), middleware.Audit("eleve.issue_login_code", "eleve"), auth.IssueChildLoginCode)
 
 	}
 }
 
 func ParentRoutes(r *gin.Engine) {
-	secretKey := os.Getenv("SECRET_KEY")
-
 	api := r.Group("/api")
 	{
 
-        api.GET("/children/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN","PARENT","ORGANISATEUR"), parents.GetChildren)
-        api.GET("/parents/user/me", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN","PARENT","ORGANISATEUR"), parents.GetParentId)
-		api.GET("/parents/:id/children", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN","PARENT","ORGANISATEUR"), parents.GetChildrenForParent)
-		api.GET("/children/:id/interactions", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "PARENT", "ORGANISATEUR"), parents.GetChildInteractions)
-		api.GET("/parents/:id/children/interactions", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "PARENT", "ORGANISATEUR"), parents.GetAllChildrenInteractionsForParent)
+        api.GET("/children/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN","PARENT","ORGANISATEUR"), parents.GetChildren)
+        api.GET("/parents/user/me", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN","PARENT","ORGANISATEUR"), parents.GetParentId)
+		api.GET("/parents/:id/children", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN","PARENT","ORGANISATEUR"), parents.GetChildrenForParent)
+		api.GET("/children/:id/interactions", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "PARENT", "ORGANISATEUR"), parents.GetChildInteractions)
+		api.GET("/parents/:id/children/interactions", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "PARENT", "ORGANISATEUR"), parents.GetAllChildrenInteractionsForParent)
+		api.GET("/children/:id/guardians", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "PARENT", "ORGANISATEUR"), parents.GetChildGuardians)
+		api.POST("/children/:id/guardians", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "PARENT"), middleware.Audit("guardian.add", "eleve"), parents.AddChildGuardian)
+		api.PUT("/children/:id/guardians/:parentId", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "PARENT"), middleware.Audit("guardian.update", "eleve"), parents.UpdateChildGuardian)
+		api.DELETE("/children/:id/guardians/:parentId", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "PARENT"), middleware.Audit("guardian.remove", "eleve"), parents.RemoveChildGuardian)
+		api.POST("/children/:id/erasure-request", middleware.JWTProtected(), middleware.RBACMiddleware("PARENT"), middleware.AuditCreate("erasure_request.create", "erasure_request"), users.RequestChildErasure)
+		api.GET("/children/:id/spending-limit", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "PARENT", "ORGANISATEUR"), parents.GetChildSpendingLimit)
+		api.PUT("/children/:id/spending-limit", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN", "PARENT"), middleware.Audit("eleve.set_spending_limit", "eleve"), parents.SetChildSpendingLimit)
 
 	}
 }
 
 
 func KermesseRoutes(r *gin.Engine) {
-	secretKey := os.Getenv("SECRET_KEY")
-
 	api := r.Group("/api")
 
 	{
-		api.POST("/kermesses", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), kermesses.CreateKermesse)
-		api.GET("/kermesses", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), kermesses.GetKermesses)
-		api.GET("/kermesses/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), kermesses.GetKermesse)
-		api.PUT("/kermesses/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), kermesses.UpdateKermesse)
-		api.DELETE("/kermesses/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), kermesses.DeleteKermesse)
-		api.GET("/kermesses/:id/plan", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), kermesses.GetKermessePlan)
-		api.GET("/kermesses/:id/stands", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), kermesses.GetKermesseStands)
+		api.POST("/kermesses", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.AuditCreate("kermesse.create", "kermesse"), kermesses.CreateKermesse)
+		api.GET("/kermesses", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), kermesses.GetKermesses)
+		api.GET("/kermesses/:id", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), kermesses.GetKermesse)
+		api.PUT("/kermesses/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("kermesse.update", "kermesse"), kermesses.UpdateKermesse)
+		api.PATCH("/kermesses/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("kermesse.update", "kermesse"), kermesses.UpdateKermesse)
+		api.DELETE("/kermesses/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("kermesse.delete", "kermesse"), kermesses.DeleteKermesse)
+		api.GET("/kermesses/:id/plan", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), kermesses.GetKermessePlan)
+		api.GET("/kermesses/:id/stands", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), kermesses.GetKermesseStands)
 	}
 
 }
 
 func StandRoutes(r *gin.Engine) {
-	secretKey := os.Getenv("SECRET_KEY")
-
 	api := r.Group("/api")
 
 	{
-		api.POST("/stands", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stands.CreateStand)
-		api.GET("/stands", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stands.GetAllStands)
-		api.GET("/stands/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN", "PARENT","ELEVE"), stands.GetStand)
-		api.PUT("/stands/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stands.UpdateStand)
-		api.DELETE("/stands/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stands.DeleteStand)
-		api.POST("/stands/:id/stock", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stands.ManageStock)
-		api.POST("/stands/:id/jetons", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stands.CollectJetons)
-		api.POST("/stands/points", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stands.AttributePoints)
-		api.GET("/stands/:id/jeton-transactions", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), jetons.GetStandTransactions)
+		api.POST("/stands", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.AuditCreate("stand.create", "stand"), stands.CreateStand)
+		api.GET("/stands", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stands.GetAllStands)
+		api.GET("/stands/:id", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN", "PARENT","ELEVE"), stands.GetStand)
+		api.PUT("/stands/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stand.update", "stand"), stands.UpdateStand)
+		api.PATCH("/stands/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stand.update", "stand"), stands.UpdateStand)
+		api.DELETE("/stands/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stand.delete", "stand"), stands.DeleteStand)
+		api.POST("/stands/:id/stock", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stand.manage_stock", "stand"), stands.ManageStock)
+		api.POST("/stands/:id/jetons", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stand.collect_jetons", "stand"), stands.CollectJetons)
+		api.POST("/stands/points", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stand.attribute_points", ""), stands.AttributePoints)
+		api.GET("/stands/:id/jeton-transactions", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), jetons.GetStandTransactions)
+		api.POST("/stands/:id/offline/consents", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ADMIN", "ELEVE", "PARENT", "TENEUR_STAND", "ORGANISATEUR"), offline.CreateOfflineConsent)
+		api.POST("/stands/:id/offline/allowances", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.AuditCreate("offline_allowance.create", "offline_allowance"), offline.CreateOfflineAllowance)
+		api.POST("/stands/:id/offline/sync", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("offline.sync", "stand"), offline.SyncOfflineSales)
 	}
 
 }
 
 func StockRoutes(r *gin.Engine) {
-	secretKey := os.Getenv("SECRET_KEY")
-
 	api := r.Group("/api")
 
 	{
-		api.POST("/stands/:id/stocks", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stock.CreateStock)
-		api.GET("/stocks", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("TENEUR_STAND", "ADMIN"), stock.GetAllStocks)
-		api.GET("/stands/:id/stocks", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stock.GetStocksByStand)
-		api.PUT("/stocks/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stock.UpdateStock)
-		api.DELETE("/stocks/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stock.DeleteStock)
-		api.POST("/stocks/:id/adjust", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stock.AdjustStock)
+		api.POST("/stands/:id/stocks", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.AuditCreate("stock.create", "stock"), stock.CreateStock)
+		api.GET("/stocks", middleware.JWTProtected(), middleware.RBACMiddleware("TENEUR_STAND", "ADMIN"), stock.GetAllStocks)
+		api.GET("/stands/:id/stocks", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), stock.GetStocksByStand)
+		api.PUT("/stocks/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stock.update", "stock"), stock.UpdateStock)
+		api.PATCH("/stocks/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stock.update", "stock"), stock.UpdateStock)
+		api.DELETE("/stocks/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stock.delete", "stock"), stock.DeleteStock)
+		api.POST("/stocks/:id/adjust", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("stock.adjust", "stock"), stock.AdjustStock)
 
 	}
 
 }
 
 func TombolaRoutes(r *gin.Engine) {
-	secretKey := os.Getenv("SECRET_KEY")
 	api := r.Group("/api")
 
 	{
-		api.POST("/kermesses/:id/tombolas", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), tombola.CreateTombola)
-		api.GET("/kermesses/:id/tombolas", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN","ELEVE", "PARENT"), tombola.GetKermesseTombolas)
-		api.GET("/tombolas/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN","ELEVE", "PARENT"), tombola.GetTombola)
-		api.GET("/tombolas", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), tombola.GetAllTombolas)
-		api.POST("/tombolas/:id/tickets", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("PARENT", "ELEVE", "ADMIN"), tombola.BuyTicket)
-		api.GET("/tombolas/tickets", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), tombola.GetAllTickets)
-		api.GET("/tombolas/:id/user/:userId/tickets", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "ELEVE", "PARENT"), tombola.GetUserTickets)
-		api.POST("/tombolas/:id/draw", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), tombola.PerformDraw)
-		api.GET("/tombolas/:id/gagnants", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "TENEUR_STAND", "ELEVE", "PARENT"), gagnant.GetWinners)
-		api.GET("/tombolas/:id/gagnants/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "TENEUR_STAND", "ELEVE", "PARENT"), gagnant.GetWinner)
-		api.POST("/tombolas/:id/lots", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), lot.CreateLot)
-		api.GET("/tombolas/:id/lots", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "TENEUR_STAND", "ELEVE", "PARENT"), lot.GetLots)
-		api.PUT("/tombolas/lots/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), lot.UpdateLot)
-		api.DELETE("/tombolas/lots/:id", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), lot.DeleteLot)
+		api.POST("/kermesses/:id/tombolas", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.AuditCreate("tombola.create", "tombola"), tombola.CreateTombola)
+		api.GET("/kermesses/:id/tombolas", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN","ELEVE", "PARENT"), tombola.GetKermesseTombolas)
+		api.GET("/tombolas/:id", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN","ELEVE", "PARENT"), tombola.GetTombola)
+		api.PUT("/tombolas/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("tombola.update", "tombola"), tombola.UpdateTombola)
+		api.PATCH("/tombolas/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("tombola.update", "tombola"), tombola.UpdateTombola)
+		api.GET("/tombolas", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), tombola.GetAllTombolas)
+		api.POST("/tombolas/:id/tickets", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("PARENT", "ELEVE", "ADMIN"), middleware.Audit("tombola.buy_ticket", "tombola"), tombola.BuyTicket)
+		api.GET("/tombolas/tickets", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), tombola.GetAllTickets)
+		api.GET("/tombolas/:id/user/:userId/tickets", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "ELEVE", "PARENT"), tombola.GetUserTickets)
+		api.POST("/tombolas/:id/draw", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("tombola.draw", "tombola"), tombola.PerformDraw)
+		api.POST("/tombolas/:id/draw/void", middleware.JWTProtected(), middleware.RBACMiddleware("ADMIN"), middleware.Audit("tombola.void_draw", "tombola"), tombola.VoidDraw)
+		api.GET("/tombolas/:id/draw/verification", tombola.VerifyDraw)
+		api.GET("/tombolas/:id/draw/live", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "TENEUR_STAND", "ELEVE", "PARENT"), messages.WatchDraw)
+		api.GET("/tombolas/:id/draw/live/display", messages.WatchDraw)
+		api.POST("/tombolas/:id/cancel", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("tombola.cancel", "tombola"), tombola.CancelTombola)
+		api.GET("/tombolas/:id/sales-report", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), tombola.GetTombolaSalesReport)
+		api.GET("/tombolas/:id/gagnants", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "TENEUR_STAND", "ELEVE", "PARENT"), gagnant.GetWinners)
+		api.GET("/tombolas/:id/gagnants/:id", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "TENEUR_STAND", "ELEVE", "PARENT"), gagnant.GetWinner)
+		api.POST("/tombolas/gagnants/:id/claim", middleware.JWTProtected(), middleware.RBACMiddleware("PARENT", "ELEVE", "ADMIN"), middleware.Audit("gagnant.claim", "gagnant"), gagnant.ClaimPrize)
+		api.POST("/tombolas/:id/handover", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("gagnant.handover", "tombola"), gagnant.HandOverPrize)
+		api.POST("/tombolas/:id/redraw", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("tombola.redraw", "tombola"), gagnant.RedrawUnclaimedPrizes)
+		api.POST("/tombolas/:id/lots", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.AuditCreate("lot.create", "lot"), lot.CreateLot)
+		api.GET("/tombolas/:id/lots", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "TENEUR_STAND", "ELEVE", "PARENT"), lot.GetLots)
+		api.PUT("/tombolas/lots/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("lot.update", "lot"), lot.UpdateLot)
+		api.PATCH("/tombolas/lots/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("lot.update", "lot"), lot.UpdateLot)
+		api.DELETE("/tombolas/lots/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("lot.delete", "lot"), lot.DeleteLot)
 
 	}
 
 }
 func JetonsTransactionRoutes(r *gin.Engine) {
-	secretKey := os.Getenv("SECRET_KEY")
 	api := r.Group("/api")
 
 	{
-		api.POST("/jeton-transactions", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), jetons.CreateJetonTransaction)
-		api.POST("/jeton-transaction/buy", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "PARENT", "ELEVE"), jetons.BuyJetons)
-		api.POST("/jeton-transaction/transfer", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "PARENT"), jetons.AttributeJetonsToChild)
-		api.GET("/jeton-transactions/summary", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "PARENT"), jetons.GetTransactionSummary)
-		api.POST("/jeton-transactions/pay-with-jetons", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "PARENT", "ELEVE"), jetons.PayWithJetons)
+		api.POST("/jeton-transactions", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.AuditCreate("jeton_transaction.create", "jeton_transaction"), jetons.CreateJetonTransaction)
+		api.POST("/jeton-transaction/buy", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "PARENT", "ELEVE"), middleware.Audit("jeton.buy", ""), jetons.BuyJetons)
+		api.POST("/jeton-transaction/transfer", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "PARENT"), middleware.Audit("jeton.transfer", ""), jetons.AttributeJetonsToChild)
+		api.GET("/jeton-transactions/summary", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "PARENT"), jetons.GetTransactionSummary)
+		api.POST("/jeton-transactions/pay-with-jetons", middleware.JWTProtected(token.ScopeSpend), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN", "PARENT", "ELEVE"), middleware.Audit("jeton.pay", ""), jetons.PayWithJetons)
 	}
 
 }
 
-func MessageRoutes(r *gin.Engine) {
-	secretKey := os.Getenv("SECRET_KEY")
+func InvitationRoutes(r *gin.Engine) {
+	api := r.Group("/api")
 
+	{
+		api.POST("/invitations", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.AuditCreate("invitation.create", "invitation"), invitations.CreateInvitation)
+		api.GET("/invitations", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), invitations.GetInvitations)
+		api.DELETE("/invitations/:id", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "ADMIN"), middleware.Audit("invitation.revoke", "invitation"), invitations.RevokeInvitation)
+		api.POST("/invitations/accept", invitations.AcceptInvitation)
+	}
+
+}
+
+func MessageRoutes(r *gin.Engine) {
 	api := r.Group("/api")
 
 	{
-		api.POST("/messages", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), messages.SendMessage)
-		api.PUT("/messages/:id/read", middleware.JWTProtected(secretKey), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), messages.MarkMessageAsRead)
+		api.POST("/messages", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.AuditCreate("message.send", "message"), messages.SendMessage)
+		api.PUT("/messages/:id/read", middleware.JWTProtected(), middleware.RBACMiddleware("ORGANISATEUR", "TENEUR_STAND", "ADMIN"), middleware.Audit("message.read", "message"), messages.MarkMessageAsRead)
 		api.GET("/ws/:user_id", messages.HandleWebSocket)
 	}
 
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Verrou applicatif Postgres qui sérialise l'ajout des entrées du journal d'audit
+const auditChainLock = 7_300_038
+
+var errStopVerification = errors.New("audit chain broken")
+
+type auditTarget struct {
+	model   func() interface{}
+	preload []string
+}
+
+var auditTargets = map[string]auditTarget{
+	"user":              {model: func() interface{} { return &models.User{} }},
+	"eleve":             {model: func() interface{} { return &models.Eleve{} }},
+	"kermesse":          {model: func() interface{} { return &models.Kermesse{} }},
+	"stand":             {model: func() interface{} { return &models.Stand{} }, preload: []string{"Stocks"}},
+	"stock":             {model: func() interface{} { return &models.Stock{} }},
+	"tombola":           {model: func() interface{} { return &models.Tombola{} }},
+	"lot":               {model: func() interface{} { return &models.Lot{} }},
+	"gagnant":           {model: func() interface{} { return &models.Gagnant{} }},
+	"jeton_transaction": {model: func() interface{} { return &models.JetonTransaction{} }},
+	"offline_allowance": {model: func() interface{} { return &models.OfflineAllowance{} }},
+	"invitation":        {model: func() interface{} { return &models.Invitation{} }},
+	"erasure_request":   {model: func() interface{} { return &models.ErasureRequest{} }},
+	"message":           {model: func() interface{} { return &models.Message{} }},
+}
+
+// AuditEntry décrit une opération à journaliser
+type AuditEntry struct {
+	ActorID    *uint
+	ActorRole  string
+	Action     string
+	TargetType string
+	TargetID   string
+	Method     string
+	Path       string
+	StatusCode int
+	Request    []byte
+}
+
+// sensitiveKey désigne les secrets et les données personnelles : le journal ne pouvant pas être
+// anonymisé, ils n'y sont jamais écrits
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "pin", "code", "recovery_code", "qr_code", "name", "email", "username", "contenu":
+		return true
+	}
+	return strings.Contains(key, "password") || strings.Contains(key, "token") || strings.Contains(key, "secret")
+}
+
+// scrub masque les champs sensibles à tous les niveaux d'un document JSON
+func scrub(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if sensitiveKey(key) {
+				v[key] = "***"
+			} else {
+				v[key] = scrub(inner)
+			}
+		}
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = scrub(inner)
+		}
+	}
+	return value
+}
+
+// ScrubAuditRequest renvoie le corps JSON de la requête sans ses champs sensibles ; un corps non JSON est ignoré
+func ScrubAuditRequest(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return ""
+	}
+	scrubbed, _ := json.Marshal(scrub(document))
+	return string(scrubbed)
+}
+
+// AuditSnapshot charge l'état courant de la cible sous forme de champs JSON ; nil si la cible n'existe pas
+func AuditSnapshot(db *gorm.DB, targetType, targetID string) map[string]interface{} {
+	target, ok := auditTargets[targetType]
+	if !ok || targetID == "" {
+		return nil
+	}
+	id, err := strconv.ParseUint(targetID, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	record := target.model()
+	query := db
+	for _, association := range target.preload {
+		query = query.Preload(association)
+	}
+	if err := query.First(record, id).Error; err != nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+
+	for key, value := range fields {
+		// Les associations non préchargées sont vides et n'apportent rien au diff
+		if _, nested := value.(map[string]interface{}); nested || value == nil {
+			delete(fields, key)
+		}
+	}
+	scrub(fields)
+	return fields
+}
+
+// AuditDiff ne garde que les champs modifiés ; une création n'a pas d'état avant, une suppression pas d'état après
+func AuditDiff(before, after map[string]interface{}) map[string]interface{} {
+	changes := make(map[string]interface{})
+	for key, value := range before {
+		if other, ok := after[key]; !ok || !reflect.DeepEqual(value, other) {
+			changes[key] = map[string]interface{}{"before": value, "after": after[key]}
+		}
+	}
+	for key, value := range after {
+		if _, ok := before[key]; !ok {
+			changes[key] = map[string]interface{}{"before": nil, "after": value}
+		}
+	}
+	return changes
+}
+
+func auditHash(entry models.AuditLog) string {
+	actor := ""
+	if entry.ActorID != nil {
+		actor = strconv.FormatUint(uint64(*entry.ActorID), 10)
+	}
+	payload := strings.Join([]string{
+		entry.PrevHash,
+		actor,
+		entry.ActorRole,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.Method,
+		entry.Path,
+		strconv.Itoa(entry.StatusCode),
+		entry.Request,
+		entry.Changes,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}, "\x1f")
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordAudit ajoute une entrée chaînée au journal d'audit. Une erreur est journalisée sans
+// interrompre la requête, l'opération auditée étant déjà validée.
+func RecordAudit(entry AuditEntry, before, after map[string]interface{}) {
+	changes := ""
+	if before != nil || after != nil {
+		if diff := AuditDiff(before, after); len(diff) > 0 {
+			raw, _ := json.Marshal(diff)
+			changes = string(raw)
+		}
+	}
+
+	auditLog := models.AuditLog{
+		ActorID:    entry.ActorID,
+		ActorRole:  entry.ActorRole,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Method:     entry.Method,
+		Path:       entry.Path,
+		StatusCode: entry.StatusCode,
+		Request:    ScrubAuditRequest(entry.Request),
+		Changes:    changes,
+		// Postgres conserve les dates à la microseconde : le hash doit porter sur la valeur stockée
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+			return err
+		}
+		var last models.AuditLog
+		if err := tx.Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		auditLog.PrevHash = last.Hash
+		auditLog.Hash = auditHash(auditLog)
+		return tx.Create(&auditLog).Error
+	})
+	if err != nil {
+		log.Println("Erreur lors de l'enregistrement du journal d'audit:", err)
+	}
+}
+
+// AuditChainReport est le résultat de la vérification du chaînage du journal
+type AuditChainReport struct {
+	Valid         bool
+	Checked       int
+	FirstBrokenID *uint
+	Reason        string
+}
+
+// VerifyAuditChain recalcule le hash de chaque entrée, par identifiant croissant, et vérifie le lien avec la précédente
+func VerifyAuditChain(db *gorm.DB) (AuditChainReport, error) {
+	report := AuditChainReport{Valid: true}
+	previous := ""
+
+	var batch []models.AuditLog
+	result := db.FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			reason := ""
+			switch {
+			case entry.PrevHash != previous:
+				reason = "previous hash does not match"
+			case entry.Hash != auditHash(entry):
+				reason = "entry content does not match its hash"
+			}
+			if reason != "" {
+				id := entry.ID
+				report.Valid = false
+				report.FirstBrokenID = &id
+				report.Reason = fmt.Sprintf("entry %d: %s", entry.ID, reason)
+				return errStopVerification
+			}
+			previous = entry.Hash
+			report.Checked++
+		}
+		return nil
+	})
+	if result.Error != nil && !errors.Is(result.Error, errStopVerification) {
+		return report, result.Error
+	}
+	return report, nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"example/hello/internal/models"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	childPINLength       = 4
+	usernameAttempts     = 20
+	maxUsernameBaseRunes = 20
+)
+
+var ErrUsernameUnavailable = errors.New("could not generate a unique username")
+
+func randomDigits(length int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte('0' + n.Int64()))
+	}
+	return b.String(), nil
+}
+
+var accentReplacer = strings.NewReplacer(
+	"à", "a", "â", "a", "ä", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"î", "i", "ï", "i", "ô", "o", "ö", "o", "ù", "u", "û", "u", "ü", "u", "ÿ", "y",
+)
+
+// usernameBase réduit un prénom à des lettres minuscules sans accents : "Léa-Marie" devient "leamarie"
+func usernameBase(name string) string {
+	var b strings.Builder
+	for _, r := range accentReplacer.Replace(strings.ToLower(name)) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+		if b.Len() >= maxUsernameBaseRunes {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "eleve"
+	}
+	return b.String()
+}
+
+// GenerateChildUsername propose un identifiant libre de la forme "prenom.1234"
+func GenerateChildUsername(tx *gorm.DB, name string) (string, error) {
+	base := usernameBase(name)
+	for i := 0; i < usernameAttempts; i++ {
+		digits, err := randomDigits(4)
+		if err != nil {
+			return "", err
+		}
+		username := fmt.Sprintf("%s.%s", base, digits)
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return username, nil
+		}
+	}
+	return "", ErrUsernameUnavailable
+}
+
+// GenerateChildPIN renvoie un code PIN à quatre chiffres et son hash
+func GenerateChildPIN() (string, string, error) {
+	pin, err := randomDigits(childPINLength)
+	if err != nil {
+		return "", "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+	return pin, string(hash), nil
+}
+
+// PlaceholderEmail attribue une adresse non routable aux comptes créés sans e-mail
+func PlaceholderEmail() (string, error) {
+	suffix, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("eleve-%s@%s", suffix, PlaceholderEmailDomain), nil
+}
+
+// ChildOfParent charge l'élève si l'utilisateur connecté en est tuteur avec tous les droits
+func ChildOfParent(db *gorm.DB, parentUserID interface{}, eleveID int) (models.Eleve, error) {
+	if err := RequireGuardian(db, parentUserID, uint(eleveID), models.GuardianPermission.CanManage); err != nil {
+		return models.Eleve{}, err
+	}
+	var eleve models.Eleve
+	err := db.Preload("User").First(&eleve, eleveID).Error
+	return eleve, err
+}
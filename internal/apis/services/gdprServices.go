@@ -0,0 +1,337 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"example/hello/internal/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// Domaine réservé (RFC 2606) des adresses des comptes anonymisés
+	ErasedEmailDomain = "supprime.invalid"
+	ErasedUserName    = "Utilisateur supprimé"
+	erasedMessage     = "[message supprimé]"
+)
+
+var ErrAlreadyErased = errors.New("account already anonymised")
+
+type exportProfile struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Username        *string    `json:"username,omitempty"`
+	ActiveRole      string     `json:"active_role"`
+	Roles           []string   `json:"roles"`
+	SoldeJetons     int64      `json:"solde_jetons"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	MFAEnabled      bool       `json:"mfa_enabled"`
+}
+
+type exportChild struct {
+	exportProfile
+	Permission        models.GuardianPermission `json:"permission"`
+	PointsAccumules   int                       `json:"points_accumules"`
+	PlafondJournalier *int64                    `json:"plafond_journalier"`
+	Transactions      []exportTransaction       `json:"transactions,omitempty"`
+	Tickets           []exportTicket            `json:"tickets,omitempty"`
+	Gains             []exportGain              `json:"gains,omitempty"`
+}
+
+type exportTransaction struct {
+	ID          uint      `json:"id"`
+	Type        string    `json:"type"`
+	Montant     int64     `json:"montant"`
+	Description string    `json:"description"`
+	StandID     *uint     `json:"stand_id"`
+	TombolaID   *uint     `json:"tombola_id"`
+	Date        time.Time `json:"date"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type exportTicket struct {
+	ID           uint   `json:"id"`
+	TombolaID    *uint  `json:"tombola_id"`
+	Numero       string `json:"numero"`
+	EstGagnant   bool   `json:"est_gagnant"`
+	EstRembourse bool   `json:"est_rembourse"`
+	PrixEnJetons int    `json:"prix_en_jetons"`
+}
+
+type exportGain struct {
+	ID           uint       `json:"id"`
+	TombolaID    uint       `json:"tombola_id"`
+	TicketID     uint       `json:"ticket_id"`
+	LotID        uint       `json:"lot_id"`
+	Lot          string     `json:"lot"`
+	Statut       string     `json:"statut"`
+	ClaimedAt    *time.Time `json:"claimed_at"`
+	HandedOverAt *time.Time `json:"handed_over_at"`
+}
+
+type exportSession struct {
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func profileOf(db *gorm.DB, user models.User) (exportProfile, error) {
+	roles, err := UserRoles(db, user)
+	if err != nil {
+		return exportProfile{}, err
+	}
+	email := user.Email
+	if IsPlaceholderEmail(email) {
+		email = ""
+	}
+	return exportProfile{
+		ID:              user.ID,
+		Name:            user.Name,
+		Email:           email,
+		Username:        user.Username,
+		ActiveRole:      user.Roles.String(),
+		Roles:           RoleNames(roles),
+		SoldeJetons:     user.SoldeJetons,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		MFAEnabled:      user.TOTPEnabledAt != nil,
+	}, nil
+}
+
+// activity charge l'historique financier d'un utilisateur
+func activity(db *gorm.DB, userID uint) ([]exportTransaction, []exportTicket, []exportGain, error) {
+	var transactions []models.JetonTransaction
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&transactions).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	var tickets []models.Ticket
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&tickets).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	var gains []models.Gagnant
+	if err := db.Where("user_id = ?", userID).Preload("Lot").Order("id").Find(&gains).Error; err != nil {
+		return nil, nil, nil, err
+	}
+
+	exportedTransactions := make([]exportTransaction, 0, len(transactions))
+	for _, t := range transactions {
+		exportedTransactions = append(exportedTransactions, exportTransaction{
+			ID:          t.ID,
+			Type:        string(t.Type),
+			Montant:     t.Montant,
+			Description: t.Description,
+			StandID:     t.StandID,
+			TombolaID:   t.TombolaID,
+			Date:        t.Date,
+			CreatedAt:   t.CreatedAt,
+		})
+	}
+	exportedTickets := make([]exportTicket, 0, len(tickets))
+	for _, t := range tickets {
+		exportedTickets = append(exportedTickets, exportTicket{
+			ID:           t.ID,
+			TombolaID:    t.TombolaID,
+			Numero:       t.Numero,
+			EstGagnant:   t.EstGagnant,
+			EstRembourse: t.EstRembourse,
+			PrixEnJetons: t.PrixEnJetons,
+		})
+	}
+	exportedGains := make([]exportGain, 0, len(gains))
+	for _, g := range gains {
+		exportedGains = append(exportedGains, exportGain{
+			ID:           g.ID,
+			TombolaID:    g.TombolaID,
+			TicketID:     g.TicketID,
+			LotID:        g.LotID,
+			Lot:          g.Lot.Nom,
+			Statut:       string(g.Statut),
+			ClaimedAt:    g.ClaimedAt,
+			HandedOverAt: g.HandedOverAt,
+		})
+	}
+	return exportedTransactions, exportedTickets, exportedGains, nil
+}
+
+// BuildUserExport rassemble les données personnelles d'un utilisateur dans une archive zip de fichiers JSON
+func BuildUserExport(db *gorm.DB, userID uint) ([]byte, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]interface{})
+
+	profile, err := profileOf(db, user)
+	if err != nil {
+		return nil, err
+	}
+	files["profile.json"] = profile
+
+	transactions, tickets, gains, err := activity(db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	files["transactions.json"] = transactions
+	files["tickets.json"] = tickets
+	files["gains.json"] = gains
+
+	messages := []models.Message{}
+	if err := db.Where("expediteur_id = ? OR destinataire_id = ?", user.ID, user.ID).Order("date").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	files["messages.json"] = messages
+
+	children := []exportChild{}
+	var parent models.Parent
+	if err := db.Where("user_id = ?", user.ID).First(&parent).Error; err == nil {
+		guardianships, err := GuardedChildren(db, parent.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, guardianship := range guardianships {
+			childProfile, err := profileOf(db, guardianship.Eleve.User)
+			if err != nil {
+				return nil, err
+			}
+			child := exportChild{
+				exportProfile:     childProfile,
+				Permission:        guardianship.Permission,
+				PointsAccumules:   guardianship.Eleve.PointsAccumules,
+				PlafondJournalier: guardianship.Eleve.PlafondJournalier,
+			}
+			// L'historique des enfants n'est exporté que pour les tuteurs autorisés à le consulter
+			if guardianship.Permission.CanView() {
+				if child.Transactions, child.Tickets, child.Gains, err = activity(db, guardianship.Eleve.UserID); err != nil {
+					return nil, err
+				}
+			}
+			children = append(children, child)
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	files["children.json"] = children
+
+	var refreshTokens []models.RefreshToken
+	if err := db.Where("user_id = ?", user.ID).Order("created_at").Find(&refreshTokens).Error; err != nil {
+		return nil, err
+	}
+	sessions := make([]exportSession, 0, len(refreshTokens))
+	for _, rt := range refreshTokens {
+		sessions = append(sessions, exportSession{
+			DeviceName: rt.DeviceName,
+			UserAgent:  rt.UserAgent,
+			IPAddress:  rt.IPAddress,
+			CreatedAt:  rt.CreatedAt,
+			LastUsedAt: rt.LastUsedAt,
+			RevokedAt:  rt.RevokedAt,
+		})
+	}
+	files["sessions.json"] = sessions
+
+	authEvents := []models.AuthEvent{}
+	if err := db.Where("user_id = ?", user.ID).Order("created_at").Find(&authEvents).Error; err != nil {
+		return nil, err
+	}
+	files["auth_events.json"] = authEvents
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, name := range []string{"profile.json", "children.json", "transactions.json", "tickets.json", "gains.json", "messages.json", "sessions.json", "auth_events.json"} {
+		w, err := archive.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AnonymiseUser efface les données personnelles d'un compte. Les transactions, tickets, gains et
+// ventes hors ligne sont conservés pour la comptabilité : ils restent liés à l'identifiant, qui ne
+// désigne plus personne.
+func AnonymiseUser(tx *gorm.DB, userID uint) error {
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.AnonymisedAt != nil {
+		return ErrAlreadyErased
+	}
+
+	password, err := UnusablePassword()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	erasedEmail := fmt.Sprintf("user-%d@%s", user.ID, ErasedEmailDomain)
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"name":              ErasedUserName,
+		"email":             erasedEmail,
+		"username":          nil,
+		"pin_hash":          "",
+		"password":          password,
+		"email_verified_at": nil,
+		"failed_logins":     0,
+		"locked_until":      nil,
+		"totp_secret":       "",
+		"totp_enabled_at":   nil,
+		"totp_last_step":    0,
+		"anonymised_at":     now,
+	}).Error; err != nil {
+		return err
+	}
+
+	// Plus aucune session ni lien envoyé par e-mail ne doit rester utilisable
+	for _, model := range []interface{}{&models.RefreshToken{}, &models.UserToken{}, &models.RecoveryCode{}} {
+		if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&models.AuthEvent{}).
+		Where("user_id = ? OR LOWER(email) = LOWER(?)", user.ID, user.Email).
+		Updates(map[string]interface{}{"email": erasedEmail, "ip_address": "", "user_agent": ""}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Invitation{}).
+		Where("LOWER(email) = LOWER(?)", user.Email).
+		Update("email", erasedEmail).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Message{}).
+		Where("expediteur_id = ? OR destinataire_id = ?", user.ID, user.ID).
+		Update("contenu", erasedMessage).Error; err != nil {
+		return err
+	}
+
+	// Un tuteur anonymisé ne suit plus aucun enfant ; ses enfants gardent leurs autres tuteurs
+	var parent models.Parent
+	if err := tx.Where("user_id = ?", user.ID).First(&parent).Error; err == nil {
+		if err := tx.Where("parent_id = ?", parent.ID).Delete(&models.Guardianship{}).Error; err != nil {
+			return err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return nil
+}
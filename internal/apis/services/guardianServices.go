@@ -0,0 +1,220 @@
+package services
+
+import (
+	"errors"
+	"example/hello/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotGuardian           = errors.New("not a guardian of this child")
+	ErrGuardianPermission    = errors.New("guardian permission does not allow this action")
+	ErrLastFullGuardian      = errors.New("a child must keep at least one guardian with full permission")
+	ErrSpendingLimitExceeded = errors.New("daily spending limit exceeded")
+)
+
+// GuardianPermission renvoie la permission du parent (identifié par son utilisateur) sur l'élève
+func GuardianPermission(db *gorm.DB, parentUserID interface{}, eleveID uint) (models.GuardianPermission, error) {
+	var guardianship models.Guardianship
+	err := db.Joins("JOIN parents ON parents.id = guardianships.parent_id").
+		Where("guardianships.eleve_id = ? AND parents.user_id = ?", eleveID, parentUserID).
+		First(&guardianship).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrNotGuardian
+	}
+	if err != nil {
+		return "", err
+	}
+	return guardianship.Permission, nil
+}
+
+// RequireGuardian vérifie que le parent dispose de la permission demandée sur l'élève
+func RequireGuardian(db *gorm.DB, parentUserID interface{}, eleveID uint, allowed func(models.GuardianPermission) bool) error {
+	permission, err := GuardianPermission(db, parentUserID, eleveID)
+	if err != nil {
+		return err
+	}
+	if !allowed(permission) {
+		return ErrGuardianPermission
+	}
+	return nil
+}
+
+// RequireSpender vérifie que l'appelant peut dépenser les jetons de l'utilisateur : les siens, ou ceux d'un
+// élève dont il est tuteur avec tous les droits
+func RequireSpender(db *gorm.DB, callerID, userID uint) error {
+	if callerID == userID {
+		return nil
+	}
+	var eleve models.Eleve
+	if err := db.Where("user_id = ?", userID).First(&eleve).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotGuardian
+		}
+		return err
+	}
+	return RequireGuardian(db, callerID, eleve.ID, models.GuardianPermission.CanManage)
+}
+
+// SetGuardian ajoute un tuteur à l'élève ou modifie sa permission
+func SetGuardian(tx *gorm.DB, eleveID, parentID uint, permission models.GuardianPermission) error {
+	guardianship := models.Guardianship{EleveID: eleveID, ParentID: parentID, Permission: permission}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "eleve_id"}, {Name: "parent_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"permission", "updated_at"}),
+	}).Create(&guardianship).Error
+	if err != nil {
+		return err
+	}
+	return ensureFullGuardian(tx, eleveID)
+}
+
+// RemoveGuardian retire un tuteur ; le dernier tuteur avec tous les droits ne peut pas partir
+func RemoveGuardian(tx *gorm.DB, eleveID, parentID uint) error {
+	result := tx.Where("eleve_id = ? AND parent_id = ?", eleveID, parentID).Delete(&models.Guardianship{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotGuardian
+	}
+	if err := ensureFullGuardian(tx, eleveID); err != nil {
+		return err
+	}
+
+	// Le parent de référence de l'élève est repris parmi les tuteurs restants
+	var eleve models.Eleve
+	if err := tx.First(&eleve, eleveID).Error; err != nil {
+		return err
+	}
+	if eleve.ParentID != nil && *eleve.ParentID == parentID {
+		var next models.Guardianship
+		if err := tx.Where("eleve_id = ? AND permission = ?", eleveID, models.GuardianFull).Order("created_at").First(&next).Error; err != nil {
+			return err
+		}
+		return tx.Model(&eleve).Update("parent_id", next.ParentID).Error
+	}
+	return nil
+}
+
+func ensureFullGuardian(tx *gorm.DB, eleveID uint) error {
+	var count int64
+	if err := tx.Model(&models.Guardianship{}).
+		Where("eleve_id = ? AND permission = ?", eleveID, models.GuardianFull).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLastFullGuardian
+	}
+	return nil
+}
+
+// GuardedChildren renvoie les élèves suivis par le parent, avec sa permission sur chacun
+func GuardedChildren(db *gorm.DB, parentID uint) ([]models.Guardianship, error) {
+	var guardianships []models.Guardianship
+	err := db.Where("parent_id = ?", parentID).
+		Preload("Eleve").
+		Preload("Eleve.User").
+		Order("eleve_id").
+		Find(&guardianships).Error
+	return guardianships, err
+}
+
+// Families regroupe les élèves et les tuteurs reliés, directement ou non, par des tutelles : chaque
+// utilisateur concerné est associé au plus petit identifiant d'utilisateur de sa famille
+func Families(db *gorm.DB) (map[uint]uint, error) {
+	var links []struct {
+		EleveUserID  uint
+		ParentUserID uint
+	}
+	if err := db.Table("guardianships").
+		Select("eleves.user_id AS eleve_user_id, parents.user_id AS parent_user_id").
+		Joins("JOIN eleves ON eleves.id = guardianships.eleve_id AND eleves.deleted_at IS NULL").
+		Joins("JOIN parents ON parents.id = guardianships.parent_id AND parents.deleted_at IS NULL").
+		Scan(&links).Error; err != nil {
+		return nil, err
+	}
+
+	roots := make(map[uint]uint)
+	var find func(id uint) uint
+	find = func(id uint) uint {
+		root, ok := roots[id]
+		if !ok || root == id {
+			return id
+		}
+		root = find(root)
+		roots[id] = root
+		return root
+	}
+	for _, link := range links {
+		a, b := find(link.EleveUserID), find(link.ParentUserID)
+		if a == b {
+			continue
+		}
+		// La racine d'une famille reste son plus petit identifiant
+		if a > b {
+			a, b = b, a
+		}
+		roots[a] = a
+		roots[b] = a
+	}
+
+	families := make(map[uint]uint, len(roots))
+	for id := range roots {
+		families[id] = find(id)
+	}
+	return families, nil
+}
+
+func startOfDay(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+}
+
+// SpentToday totalise les achats en jetons de l'utilisateur depuis minuit ; les achats de jetons payés par carte ne comptent pas
+func SpentToday(tx *gorm.DB, userID uint) (int64, error) {
+	var spent int64
+	err := tx.Model(&models.JetonTransaction{}).
+		Select("COALESCE(SUM(montant), 0)").
+		Where("user_id = ? AND type = ? AND paiement_id = '' AND created_at >= ?", userID, models.TransactionTypeAchat, startOfDay(time.Now())).
+		Scan(&spent).Error
+	return spent, err
+}
+
+// RemainingSpendingAllowance renvoie ce que l'utilisateur peut encore dépenser aujourd'hui ; limited vaut false sans plafond
+func RemainingSpendingAllowance(tx *gorm.DB, userID uint) (remaining int64, limited bool, err error) {
+	var eleve models.Eleve
+	err = tx.Where("user_id = ?", userID).First(&eleve).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && eleve.PlafondJournalier == nil) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	spent, err := SpentToday(tx, userID)
+	if err != nil {
+		return 0, false, err
+	}
+	remaining = *eleve.PlafondJournalier - spent
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true, nil
+}
+
+// CheckSpendingLimit refuse une dépense qui ferait dépasser le plafond journalier fixé par les tuteurs
+func CheckSpendingLimit(tx *gorm.DB, userID uint, amount int64) error {
+	remaining, limited, err := RemainingSpendingAllowance(tx, userID)
+	if err != nil {
+		return err
+	}
+	if limited && amount > remaining {
+		return ErrSpendingLimitExceeded
+	}
+	return nil
+}
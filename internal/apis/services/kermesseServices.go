@@ -0,0 +1,52 @@
+package services
+
+import (
+	"example/hello/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// IsKermesseOrganiser indique si l'utilisateur fait partie des organisateurs de la kermesse
+func IsKermesseOrganiser(db *gorm.DB, userID, kermesseID uint) (bool, error) {
+	var count int64
+	err := db.Table("organisateur_kermesses").
+		Joins("JOIN organisateurs ON organisateurs.id = organisateur_kermesses.organisateur_id").
+		Where("organisateurs.user_id = ? AND organisateur_kermesses.kermesse_id = ?", userID, kermesseID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// UserKermesse renvoie la kermesse à laquelle l'utilisateur est rattaché par les stands qu'il tient ou les
+// kermesses qu'il organise ; nil s'il n'est rattaché à aucune ou à plusieurs
+func UserKermesse(db *gorm.DB, userID uint) (*uint, error) {
+	var ids []uint
+	err := db.Raw(`SELECT stands.kermesse_id FROM stands JOIN teneur_stands ON teneur_stands.id = stands.teneur_id WHERE teneur_stands.user_id = ?
+UNION SELECT organisateur_kermesses.kermesse_id FROM organisateur_kermesses JOIN organisateurs ON organisateurs.id = organisateur_kermesses.organisateur_id WHERE organisateurs.user_id = ?`,
+		userID, userID).Scan(&ids).Error
+	if err != nil || len(ids) != 1 {
+		return nil, err
+	}
+	return &ids[0], nil
+}
+
+// CanManageStand indique si l'utilisateur tient le stand ou fait partie des organisateurs de sa kermesse
+func CanManageStand(db *gorm.DB, userID uint, stand models.Stand) (bool, error) {
+	var count int64
+	if err := db.Model(&models.TeneurStand{}).Where("id = ? AND user_id = ?", stand.TeneurID, userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	return IsKermesseOrganiser(db, userID, stand.KermesseID)
+}
+
+// AddKermesseOrganiser rattache le profil organisateur de l'utilisateur à la kermesse
+func AddKermesseOrganiser(tx *gorm.DB, userID, kermesseID uint) error {
+	var organisateur models.Organisateur
+	if err := tx.Where("user_id = ?", userID).First(&organisateur).Error; err != nil {
+		return err
+	}
+	return tx.Exec("INSERT INTO organisateur_kermesses (organisateur_id, kermesse_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		organisateur.ID, kermesseID).Error
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"example/hello/internal/initializers"
+	"example/hello/internal/models"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Nombre d'échecs tolérés avant de ralentir les tentatives
+	freeLoginFailures = 3
+	baseLoginBackoff  = time.Second
+	maxLoginBackoff   = 15 * time.Minute
+	// Durée après laquelle un compteur inactif est oublié
+	throttleEntryTTL = time.Hour
+
+	// Verrouillage du compte après trop d'échecs consécutifs
+	MaxFailedLogins  = 10
+	AccountLockDelay = 30 * time.Minute
+)
+
+type throttleEntry struct {
+	failures     int
+	blockedUntil time.Time
+	lastFailure  time.Time
+}
+
+// loginThrottle applique un délai exponentiel par clé (adresse IP ou e-mail)
+type loginThrottle struct {
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+}
+
+var throttle = &loginThrottle{entries: make(map[string]*throttleEntry)}
+
+func (t *loginThrottle) retryAfter(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[key]
+	if !ok {
+		return 0
+	}
+	if now.Sub(entry.lastFailure) > throttleEntryTTL {
+		delete(t.entries, key)
+		return 0
+	}
+	if entry.blockedUntil.After(now) {
+		return entry.blockedUntil.Sub(now)
+	}
+	return 0
+}
+
+func (t *loginThrottle) fail(key string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[key]
+	if !ok || now.Sub(entry.lastFailure) > throttleEntryTTL {
+		entry = &throttleEntry{}
+		t.entries[key] = entry
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+	if entry.failures > freeLoginFailures {
+		exponent := float64(entry.failures - freeLoginFailures - 1)
+		delay := time.Duration(float64(baseLoginBackoff) * math.Pow(2, exponent))
+		if delay > maxLoginBackoff || delay <= 0 {
+			delay = maxLoginBackoff
+		}
+		entry.blockedUntil = now.Add(delay)
+	}
+
+	// Purge opportuniste des entrées périmées
+	for k, e := range t.entries {
+		if now.Sub(e.lastFailure) > throttleEntryTTL {
+			delete(t.entries, k)
+		}
+	}
+}
+
+func (t *loginThrottle) reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// LoginRetryAfter renvoie le temps à attendre avant une nouvelle tentative pour cette IP ou cet e-mail
+func LoginRetryAfter(ip, email string) time.Duration {
+	now := time.Now()
+	byIP := throttle.retryAfter(ipKey(ip), now)
+	byEmail := throttle.retryAfter(emailKey(email), now)
+	if byIP > byEmail {
+		return byIP
+	}
+	return byEmail
+}
+
+// RecordLoginFailure compte un échec pour l'IP et pour l'e-mail
+func RecordLoginFailure(ip, email string) {
+	now := time.Now()
+	throttle.fail(ipKey(ip), now)
+	throttle.fail(emailKey(email), now)
+}
+
+// ResetLoginFailures efface le compteur d'un e-mail après une connexion réussie ou un déverrouillage
+func ResetLoginFailures(email string) {
+	throttle.reset(emailKey(email))
+}
+
+// RecordAuthEvent enregistre une activité de connexion suspecte
+func RecordAuthEvent(userID *uint, email, ip, userAgent string, event models.AuthEventType, details string) {
+	authEvent := models.AuthEvent{
+		UserID:    userID,
+		Email:     email,
+		IPAddress: ip,
+		UserAgent: userAgent,
+		Event:     event,
+		Details:   details,
+	}
+	if err := initializers.DB.Create(&authEvent).Error; err != nil {
+		log.Println("Erreur lors de l'enregistrement de l'événement d'authentification:", err)
+	}
+}
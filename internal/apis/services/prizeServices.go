@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"example/hello/common"
+	"example/hello/i18n"
+	"example/hello/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// prizeHandoverPurpose est l'usage des jetons signés encodés dans le QR code de remise d'un lot
+const prizeHandoverPurpose = "PRIZE_HANDOVER"
+
+// prizeHandoverTTL borne la validité d'un QR code de remise lorsque la tombola n'a pas de date limite de retrait
+const prizeHandoverTTL = 30 * 24 * time.Hour
+
+var (
+	ErrPrizeNotClaimable      = errors.New("prize can no longer be claimed")
+	ErrPrizeAlreadyHandedOver = errors.New("prize already handed over")
+	ErrClaimDeadlinePassed    = errors.New("claim deadline passed")
+	ErrInvalidHandoverCode    = errors.New("invalid handover code")
+	ErrNoClaimDeadline        = errors.New("no claim deadline set")
+	ErrClaimDeadlineNotPassed = errors.New("claim deadline not passed yet")
+)
+
+// checkClaimable vérifie qu'un gain peut encore être réclamé ou remis à l'instant now
+func checkClaimable(gagnant models.Gagnant, tombola models.Tombola, now time.Time) error {
+	switch {
+	case gagnant.VoidedDrawID != nil || gagnant.Statut == models.PrizeUnclaimed:
+		return ErrPrizeNotClaimable
+	case tombola.Revealing(now):
+		return ErrDrawRevealing
+	case gagnant.Statut == models.PrizeHandedOver:
+		return ErrPrizeAlreadyHandedOver
+	case tombola.DateLimiteRetrait != nil && now.After(*tombola.DateLimiteRetrait):
+		return ErrClaimDeadlinePassed
+	}
+	return nil
+}
+
+// ClaimPrize enregistre la réclamation d'un lot par son gagnant ; réclamer de nouveau un lot déjà réclamé
+// ne change rien. Le QR code de remise s'obtient ensuite avec PrizeHandoverCode.
+func ClaimPrize(db *gorm.DB, gagnantID uint, now time.Time) (models.Gagnant, error) {
+	var gagnant models.Gagnant
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Tombola").First(&gagnant, gagnantID).Error; err != nil {
+			return err
+		}
+		if err := checkClaimable(gagnant, gagnant.Tombola, now); err != nil {
+			return err
+		}
+		if gagnant.Statut == models.PrizeClaimed {
+			return nil
+		}
+
+		gagnant.Statut = models.PrizeClaimed
+		gagnant.ClaimedAt = &now
+		return tx.Model(&gagnant).Updates(map[string]interface{}{
+			"statut":     gagnant.Statut,
+			"claimed_at": gagnant.ClaimedAt,
+		}).Error
+	})
+	return gagnant, err
+}
+
+// PrizeHandoverCode renvoie le contenu du QR code du ticket gagnant, à présenter lors de la remise du lot ;
+// il expire à la date limite de retrait
+func PrizeHandoverCode(gagnant models.Gagnant, tombola models.Tombola, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(prizeHandoverTTL)
+	if tombola.DateLimiteRetrait != nil {
+		expiresAt = *tombola.DateLimiteRetrait
+	}
+	code, err := common.NewSignedToken(prizeHandoverPurpose, gagnant.TicketID, expiresAt)
+	return code, expiresAt, err
+}
+
+// HandOverPrize confirme la remise d'un lot après le scan du QR code du ticket gagnant par staffID
+func HandOverPrize(db *gorm.DB, tombolaID uint, code string, staffID uint, now time.Time) (models.Gagnant, error) {
+	ticketID, err := common.ParseSignedToken(prizeHandoverPurpose, code)
+	switch {
+	case errors.Is(err, common.ErrExpiredSignedToken):
+		return models.Gagnant{}, ErrClaimDeadlinePassed
+	case err != nil:
+		return models.Gagnant{}, ErrInvalidHandoverCode
+	}
+
+	var gagnant models.Gagnant
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Le ticket doit avoir gagné dans cette tombola, lors du tirage en vigueur
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tombola_id = ? AND ticket_id = ? AND voided_draw_id IS NULL", tombolaID, ticketID).
+			First(&gagnant).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidHandoverCode
+			}
+			return err
+		}
+		var tombola models.Tombola
+		if err := tx.First(&tombola, tombolaID).Error; err != nil {
+			return err
+		}
+		if err := checkClaimable(gagnant, tombola, now); err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"statut":            models.PrizeHandedOver,
+			"handed_over_at":    now,
+			"handed_over_by_id": staffID,
+		}
+		if gagnant.ClaimedAt == nil {
+			updates["claimed_at"] = now
+		}
+		if err := tx.Model(&gagnant).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Preload("User").Preload("Lot").Preload("Ticket").First(&gagnant, gagnant.ID).Error
+	})
+	return gagnant, err
+}
+
+// PrizeRedraw est le résultat d'une remise en jeu des lots non retirés
+type PrizeRedraw struct {
+	Unclaimed []models.Gagnant
+	Winners   []models.Gagnant
+}
+
+// RedrawUnclaimedPrizes remet en jeu, une fois la date limite de retrait passée, les lots ni réclamés ni remis.
+// Les remplaçants sont les tickets suivants du tirage publié (voir DrawPicks) : le résultat reste vérifiable
+// sans nouvelle graine. La nouvelle date limite s'applique aux remplaçants.
+func RedrawUnclaimedPrizes(db *gorm.DB, tombolaID uint, deadline, now time.Time) (PrizeRedraw, error) {
+	var redraw PrizeRedraw
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var tombola models.Tombola
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tombola, tombolaID).Error; err != nil {
+			return err
+		}
+		switch {
+		case tombola.Status != models.TombolaDrawn:
+			return ErrTombolaNotDrawn
+		case tombola.DateLimiteRetrait == nil:
+			return ErrNoClaimDeadline
+		case !now.After(*tombola.DateLimiteRetrait):
+			return ErrClaimDeadlineNotPassed
+		}
+
+		var active []models.Gagnant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tombola_id = ? AND voided_draw_id IS NULL", tombola.ID).
+			Order("ordre").Find(&active).Error; err != nil {
+			return err
+		}
+		for _, gagnant := range active {
+			if gagnant.Statut == models.PrizePending || gagnant.Statut == models.PrizeNotified || gagnant.Statut == models.PrizeClaimed {
+				gagnant.Statut = models.PrizeUnclaimed
+				redraw.Unclaimed = append(redraw.Unclaimed, gagnant)
+			}
+		}
+		if len(redraw.Unclaimed) > 0 {
+			ids := make([]uint, 0, len(redraw.Unclaimed))
+			for _, gagnant := range redraw.Unclaimed {
+				ids = append(ids, gagnant.ID)
+			}
+			if err := tx.Model(&models.Gagnant{}).Where("id IN ?", ids).Update("statut", models.PrizeUnclaimed).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := LoadDrawInputs(tx).First(&tombola, tombola.ID).Error; err != nil {
+			return err
+		}
+		tickets := make(map[uint]models.Ticket, len(tombola.Tickets))
+		for _, ticket := range tombola.Tickets {
+			tickets[ticket.ID] = ticket
+		}
+
+		// Chaque gain en vigueur a consommé un ticket du tirage : les remplaçants sont les suivants
+		picks := DrawPicks(&tombola, tombola.DrawPublicValue, len(active)+len(redraw.Unclaimed))
+		for j, unclaimed := range redraw.Unclaimed {
+			k := len(active) + j
+			if k >= len(picks) {
+				break // Plus de tickets éligibles : le lot reste non retiré
+			}
+			ticket := tickets[picks[k]]
+			replaces := unclaimed.ID
+			winner := models.Gagnant{
+				UserID:     ticket.UserID,
+				TicketID:   ticket.ID,
+				LotID:      unclaimed.LotID,
+				TombolaID:  tombola.ID,
+				Ordre:      k + 1,
+				Statut:     models.PrizePending,
+				RemplaceID: &replaces,
+			}
+			if err := tx.Create(&winner).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Ticket{}).Where("id = ?", ticket.ID).Update("est_gagnant", true).Error; err != nil {
+				return err
+			}
+			redraw.Winners = append(redraw.Winners, winner)
+		}
+
+		if err := tx.Model(&tombola).Update("date_limite_retrait", deadline).Error; err != nil {
+			return err
+		}
+
+		if len(redraw.Winners) > 0 {
+			ids := make([]uint, 0, len(redraw.Winners))
+			for _, winner := range redraw.Winners {
+				ids = append(ids, winner.ID)
+			}
+			return tx.Where("id IN ?", ids).
+				Preload("User").Preload("Tombola").Preload("Lot").Preload("Ticket").
+				Order("ordre").Find(&redraw.Winners).Error
+		}
+		return nil
+	})
+	return redraw, err
+}
+
+// PendingNotifications renvoie, par tombola tirée, les gagnants en vigueur qui n'ont pas encore été prévenus,
+// chargés avec User, Tombola, Lot et Ticket
+func PendingNotifications(db *gorm.DB) (map[uint][]models.Gagnant, error) {
+	var pending []models.Gagnant
+	err := db.Joins("JOIN tombolas ON tombolas.id = gagnants.tombola_id").
+		Where("gagnants.statut = ? AND gagnants.voided_draw_id IS NULL AND tombolas.status = ?", models.PrizePending, models.TombolaDrawn).
+		Preload("User").Preload("Tombola").Preload("Lot").Preload("Ticket").
+		Order("gagnants.ordre, gagnants.id").Find(&pending).Error
+	if err != nil {
+		return nil, err
+	}
+	byTombola := make(map[uint][]models.Gagnant)
+	for _, gagnant := range pending {
+		byTombola[gagnant.TombolaID] = append(byTombola[gagnant.TombolaID], gagnant)
+	}
+	return byTombola, nil
+}
+
+// NotifyWinners enregistre le message qui annonce son gain à chaque gagnant, envoyé de la part de senderID,
+// puis marque les gains comme notifiés ; lang donne la langue du destinataire. Les gagnants sont chargés avec
+// User, Tombola, Lot et Ticket.
+func NotifyWinners(db *gorm.DB, senderID uint, winners []models.Gagnant, lang func(models.User) string) ([]models.Message, error) {
+	notifications := make([]models.Message, 0, len(winners))
+	for _, winner := range winners {
+		recipientLang := lang(winner.User)
+		contenu := i18n.Translate(recipientLang, "notification.prize_won", winner.Ticket.Numero, winner.Lot.Nom, winner.Tombola.Nom)
+		if deadline := winner.Tombola.DateLimiteRetrait; deadline != nil {
+			contenu += i18n.Translate(recipientLang, "notification.prize_deadline", deadline.Format(i18n.Translate(recipientLang, "notification.date_format")))
+		}
+		notifications = append(notifications, models.Message{
+			ExpediteurID:   senderID,
+			DestinataireID: winner.UserID,
+			Contenu:        contenu,
+			Date:           time.Now(),
+		})
+	}
+	if len(notifications) == 0 {
+		return notifications, nil
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&notifications).Error; err != nil {
+			return err
+		}
+		ids := make([]uint, 0, len(winners))
+		for _, winner := range winners {
+			ids = append(ids, winner.ID)
+		}
+		// Un gain réclamé entre-temps garde son statut, mais la date de notification est renseignée
+		if err := tx.Model(&models.Gagnant{}).Where("id IN ? AND notified_at IS NULL", ids).Update("notified_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Gagnant{}).Where("id IN ? AND statut = ?", ids, models.PrizePending).Update("statut", models.PrizeNotified).Error
+	})
+	return notifications, err
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"example/hello/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRoleNotHeld  = errors.New("role not held by user")
+	ErrLastUserRole = errors.New("cannot remove the last role of a user")
+)
+
+// UserRoles renvoie les rôles détenus par l'utilisateur, rôle actif compris
+func UserRoles(db *gorm.DB, user models.User) ([]models.Role, error) {
+	var roles []models.Role
+	if err := db.Model(&models.UserRole{}).Where("user_id = ?", user.ID).Order("role").Pluck("role", &roles).Error; err != nil {
+		return nil, err
+	}
+
+	// Les comptes antérieurs aux rôles multiples n'ont que leur rôle actif
+	for _, role := range roles {
+		if role == user.Roles {
+			return roles, nil
+		}
+	}
+	return append(roles, user.Roles), nil
+}
+
+// RoleNames convertit une liste de rôles en libellés
+func RoleNames(roles []models.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.String())
+	}
+	return names
+}
+
+// HasRole indique si le rôle figure dans la liste
+func HasRole(roles []models.Role, role models.Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantRole ajoute un rôle à l'utilisateur et crée le profil correspondant s'il n'existe pas encore
+func GrantRole(tx *gorm.DB, userID uint, role models.Role) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UserRole{UserID: userID, Role: role}).Error; err != nil {
+		return err
+	}
+
+	var profile interface{}
+	switch role {
+	case models.RoleEleve:
+		profile = &models.Eleve{UserID: userID}
+	case models.RoleParent:
+		profile = &models.Parent{UserID: userID}
+	case models.RoleTeneurStand:
+		profile = &models.TeneurStand{UserID: userID}
+	case models.RoleOrganisateur:
+		profile = &models.Organisateur{UserID: userID}
+	default:
+		return nil
+	}
+
+	// Le profil est conservé lors d'un retrait de rôle : il est simplement réutilisé
+	return tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(profile).Error
+}
+
+// RevokeRole retire un rôle ; si c'était le rôle actif, un autre rôle détenu devient actif
+func RevokeRole(tx *gorm.DB, user *models.User, role models.Role) error {
+	roles, err := UserRoles(tx, *user)
+	if err != nil {
+		return err
+	}
+	if !HasRole(roles, role) {
+		return ErrRoleNotHeld
+	}
+	if len(roles) == 1 {
+		return ErrLastUserRole
+	}
+
+	if err := tx.Where("user_id = ? AND role = ?", user.ID, role).Delete(&models.UserRole{}).Error; err != nil {
+		return err
+	}
+
+	if user.Roles == role {
+		for _, r := range roles {
+			if r != role {
+				user.Roles = r
+				break
+			}
+		}
+		if err := tx.Model(user).Update("roles", user.Roles).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
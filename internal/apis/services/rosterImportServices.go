@@ -0,0 +1,374 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"example/hello/internal/models"
+	"net/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// Domaine réservé (RFC 2606) des adresses attribuées aux élèves importés sans e-mail
+	PlaceholderEmailDomain = "eleves.invalid"
+	activationTTL          = 7 * 24 * time.Hour
+)
+
+// RosterRow est une ligne valide de la liste de l'école : un élève et son parent
+type RosterRow struct {
+	Line         int
+	ParentName   string
+	ParentEmail  string
+	StudentName  string
+	StudentEmail string
+}
+
+// RosterRowError décrit une erreur bloquante sur une ligne du fichier
+type RosterRowError struct {
+	Row     int
+	Field   string
+	Message string
+	// Args complète Message lorsque celui-ci est un format ; le message est traduit par le contrôleur
+	Args []interface{}
+}
+
+// RosterImportResult compte les comptes créés ou réutilisés
+type RosterImportResult struct {
+	ParentsCreated   int
+	ParentsExisting  int
+	StudentsCreated  int
+	StudentsExisting int
+	// Activations contient les comptes à qui envoyer un lien d'activation après validation
+	Activations []RosterActivation
+}
+
+type RosterActivation struct {
+	User  models.User
+	Token string
+}
+
+var rosterColumns = map[string][]string{
+	"parent_name":   {"parent_name", "parent_nom", "nom_parent", "parent"},
+	"parent_email":  {"parent_email", "email_parent", "parent_mail", "mail_parent"},
+	"student_name":  {"student_name", "eleve_nom", "nom_eleve", "eleve", "enfant", "nom_enfant"},
+	"student_email": {"student_email", "eleve_email", "email_eleve", "email_enfant"},
+}
+
+func normalizeHeader(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	header = strings.NewReplacer("é", "e", "è", "e", "ê", "e", " ", "_", "-", "_").Replace(header)
+	return header
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+// IsPlaceholderEmail indique si l'adresse a été générée pour un élève sans e-mail
+func IsPlaceholderEmail(email string) bool {
+	return strings.HasSuffix(email, "@"+PlaceholderEmailDomain)
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// UnusablePassword produit un hash de mot de passe que personne ne connaît : le compte s'active par e-mail ou se connecte par code PIN
+func UnusablePassword() (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// ParseRoster valide les lignes du fichier ; la première ligne non vide doit contenir les en-têtes
+func ParseRoster(rows [][]string) ([]RosterRow, []RosterRowError) {
+	var parsed []RosterRow
+	var rowErrors []RosterRowError
+
+	headerLine := -1
+	for i, row := range rows {
+		if strings.TrimSpace(strings.Join(row, "")) != "" {
+			headerLine = i
+			break
+		}
+	}
+	if headerLine < 0 {
+		return nil, []RosterRowError{{Row: 1, Message: "file is empty"}}
+	}
+
+	columns := make(map[string]int)
+	for index, header := range rows[headerLine] {
+		normalized := normalizeHeader(header)
+		for column, aliases := range rosterColumns {
+			for _, alias := range aliases {
+				if normalized == alias {
+					columns[column] = index
+				}
+			}
+		}
+	}
+	for _, required := range []string{"parent_name", "parent_email", "student_name"} {
+		if _, ok := columns[required]; !ok {
+			rowErrors = append(rowErrors, RosterRowError{Row: headerLine + 1, Field: required, Message: "missing column"})
+		}
+	}
+	if len(rowErrors) > 0 {
+		return nil, rowErrors
+	}
+
+	cell := func(row []string, column string) string {
+		index, ok := columns[column]
+		if !ok || index >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[index])
+	}
+
+	seenStudents := make(map[string]int)
+	for i := headerLine + 1; i < len(rows); i++ {
+		row := rows[i]
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		line := i + 1
+		entry := RosterRow{
+			Line:         line,
+			ParentName:   cell(row, "parent_name"),
+			ParentEmail:  normalizeEmail(cell(row, "parent_email")),
+			StudentName:  cell(row, "student_name"),
+			StudentEmail: normalizeEmail(cell(row, "student_email")),
+		}
+
+		valid := true
+		fail := func(field, message string, args ...interface{}) {
+			rowErrors = append(rowErrors, RosterRowError{Row: line, Field: field, Message: message, Args: args})
+			valid = false
+		}
+
+		if entry.ParentName == "" {
+			fail("parent_name", "required")
+		}
+		if entry.ParentEmail == "" {
+			fail("parent_email", "required")
+		} else if !validEmail(entry.ParentEmail) {
+			fail("parent_email", "invalid email")
+		}
+		if entry.StudentName == "" {
+			fail("student_name", "required")
+		}
+		if entry.StudentEmail != "" {
+			if !validEmail(entry.StudentEmail) {
+				fail("student_email", "invalid email")
+			} else if entry.StudentEmail == entry.ParentEmail {
+				fail("student_email", "must differ from the parent email")
+			}
+		}
+
+		// Un même élève ne doit apparaître qu'une fois dans le fichier
+		key := entry.StudentEmail
+		if key == "" {
+			key = entry.ParentEmail + "|" + strings.ToLower(entry.StudentName)
+		}
+		if first, ok := seenStudents[key]; ok {
+			fail("student_name", "duplicate of row %d", first)
+		} else {
+			seenStudents[key] = line
+		}
+
+		if valid {
+			parsed = append(parsed, entry)
+		}
+	}
+
+	return parsed, rowErrors
+}
+
+type rosterImport struct {
+	tx       *gorm.DB
+	result   RosterImportResult
+	parents  map[string]models.Parent
+	password string
+}
+
+// ImportRoster crée ou réutilise les comptes parents et élèves et relie les frères et sœurs au même parent.
+// Les conflits avec les comptes existants sont renvoyés comme erreurs de ligne ; l'appelant décide
+// ensuite de valider ou d'annuler la transaction.
+func ImportRoster(tx *gorm.DB, rows []RosterRow) (RosterImportResult, []RosterRowError, error) {
+	// Un seul hash suffit pour des centaines de comptes : bcrypt est volontairement lent
+	password, err := UnusablePassword()
+	if err != nil {
+		return RosterImportResult{}, nil, err
+	}
+
+	imp := &rosterImport{tx: tx, parents: make(map[string]models.Parent), password: password}
+	var rowErrors []RosterRowError
+
+	for _, row := range rows {
+		parent, err := imp.parent(row)
+		if err != nil {
+			return imp.result, nil, err
+		}
+
+		message, err := imp.student(row, parent)
+		if err != nil {
+			return imp.result, nil, err
+		}
+		if message != "" {
+			rowErrors = append(rowErrors, RosterRowError{Row: row.Line, Field: "student_email", Message: message})
+		}
+	}
+
+	return imp.result, rowErrors, nil
+}
+
+func (imp *rosterImport) activate(user models.User) error {
+	token, err := IssueUserToken(imp.tx, user.ID, models.UserTokenPasswordReset, activationTTL)
+	if err != nil {
+		return err
+	}
+	imp.result.Activations = append(imp.result.Activations, RosterActivation{User: user, Token: token})
+	return nil
+}
+
+func (imp *rosterImport) parent(row RosterRow) (models.Parent, error) {
+	if parent, ok := imp.parents[row.ParentEmail]; ok {
+		return parent, nil
+	}
+
+	var user models.User
+	err := imp.tx.Where("LOWER(email) = ?", row.ParentEmail).First(&user).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user = models.User{Name: row.ParentName, Email: row.ParentEmail, Password: imp.password, Roles: models.RoleParent}
+		if err := imp.tx.Create(&user).Error; err != nil {
+			return models.Parent{}, err
+		}
+		if err := GrantRole(imp.tx, user.ID, models.RoleParent); err != nil {
+			return models.Parent{}, err
+		}
+		if err := imp.activate(user); err != nil {
+			return models.Parent{}, err
+		}
+		imp.result.ParentsCreated++
+	case err != nil:
+		return models.Parent{}, err
+	default:
+		// Un compte existant (teneur de stand, organisateur...) reçoit en plus le rôle parent
+		if err := GrantRole(imp.tx, user.ID, user.Roles); err != nil {
+			return models.Parent{}, err
+		}
+		if err := GrantRole(imp.tx, user.ID, models.RoleParent); err != nil {
+			return models.Parent{}, err
+		}
+		imp.result.ParentsExisting++
+	}
+
+	var parent models.Parent
+	if err := imp.tx.Where("user_id = ?", user.ID).First(&parent).Error; err != nil {
+		return models.Parent{}, err
+	}
+	imp.parents[row.ParentEmail] = parent
+	return parent, nil
+}
+
+// student renvoie un message non vide lorsque la ligne entre en conflit avec un compte existant
+func (imp *rosterImport) student(row RosterRow, parent models.Parent) (string, error) {
+	var existing models.Eleve
+	var err error
+	if row.StudentEmail != "" {
+		var user models.User
+		err = imp.tx.Where("LOWER(email) = ?", row.StudentEmail).First(&user).Error
+		if err == nil {
+			if err := imp.tx.Where("user_id = ?", user.ID).First(&existing).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+				return "email already used by an account that is not a student", nil
+			} else if err != nil {
+				return "", err
+			}
+		}
+	} else {
+		// Sans e-mail, un élève du même nom suivi par le même parent est considéré comme déjà importé
+		err = imp.tx.Joins("JOIN users ON users.id = eleves.user_id").
+			Joins("JOIN guardianships ON guardianships.eleve_id = eleves.id").
+			Where("guardianships.parent_id = ? AND LOWER(users.name) = ?", parent.ID, strings.ToLower(row.StudentName)).
+			First(&existing).Error
+	}
+
+	switch {
+	case err == nil:
+		// Un élève déjà rattaché à un autre parent reçoit un tuteur de plus (parents séparés)
+		if existing.ParentID == nil {
+			if err := imp.tx.Model(&existing).Update("parent_id", parent.ID).Error; err != nil {
+				return "", err
+			}
+		}
+		if err := imp.guardian(existing.ID, parent.ID); err != nil {
+			return "", err
+		}
+		imp.result.StudentsExisting++
+		return "", nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return "", err
+	}
+
+	email := row.StudentEmail
+	if email == "" {
+		var err error
+		if email, err = PlaceholderEmail(); err != nil {
+			return "", err
+		}
+	}
+
+	user := models.User{Name: row.StudentName, Email: email, Password: imp.password, Roles: models.RoleEleve}
+	if err := imp.tx.Create(&user).Error; err != nil {
+		return "", err
+	}
+
+	eleve := models.Eleve{UserID: user.ID, ParentID: &parent.ID}
+	if err := imp.tx.Create(&eleve).Error; err != nil {
+		return "", err
+	}
+	if err := imp.guardian(eleve.ID, parent.ID); err != nil {
+		return "", err
+	}
+	if err := GrantRole(imp.tx, user.ID, models.RoleEleve); err != nil {
+		return "", err
+	}
+
+	if !IsPlaceholderEmail(email) {
+		if err := imp.activate(user); err != nil {
+			return "", err
+		}
+	}
+	imp.result.StudentsCreated++
+	return "", nil
+}
+
+// guardian rattache le parent de la liste à l'élève sans réduire une permission déjà accordée
+func (imp *rosterImport) guardian(eleveID, parentID uint) error {
+	var count int64
+	if err := imp.tx.Model(&models.Guardianship{}).Where("eleve_id = ? AND parent_id = ?", eleveID, parentID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return SetGuardian(imp.tx, eleveID, parentID, models.GuardianFull)
+}
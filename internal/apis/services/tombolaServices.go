@@ -0,0 +1,550 @@
+package services
+
+import (
+	"errors"
+	"example/hello/common"
+	"example/hello/internal/models"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTombolaNotDrawn  = errors.New("tombola not drawn")
+	ErrDrawNotCommitted = errors.New("draw seed not committed")
+	ErrNoTicketsSold    = errors.New("no tickets sold")
+	ErrNoLotsAvailable  = errors.New("no lots available")
+	// ErrPublicValueReused : un nouveau tirage après annulation exige une valeur publique nouvelle
+	ErrPublicValueReused = errors.New("public value of a voided draw reused")
+	// ErrDrawRevealing : les résultats restent cachés pendant la diffusion en direct du tirage
+	ErrDrawRevealing = errors.New("draw reveal in progress")
+
+	ErrTicketSalesNotOpen  = errors.New("ticket sales not open yet")
+	ErrTicketSalesClosed   = errors.New("ticket sales closed")
+	ErrTicketsSoldOut      = errors.New("not enough tickets left")
+	ErrTicketLimitReached  = errors.New("ticket limit per user reached")
+	ErrBuyerNotFound       = errors.New("buyer not found")
+	ErrInsufficientBalance = errors.New("insufficient jeton balance")
+
+	ErrTombolaCancelled = errors.New("tombola cancelled")
+	ErrTombolaDrawn     = errors.New("tombola already drawn")
+)
+
+// TicketPurchase est le résultat d'un achat de tickets
+type TicketPurchase struct {
+	Tickets    []models.Ticket
+	Total      int
+	NewBalance int64
+}
+
+// CheckTicketSales indique si la vente de tickets d'une tombola est ouverte à l'instant now
+func CheckTicketSales(tombola models.Tombola, now time.Time) error {
+	switch {
+	case tombola.Status != models.TombolaOpen:
+		return ErrTicketSalesClosed
+	case tombola.VenteDebut != nil && now.Before(*tombola.VenteDebut):
+		return ErrTicketSalesNotOpen
+	case tombola.VenteFin != nil && !now.Before(*tombola.VenteFin):
+		return ErrTicketSalesClosed
+	}
+	return nil
+}
+
+// BuyTickets achète quantite tickets d'une tombola dans une seule transaction. La fenêtre de vente, les
+// plafonds, le solde et le plafond de dépenses sont vérifiés sous verrou ; le prix total, remise comprise,
+// est réparti entre les tickets pour qu'un remboursement ticket par ticket reste exact.
+func BuyTickets(db *gorm.DB, tombolaID, userID uint, quantite int, now time.Time) (TicketPurchase, error) {
+	var purchase TicketPurchase
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Le verrou sérialise les achats d'une même tombola, pour respecter les plafonds, et les tient à l'écart du tirage
+		var tombola models.Tombola
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tombola, tombolaID).Error; err != nil {
+			return err
+		}
+		if err := CheckTicketSales(tombola, now); err != nil {
+			return err
+		}
+
+		if tombola.MaxTickets != nil {
+			var sold int64
+			if err := tx.Model(&models.Ticket{}).Where("tombola_id = ?", tombola.ID).Count(&sold).Error; err != nil {
+				return err
+			}
+			if int(sold)+quantite > *tombola.MaxTickets {
+				return ErrTicketsSoldOut
+			}
+		}
+		if tombola.MaxTicketsParUser != nil {
+			var owned int64
+			if err := tx.Model(&models.Ticket{}).Where("tombola_id = ? AND user_id = ?", tombola.ID, userID).Count(&owned).Error; err != nil {
+				return err
+			}
+			if int(owned)+quantite > *tombola.MaxTicketsParUser {
+				return ErrTicketLimitReached
+			}
+		}
+
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBuyerNotFound
+			}
+			return err
+		}
+		total := tombola.PrixTickets(quantite)
+		if user.SoldeJetons < int64(total) {
+			return ErrInsufficientBalance
+		}
+		if err := CheckSpendingLimit(tx, user.ID, int64(total)); err != nil {
+			return err
+		}
+
+		tickets := make([]models.Ticket, 0, quantite)
+		for i := 0; i < quantite; i++ {
+			numero, err := newTicketNumber()
+			if err != nil {
+				return err
+			}
+			// Les premiers tickets portent le reste de la division, la somme des prix vaut le total
+			prix := total / quantite
+			if i < total%quantite {
+				prix++
+			}
+			tickets = append(tickets, models.Ticket{
+				TombolaID:    &tombola.ID,
+				UserID:       user.ID,
+				Numero:       numero,
+				PrixEnJetons: prix,
+			})
+		}
+		if err := tx.Create(&tickets).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&user).Update("solde_jetons", gorm.Expr("solde_jetons - ?", total)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&tombola).UpdateColumn("jetons_collectes", gorm.Expr("jetons_collectes + ?", total)).Error; err != nil {
+			return err
+		}
+
+		jetonTransaction := models.JetonTransaction{
+			UserID:      user.ID,
+			Montant:     int64(total),
+			Type:        models.TransactionTypeAchat,
+			Description: fmt.Sprintf("Achat de %d ticket(s) pour la tombola %d", quantite, tombola.ID),
+			TombolaID:   &tombola.ID,
+			Date:        now,
+		}
+		if err := tx.Create(&jetonTransaction).Error; err != nil {
+			return err
+		}
+
+		purchase = TicketPurchase{Tickets: tickets, Total: total, NewBalance: user.SoldeJetons - int64(total)}
+		return nil
+	})
+	return purchase, err
+}
+
+// TombolaRefund résume les remboursements effectués à l'annulation d'une tombola
+type TombolaRefund struct {
+	Buyers  int
+	Tickets int
+	Total   int
+}
+
+// CancelTombola annule une tombola dont le tirage n'a pas eu lieu (ou a été annulé) et rembourse chaque
+// acheteur du prix payé pour ses tickets, par une transaction REMBOURSEMENT rattachée à la tombola
+func CancelTombola(db *gorm.DB, tombolaID uint, reason string, now time.Time) (TombolaRefund, error) {
+	var refund TombolaRefund
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Le verrou écarte les achats et le tirage pendant l'annulation
+		var tombola models.Tombola
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tombola, tombolaID).Error; err != nil {
+			return err
+		}
+		switch tombola.Status {
+		case models.TombolaCancelled:
+			return ErrTombolaCancelled
+		case models.TombolaDrawn:
+			// Les lots ont été attribués : le tirage doit d'abord être annulé
+			return ErrTombolaDrawn
+		}
+
+		var buyers []struct {
+			UserID  uint
+			Tickets int
+			Total   int
+		}
+		if err := tx.Model(&models.Ticket{}).
+			Select("user_id, COUNT(*) AS tickets, COALESCE(SUM(prix_en_jetons), 0) AS total").
+			Where("tombola_id = ? AND NOT est_rembourse", tombola.ID).
+			Group("user_id").Order("user_id").
+			Scan(&buyers).Error; err != nil {
+			return err
+		}
+
+		for _, buyer := range buyers {
+			refund.Buyers++
+			refund.Tickets += buyer.Tickets
+			refund.Total += buyer.Total
+			if buyer.Total == 0 {
+				continue
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", buyer.UserID).
+				UpdateColumn("solde_jetons", gorm.Expr("solde_jetons + ?", buyer.Total)).Error; err != nil {
+				return err
+			}
+			jetonTransaction := models.JetonTransaction{
+				UserID:      buyer.UserID,
+				Montant:     int64(buyer.Total),
+				Type:        models.TransactionTypeRemboursement,
+				Description: fmt.Sprintf("Remboursement de %d ticket(s) de la tombola %d annulée", buyer.Tickets, tombola.ID),
+				TombolaID:   &tombola.ID,
+				Date:        now,
+			}
+			if err := tx.Create(&jetonTransaction).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.Ticket{}).
+			Where("tombola_id = ? AND NOT est_rembourse", tombola.ID).
+			Update("est_rembourse", true).Error; err != nil {
+			return err
+		}
+		return tx.Model(&tombola).Updates(map[string]interface{}{
+			"status":           models.TombolaCancelled,
+			"jetons_collectes": gorm.Expr("jetons_collectes - ?", refund.Total),
+			"cancelled_at":     now,
+			"cancel_reason":    reason,
+		}).Error
+	})
+	return refund, err
+}
+
+// DailyTicketSales totalise les ventes de tickets d'une journée
+type DailyTicketSales struct {
+	Jour   string
+	Achats int
+	Jetons int64
+}
+
+// TicketSalesReport rassemble les chiffres de vente d'une tombola, tirés des tickets et du journal des jetons
+type TicketSalesReport struct {
+	TicketsVendus     int64
+	TicketsRembourses int64
+	Acheteurs         int64
+	JetonsEncaisses   int64
+	JetonsRembourses  int64
+	ParJour           []DailyTicketSales
+}
+
+// TombolaSalesReport calcule le rapport des ventes de tickets d'une tombola
+func TombolaSalesReport(db *gorm.DB, tombolaID uint) (TicketSalesReport, error) {
+	var report TicketSalesReport
+	if err := db.Model(&models.Ticket{}).
+		Select("COUNT(*), COUNT(*) FILTER (WHERE est_rembourse), COUNT(DISTINCT user_id)").
+		Where("tombola_id = ?", tombolaID).
+		Row().Scan(&report.TicketsVendus, &report.TicketsRembourses, &report.Acheteurs); err != nil {
+		return report, err
+	}
+
+	if err := db.Model(&models.JetonTransaction{}).
+		Select("COALESCE(SUM(CASE WHEN type = ? THEN montant ELSE 0 END), 0), "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN montant ELSE 0 END), 0)",
+			models.TransactionTypeAchat, models.TransactionTypeRemboursement).
+		Where("tombola_id = ?", tombolaID).
+		Row().Scan(&report.JetonsEncaisses, &report.JetonsRembourses); err != nil {
+		return report, err
+	}
+
+	// Les achats sont datés par leur transaction, les tickets n'ayant pas de date propre
+	report.ParJour = make([]DailyTicketSales, 0)
+	err := db.Model(&models.JetonTransaction{}).
+		Select("TO_CHAR(created_at, 'YYYY-MM-DD') AS jour, COUNT(*) AS achats, SUM(montant) AS jetons").
+		Where("tombola_id = ? AND type = ?", tombolaID, models.TransactionTypeAchat).
+		Group("jour").Order("jour").
+		Scan(&report.ParJour).Error
+	return report, err
+}
+
+// newTicketNumber génère un numéro de ticket unique de la forme "T-<horodatage>-<aléa>"
+func newTicketNumber() (string, error) {
+	randomNumber, err := common.GenerateRandomNumber(1000, 9999)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("T-%d-%d", time.Now().UnixNano(), randomNumber), nil
+}
+
+// DrawnLot associe un gain au ticket désigné par le tirage ; Ordre est la position du gain, à partir de 1
+type DrawnLot struct {
+	Lot    models.Lot
+	Ticket models.Ticket
+	Ordre  int
+}
+
+// AwardedLots renvoie les gains d'une tombola dans l'ordre d'attribution : les lots par rang puis par
+// identifiant, chacun répété selon sa quantité
+func AwardedLots(lots []models.Lot) []models.Lot {
+	sorted := make([]models.Lot, len(lots))
+	copy(sorted, lots)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Rang != sorted[j].Rang {
+			return sorted[i].Rang < sorted[j].Rang
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	awards := make([]models.Lot, 0, len(sorted))
+	for _, lot := range sorted {
+		for i := 0; i < lot.Quantite; i++ {
+			awards = append(awards, lot)
+		}
+	}
+	return awards
+}
+
+// DrawWinningTickets associe à chaque gain le ticket désigné par le tirage (voir common.DrawAlgorithm) ;
+// les tickets doivent être triés par identifiant croissant et, si la règle de gain est restrictive, porter leur groupe
+func DrawWinningTickets(tombola *models.Tombola, publicValue string) []DrawnLot {
+	tickets := make(map[uint]models.Ticket, len(tombola.Tickets))
+	for _, ticket := range tombola.Tickets {
+		tickets[ticket.ID] = ticket
+	}
+
+	awards := AwardedLots(tombola.Lots)
+	winners := DrawPicks(tombola, publicValue, len(awards))
+
+	drawn := make([]DrawnLot, 0, len(winners))
+	for k, ticketID := range winners {
+		drawn = append(drawn, DrawnLot{Lot: awards[k], Ticket: tickets[ticketID], Ordre: k + 1})
+	}
+	return drawn
+}
+
+// DrawPicks renvoie les count premiers tickets retenus par le tirage, dans l'ordre : les gains du tirage
+// puis, au-delà, les remplaçants des lots non retirés
+func DrawPicks(tombola *models.Tombola, publicValue string, count int) []uint {
+	ticketIDs := make([]uint, 0, len(tombola.Tickets))
+	var groups map[uint]uint
+	if tombola.RegleGain.Restricted() {
+		groups = make(map[uint]uint, len(tombola.Tickets))
+	}
+	for _, ticket := range tombola.Tickets {
+		ticketIDs = append(ticketIDs, ticket.ID)
+		if groups != nil {
+			groups[ticket.ID] = ticket.GroupeTirage
+		}
+	}
+
+	order := common.DrawOrder(tombola.DrawSeed, publicValue, ticketIDs)
+	return common.DrawWinners(order, groups, count)
+}
+
+// AssignDrawGroups enregistre le groupe de chaque ticket selon la règle de gain de la tombola : un groupe par
+// acheteur ou par famille. Les groupes sont numérotés dans l'ordre des tickets pour que la vérification
+// publique ne révèle pas les acheteurs.
+func AssignDrawGroups(tx *gorm.DB, tombola *models.Tombola) error {
+	if !tombola.RegleGain.Restricted() {
+		return nil
+	}
+	var families map[uint]uint
+	if tombola.RegleGain == models.PrizeRuleOnePerFamily {
+		var err error
+		if families, err = Families(tx); err != nil {
+			return err
+		}
+	}
+
+	numbers := make(map[uint]uint)
+	members := make(map[uint][]uint)
+	for i := range tombola.Tickets {
+		ticket := &tombola.Tickets[i]
+		key := ticket.UserID
+		if family, ok := families[key]; ok {
+			key = family
+		}
+		if numbers[key] == 0 {
+			numbers[key] = uint(len(numbers) + 1)
+		}
+		ticket.GroupeTirage = numbers[key]
+		members[ticket.GroupeTirage] = append(members[ticket.GroupeTirage], ticket.ID)
+	}
+
+	for group, ticketIDs := range members {
+		if err := tx.Model(&models.Ticket{}).Where("id IN ?", ticketIDs).Update("groupe_tirage", group).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadDrawInputs charge les lots, par rang, et les tickets d'une tombola dans l'ordre d'entrée du tirage
+func LoadDrawInputs(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Lots", func(db *gorm.DB) *gorm.DB { return db.Order("rang, id") }).
+		Preload("Tickets", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+}
+
+// ActiveWinners renvoie les gagnants en vigueur d'une tombola, hors tirages annulés, dans l'ordre des gains
+func ActiveWinners(db *gorm.DB, tombolaID uint) ([]models.Gagnant, error) {
+	var winners []models.Gagnant
+	err := db.Where("tombola_id = ? AND voided_draw_id IS NULL", tombolaID).
+		Preload("User").Preload("Tombola").Preload("Lot").Preload("Ticket").
+		Order("ordre, id").Find(&winners).Error
+	return winners, err
+}
+
+// DrawRevealDuration est la durée de la diffusion en direct d'un tirage de awards gains : début, annonce du lot
+// puis révélation du ticket de chaque gain, et fin, avec delay entre deux étapes
+func DrawRevealDuration(awards int, delay time.Duration) time.Duration {
+	return time.Duration(2*awards+1) * delay
+}
+
+// DrawTombola effectue le tirage d'une tombola dans une seule transaction, pour drawnByID ; les résultats restent
+// cachés pendant sa diffusion, avec revealDelay entre deux étapes. Un tirage déjà effectué n'est pas refait :
+// ses gagnants sont renvoyés, quelle que soit la valeur publique reçue, et drawn vaut false.
+func DrawTombola(db *gorm.DB, tombolaID, drawnByID uint, publicValue string, revealDelay time.Duration) (winners []models.Gagnant, drawn bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Le verrou sérialise les tirages concurrents et attend la fin des achats de tickets en cours
+		var tombola models.Tombola
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tombola, tombolaID).Error; err != nil {
+			return err
+		}
+		if tombola.Status == models.TombolaCancelled {
+			return ErrTombolaCancelled
+		}
+		if tombola.Status == models.TombolaDrawn {
+			var err error
+			winners, err = ActiveWinners(tx, tombola.ID)
+			return err
+		}
+		// Sans empreinte publiée avant la vente, le tirage ne pourrait pas être vérifié
+		if tombola.DrawCommitment == "" || tombola.DrawSeed == "" {
+			return ErrDrawNotCommitted
+		}
+		// Après une annulation, la graine a été publiée une fois la vente close : seule une valeur publique
+		// annoncée après cette publication empêche de choisir la graine en connaissant l'issue du tirage
+		if tombola.Status == models.TombolaVoided {
+			var reused int64
+			if err := tx.Model(&models.VoidedDraw{}).
+				Where("tombola_id = ? AND public_value = ?", tombola.ID, publicValue).
+				Count(&reused).Error; err != nil {
+				return err
+			}
+			if reused > 0 {
+				return ErrPublicValueReused
+			}
+		}
+
+		if err := LoadDrawInputs(tx).First(&tombola, tombola.ID).Error; err != nil {
+			return err
+		}
+		if len(tombola.Tickets) == 0 {
+			return ErrNoTicketsSold
+		}
+		if len(tombola.Lots) == 0 {
+			return ErrNoLotsAvailable
+		}
+		if err := AssignDrawGroups(tx, &tombola); err != nil {
+			return err
+		}
+
+		// Les gagnants ne sont prévenus qu'à la fin de la diffusion du tirage (voir NotifyWinners)
+		now := time.Now()
+		awards := DrawWinningTickets(&tombola, publicValue)
+		for _, award := range awards {
+			winner := models.Gagnant{
+				UserID:    award.Ticket.UserID,
+				TicketID:  award.Ticket.ID,
+				LotID:     award.Lot.ID,
+				TombolaID: tombola.ID,
+				Ordre:     award.Ordre,
+				Statut:    models.PrizePending,
+			}
+			if err := tx.Create(&winner).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Ticket{}).Where("id = ?", award.Ticket.ID).Update("est_gagnant", true).Error; err != nil {
+				return err
+			}
+		}
+
+		// La valeur publique est enregistrée : la graine devient consultable et la vente est close
+		if err := tx.Model(&tombola).Updates(map[string]interface{}{
+			"status":            models.TombolaDrawn,
+			"draw_public_value": publicValue,
+			"drawn_at":          now,
+			"reveal_ends_at":    now.Add(DrawRevealDuration(len(awards), revealDelay)),
+			"drawn_by_id":       drawnByID,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		winners, err = ActiveWinners(tx, tombola.ID)
+		drawn = true
+		return err
+	})
+	return winners, drawn, err
+}
+
+// VoidDraw annule le tirage d'une tombola : les gains sont rattachés au tirage annulé, les tickets ne sont
+// plus gagnants et une nouvelle graine est publiée pour le tirage suivant
+func VoidDraw(db *gorm.DB, tombolaID, adminID uint, reason string) (models.VoidedDraw, error) {
+	var voided models.VoidedDraw
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var tombola models.Tombola
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tombola, tombolaID).Error; err != nil {
+			return err
+		}
+		if tombola.Status != models.TombolaDrawn {
+			return ErrTombolaNotDrawn
+		}
+
+		voided = models.VoidedDraw{
+			TombolaID:   tombola.ID,
+			Commitment:  tombola.DrawCommitment,
+			Seed:        tombola.DrawSeed,
+			PublicValue: tombola.DrawPublicValue,
+			DrawnAt:     tombola.DrawnAt,
+			Reason:      reason,
+			VoidedByID:  adminID,
+			VoidedAt:    time.Now(),
+		}
+		if err := tx.Create(&voided).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Gagnant{}).
+			Where("tombola_id = ? AND voided_draw_id IS NULL", tombola.ID).
+			Update("voided_draw_id", voided.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Ticket{}).
+			Where("tombola_id = ? AND est_gagnant", tombola.ID).
+			Update("est_gagnant", false).Error; err != nil {
+			return err
+		}
+
+		// La graine révélée ne peut pas resservir : le nouveau tirage repart d'une graine publiée maintenant,
+		// vente close ; DrawTombola exige alors une valeur publique différente de celles des tirages annulés
+		seed, commitment, err := common.NewDrawSeed()
+		if err != nil {
+			return err
+		}
+		return tx.Model(&tombola).Updates(map[string]interface{}{
+			"status":            models.TombolaVoided,
+			"draw_commitment":   commitment,
+			"draw_seed":         seed,
+			"draw_public_value": "",
+			"drawn_at":          nil,
+		}).Error
+	})
+	return voided, err
+}
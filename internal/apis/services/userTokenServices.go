@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"example/hello/common"
+	"example/hello/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// IssueUserToken crée un jeton signé à usage unique ; les jetons précédents du même usage sont invalidés
+func IssueUserToken(tx *gorm.DB, userID uint, purpose models.UserTokenPurpose, ttl time.Duration) (string, error) {
+	now := time.Now()
+	raw, err := common.NewSignedToken(string(purpose), userID, now.Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+
+	record := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: common.HashToken(raw),
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// ConsumeUserToken vérifie un jeton et le marque comme utilisé ; il renvoie l'utilisateur concerné
+func ConsumeUserToken(tx *gorm.DB, purpose models.UserTokenPurpose, raw string) (uint, error) {
+	userID, err := common.ParseSignedToken(string(purpose), raw)
+	if err != nil {
+		return 0, ErrInvalidUserToken
+	}
+
+	now := time.Now()
+	result := tx.Model(&models.UserToken{}).
+		Where("token_hash = ? AND purpose = ? AND user_id = ? AND used_at IS NULL AND expires_at > ?", common.HashToken(raw), purpose, userID, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrInvalidUserToken
+	}
+
+	return userID, nil
+}
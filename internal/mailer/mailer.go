@@ -0,0 +1,116 @@
+// Package mailer envoie les e-mails transactionnels de l'application.
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message est un e-mail texte à envoyer
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer est implémenté par chaque moyen d'envoi
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer envoie les messages via un serveur SMTP
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	headers := []string{
+		"From: " + m.From,
+		"To: " + msg.To,
+		"Subject: " + msg.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	body := strings.Join(headers, "\r\n") + "\r\n\r\n" + msg.Body
+
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{msg.To}, []byte(body))
+}
+
+// LogMailer écrit les messages dans un fichier, ou dans les logs si aucun fichier n'est configuré.
+// Il est destiné au développement local.
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	entry := fmt.Sprintf("---- %s\nTo: %s\nSubject: %s\n\n%s\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+
+	if m.Path == "" {
+		log.Print(entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(entry)
+	return err
+}
+
+var (
+	defaultMailer Mailer
+	defaultOnce   sync.Once
+)
+
+// Default renvoie le mailer configuré par MAILER (smtp ou log, log par défaut)
+func Default() Mailer {
+	defaultOnce.Do(func() {
+		if strings.EqualFold(os.Getenv("MAILER"), "smtp") {
+			port := os.Getenv("SMTP_PORT")
+			if port == "" {
+				port = "587"
+			}
+			defaultMailer = SMTPMailer{
+				Host:     os.Getenv("SMTP_HOST"),
+				Port:     port,
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+				From:     os.Getenv("SMTP_FROM"),
+			}
+			return
+		}
+		defaultMailer = &LogMailer{Path: os.Getenv("MAILER_LOG_FILE")}
+	})
+	return defaultMailer
+}
+
+// SendAsync envoie un message sans bloquer la requête ; les erreurs sont journalisées
+func SendAsync(msg Message) {
+	go func() {
+		if err := Default().Send(msg); err != nil {
+			log.Printf("Erreur lors de l'envoi de l'e-mail à %s: %v", msg.To, err)
+		}
+	}()
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"example/hello/i18n"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// appLink construit un lien vers l'application à partir de APP_URL
+func appLink(path, token string) string {
+	base := strings.TrimRight(os.Getenv("APP_URL"), "/")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return fmt.Sprintf("%s%s?token=%s", base, path, url.QueryEscape(token))
+}
+
+// VerificationEmail invite l'utilisateur à confirmer son adresse e-mail
+func VerificationEmail(lang, to, name, token string) Message {
+	return Message{
+		To:      to,
+		Subject: i18n.Translate(lang, "email.verification.subject"),
+		Body:    i18n.Translate(lang, "email.verification.body", name, appLink("/verify-email", token)),
+	}
+}
+
+// PasswordResetEmail contient le lien de réinitialisation du mot de passe
+func PasswordResetEmail(lang, to, name, token string) Message {
+	return Message{
+		To:      to,
+		Subject: i18n.Translate(lang, "email.password_reset.subject"),
+		Body:    i18n.Translate(lang, "email.password_reset.body", name, appLink("/reset-password", token)),
+	}
+}
+
+// InvitationEmail transmet le lien d'invitation à un futur teneur de stand, organisateur ou administrateur
+func InvitationEmail(lang, to, inviterName, role, kermesseNom, token string) Message {
+	context := ""
+	if kermesseNom != "" {
+		context = i18n.Translate(lang, "email.invitation.kermesse", kermesseNom)
+	}
+	return Message{
+		To:      to,
+		Subject: i18n.Translate(lang, "email.invitation.subject"),
+		Body:    i18n.Translate(lang, "email.invitation.body", inviterName, role, context, appLink("/invitations/accept", token)),
+	}
+}
+
+// ActivationEmail invite une famille importée depuis la liste de l'école à choisir son mot de passe
+func ActivationEmail(lang, to, name, token string) Message {
+	return Message{
+		To:      to,
+		Subject: i18n.Translate(lang, "email.activation.subject"),
+		Body:    i18n.Translate(lang, "email.activation.body", name, appLink("/reset-password", token)),
+	}
+}
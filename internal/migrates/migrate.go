@@ -33,6 +33,10 @@ func main() {
 	// Supprimer explicitement les tables de jointure
 	initializers.DB.Migrator().DropTable("kermesse_lots", "kermesse_organisateurs", "kermesse_participants", "kermesse_stands", "stand_responsables", "stand_stock", "stand_responsables", "tombola_gagnant", "organisateur_kermesses", "tombola_lots", "tombola_tickets", "parents_eleves")*/
 
+	// La reprise des adresses vérifiées n'a lieu qu'à l'ajout de la colonne, pour ne pas valider les inscriptions en attente
+	backfillEmailVerified := initializers.DB.Migrator().HasTable(&models.User{}) && !initializers.DB.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+	backfillNotifiedAt := initializers.DB.Migrator().HasTable(&models.Gagnant{}) && !initializers.DB.Migrator().HasColumn(&models.Gagnant{}, "NotifiedAt")
+
 	err := initializers.DB.AutoMigrate(
 		&models.User{},
 		&models.Parent{},
@@ -46,11 +50,63 @@ func main() {
 		&models.Stock{},
 		&models.Ticket{},
 		&models.Gagnant{},
+		&models.VoidedDraw{},
 		&models.JetonTransaction{},
-		&models.Message{},)
+		&models.Message{},
+		&models.OfflineAllowance{},
+		&models.OfflineSale{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.UserToken{},
+		&models.AuthEvent{},
+		&models.RecoveryCode{},
+		&models.UserRole{},
+		&models.Invitation{},
+		&models.Guardianship{},
+		&models.ErasureRequest{},
+		&models.AuditLog{},)
 
 	if err != nil {
 		return
 	}
 
+	// Les comptes créés avant la vérification des adresses e-mail restent autorisés à acheter des jetons
+	if backfillEmailVerified {
+		initializers.DB.Exec("UPDATE users SET email_verified_at = NOW() WHERE email_verified_at IS NULL")
+	}
+
+	// Reprise des rôles actifs des comptes créés avant les rôles multiples
+	initializers.DB.Exec("INSERT INTO user_roles (user_id, role, created_at) SELECT id, roles, NOW() FROM users ON CONFLICT DO NOTHING")
+
+	// Le parent rattaché à chaque élève devient son premier tuteur avec tous les droits
+	initializers.DB.Exec("INSERT INTO guardianships (eleve_id, parent_id, permission, created_at, updated_at) SELECT id, parent_id, 'FULL', NOW(), NOW() FROM eleves WHERE parent_id IS NOT NULL ON CONFLICT DO NOTHING")
+
+	// Les tombolas tirées avant l'introduction du statut ne doivent pas être tirées une seconde fois
+	initializers.DB.Exec("UPDATE tombolas SET status = 'DRAWN' WHERE status = 'OPEN' AND id IN (SELECT tombola_id FROM gagnants WHERE voided_draw_id IS NULL)")
+
+	// Les achats de tickets enregistrés sans référence sont rattachés à leur tombola, et la recette des tombolas recalculée
+	initializers.DB.Exec(`UPDATE jeton_transactions SET tombola_id = CAST(substring(description from 'pour la tombola ([0-9]+)$') AS bigint)
+WHERE tombola_id IS NULL AND stand_id IS NULL AND type = 'ACHAT' AND description ~ '^Achat .*ticket.* pour la tombola [0-9]+$'`)
+	initializers.DB.Exec("UPDATE tombolas SET jetons_collectes = (SELECT COALESCE(SUM(prix_en_jetons), 0) FROM tickets WHERE tickets.tombola_id = tombolas.id AND NOT tickets.est_rembourse)")
+
+	// Les gains tirés avant les rangs ont été attribués par identifiant de lot croissant
+	initializers.DB.Exec(`UPDATE gagnants SET ordre = ranked.ordre
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY tombola_id, voided_draw_id ORDER BY lot_id, id) AS ordre FROM gagnants) AS ranked
+WHERE gagnants.id = ranked.id AND gagnants.ordre = 0`)
+
+	// Les gagnants désignés avant le suivi des retraits ont été prévenus au tirage ; ensuite, notified_at
+	// reste vide tant que la diffusion du tirage n'a pas prévenu le gagnant
+	if backfillNotifiedAt {
+		initializers.DB.Exec("UPDATE gagnants SET notified_at = tombolas.drawn_at FROM tombolas WHERE gagnants.tombola_id = tombolas.id AND gagnants.notified_at IS NULL")
+	}
+
+	// Le journal d'audit est en ajout seul : la base refuse toute modification ou suppression d'entrée
+	initializers.DB.Exec(`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql`)
+	initializers.DB.Exec("DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs")
+	initializers.DB.Exec("CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only()")
+
 }
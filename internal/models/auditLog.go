@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// AuditLog est une entrée du journal d'audit des opérations sensibles.
+// Le journal n'est jamais modifié : chaque entrée porte le hash de la précédente,
+// de sorte qu'une altération casse la chaîne.
+type AuditLog struct {
+	ID         uint   `gorm:"primary_key" json:"id"`
+	ActorID    *uint  `gorm:"index" json:"actor_id"`
+	ActorRole  string `json:"actor_role"`
+	Action     string `gorm:"index" json:"action"`
+	TargetType string `gorm:"index:idx_audit_target" json:"target_type"`
+	TargetID   string `gorm:"index:idx_audit_target" json:"target_id"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	StatusCode int    `json:"status_code"`
+	// Request est le corps JSON de la requête, Changes le diff champ par champ {"champ": {"before": ..., "after": ...}}
+	Request   string    `gorm:"type:text" json:"request"`
+	Changes   string    `gorm:"type:text" json:"changes"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `gorm:"uniqueIndex" json:"hash"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type AuthEventType string
+
+const (
+	AuthEventLoginFailed     AuthEventType = "LOGIN_FAILED"
+	AuthEventLoginThrottled  AuthEventType = "LOGIN_THROTTLED"
+	AuthEventLoginLocked     AuthEventType = "LOGIN_WHILE_LOCKED"
+	AuthEventAccountLocked   AuthEventType = "ACCOUNT_LOCKED"
+	AuthEventAccountUnlocked AuthEventType = "ACCOUNT_UNLOCKED"
+	AuthEventMFAFailed       AuthEventType = "MFA_FAILED"
+	AuthEventMFAReset        AuthEventType = "MFA_RESET"
+)
+
+// AuthEvent journalise les activités de connexion suspectes
+type AuthEvent struct {
+	ID        uint          `gorm:"primary_key" json:"id"`
+	UserID    *uint         `gorm:"index" json:"user_id"`
+	Email     string        `gorm:"index" json:"email"`
+	IPAddress string        `gorm:"index" json:"ip_address"`
+	UserAgent string        `json:"user_agent"`
+	Event     AuthEventType `json:"event"`
+	Details   string        `json:"details"`
+	CreatedAt time.Time     `json:"created_at"`
+}
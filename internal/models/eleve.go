@@ -9,4 +9,6 @@ type Eleve struct {
 	ParentID        *uint
 	Parent          Parent `gorm:"foreignKey:ParentID"`
 	PointsAccumules int
+	// PlafondJournalier limite les dépenses en jetons de l'élève par jour ; nil signifie sans limite
+	PlafondJournalier *int64
 }
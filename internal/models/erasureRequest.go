@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type ErasureStatus string
+
+const (
+	ErasurePending   ErasureStatus = "PENDING"
+	ErasureCompleted ErasureStatus = "COMPLETED"
+	ErasureRejected  ErasureStatus = "REJECTED"
+	ErasureCancelled ErasureStatus = "CANCELLED"
+)
+
+// ErasureRequest trace une demande d'effacement des données d'un compte.
+// Elle est conservée après traitement comme preuve de la suite donnée.
+type ErasureRequest struct {
+	ID            uint          `gorm:"primary_key" json:"id"`
+	UserID        uint          `gorm:"index" json:"user_id"`
+	RequestedByID uint          `json:"requested_by_id"`
+	Reason        string        `json:"reason"`
+	Status        ErasureStatus `gorm:"index" json:"status"`
+	ProcessedByID *uint         `json:"processed_by_id"`
+	ProcessedAt   *time.Time    `json:"processed_at"`
+	Motif         string        `json:"motif"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
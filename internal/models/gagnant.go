@@ -1,5 +1,22 @@
 package models
 
+import "time"
+
+type PrizeClaimStatus string
+
+const (
+    // PrizePending : le gagnant est désigné mais pas encore prévenu, le tirage est en cours de diffusion
+    PrizePending PrizeClaimStatus = "PENDING"
+    // PrizeNotified : le gagnant a été prévenu par l'application, le lot l'attend
+    PrizeNotified PrizeClaimStatus = "NOTIFIED"
+    // PrizeClaimed : le gagnant a réclamé son lot et obtenu le QR code de remise
+    PrizeClaimed PrizeClaimStatus = "CLAIMED"
+    // PrizeHandedOver : le lot a été remis, QR code du ticket gagnant scanné
+    PrizeHandedOver PrizeClaimStatus = "HANDED_OVER"
+    // PrizeUnclaimed : lot non retiré à la date limite, remis en jeu
+    PrizeUnclaimed PrizeClaimStatus = "UNCLAIMED"
+)
+
 type Gagnant struct {
     ID        uint `gorm:"primary_key" json:"id"`
     UserID    uint `json:"userId"`
@@ -10,4 +27,16 @@ type Gagnant struct {
     Lot       Lot `json:"lot"`
     TicketID  uint `json:"ticketId"`
     Ticket    Ticket `json:"ticket"`
+    // Ordre est la position du gain dans le tirage, à partir de 1 : premier prix d'abord
+    Ordre     int `gorm:"default:0" json:"ordre"`
+    // VoidedDrawID désigne le tirage annulé auquel appartenait ce gain ; nul pour les gains en vigueur
+    VoidedDrawID *uint `gorm:"index" json:"voided_draw_id,omitempty"`
+    // Retrait du lot
+    Statut         PrizeClaimStatus `gorm:"size:16;default:NOTIFIED;index" json:"statut"`
+    NotifiedAt     *time.Time `json:"notified_at"`
+    ClaimedAt      *time.Time `json:"claimed_at"`
+    HandedOverAt   *time.Time `json:"handed_over_at"`
+    HandedOverByID *uint `json:"handed_over_by_id"`
+    // RemplaceID désigne le gain non retiré dont ce gain a repris le lot lors d'une remise en jeu
+    RemplaceID *uint `json:"remplace_id,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type GuardianPermission string
+
+const (
+	// GuardianFull : financer, consulter l'historique, fixer les plafonds et gérer les autres tuteurs
+	GuardianFull GuardianPermission = "FULL"
+	// GuardianFundOnly : transférer des jetons sans accès à l'historique
+	GuardianFundOnly GuardianPermission = "FUND_ONLY"
+	// GuardianViewOnly : suivre l'historique et le plafond sans pouvoir financer
+	GuardianViewOnly GuardianPermission = "VIEW_ONLY"
+)
+
+func (p GuardianPermission) Valid() bool {
+	return p == GuardianFull || p == GuardianFundOnly || p == GuardianViewOnly
+}
+
+func (p GuardianPermission) CanFund() bool {
+	return p == GuardianFull || p == GuardianFundOnly
+}
+
+func (p GuardianPermission) CanView() bool {
+	return p == GuardianFull || p == GuardianViewOnly
+}
+
+func (p GuardianPermission) CanManage() bool {
+	return p == GuardianFull
+}
+
+// Guardianship relie un élève à chacun de ses tuteurs (parents séparés, grands-parents...).
+// Eleve.ParentID reste le parent qui a créé ou importé le compte.
+type Guardianship struct {
+	ID         uint               `gorm:"primary_key" json:"id"`
+	EleveID    uint               `gorm:"uniqueIndex:idx_guardianship;not null" json:"eleve_id"`
+	Eleve      Eleve              `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	ParentID   uint               `gorm:"uniqueIndex:idx_guardianship;not null" json:"parent_id"`
+	Parent     Parent             `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Permission GuardianPermission `json:"permission"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+}
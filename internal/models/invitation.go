@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Invitation permet d'attribuer un rôle privilégié à une adresse e-mail.
+// Seul le hash du lien signé est conservé.
+type Invitation struct {
+	ID           uint       `gorm:"primary_key" json:"id"`
+	Email        string     `gorm:"index;not null" json:"email"`
+	Role         Role       `json:"role"`
+	KermesseID   *uint      `gorm:"index" json:"kermesse_id"`
+	Kermesse     *Kermesse  `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	StandID      *uint      `json:"stand_id"`
+	Stand        *Stand     `gorm:"constraint:OnDelete:SET NULL;" json:"-"`
+	InvitedByID  uint       `gorm:"index" json:"invited_by_id"`
+	TokenHash    string     `gorm:"uniqueIndex" json:"-"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	AcceptedAt   *time.Time `json:"accepted_at"`
+	AcceptedByID *uint      `json:"accepted_by_id"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Status renvoie l'état courant de l'invitation
+func (i Invitation) Status(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return "ACCEPTED"
+	case i.RevokedAt != nil:
+		return "REVOKED"
+	case now.After(i.ExpiresAt):
+		return "EXPIRED"
+	default:
+		return "PENDING"
+	}
+}
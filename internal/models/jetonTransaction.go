@@ -7,9 +7,10 @@ import (
 type TransactionType string
 
 const (
-	TransactionTypeAchat       TransactionType = "ACHAT"
-	TransactionTypeUtilisation TransactionType = "UTILISATION"
-	TransactionTypeTransfert   TransactionType = "TRANSFERT"
+	TransactionTypeAchat         TransactionType = "ACHAT"
+	TransactionTypeUtilisation   TransactionType = "UTILISATION"
+	TransactionTypeTransfert     TransactionType = "TRANSFERT"
+	TransactionTypeRemboursement TransactionType = "REMBOURSEMENT"
 )
 
 type JetonTransaction struct {
@@ -21,6 +22,9 @@ type JetonTransaction struct {
 	Description string
 	StandID     *uint
 	Stand       *Stand
+	TombolaID   *uint `gorm:"index"`
+	Tombola     *Tombola
 	Date        time.Time
 	PaiementID  string
+	CreatedAt   time.Time
 }
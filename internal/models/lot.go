@@ -7,4 +7,8 @@ type Lot struct {
 	Nom         string
 	Description string
 	Valeur      float64
+	// Quantite est le nombre d'exemplaires identiques du lot, chacun attribué à un ticket différent
+	Quantite int `gorm:"default:1" json:"quantite"`
+	// Rang est le palier du lot : les lots de rang 1 (premier prix) sont attribués en premier
+	Rang int `gorm:"default:1" json:"rang"`
 }
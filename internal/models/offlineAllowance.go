@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// OfflineAllowance est une autorisation de dépense signée, confiée à un terminal de stand
+// pour encaisser un client sans connexion.
+type OfflineAllowance struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	UserID    uint       `json:"user_id"`
+	User      User       `json:"-"`
+	StandID   uint       `json:"stand_id"`
+	Stand     Stand      `json:"-"`
+	Plafond   int64      `json:"plafond"`
+	Depense   int64      `json:"depense"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	// ConsentHash est l'empreinte du QR code par lequel le client a accepté l'autorisation, utilisable une seule fois
+	ConsentHash *string `gorm:"size:64;uniqueIndex" json:"-"`
+}
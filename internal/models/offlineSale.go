@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type OfflineSaleStatus string
+
+const (
+	OfflineSaleApplied  OfflineSaleStatus = "APPLIED"
+	OfflineSaleRejected OfflineSaleStatus = "REJECTED"
+)
+
+// OfflineSale garde la trace de chaque vente synchronisée, appliquée ou rejetée,
+// afin qu'un terminal puisse renvoyer un lot sans double débit.
+type OfflineSale struct {
+	ID            uint              `gorm:"primary_key" json:"id"`
+	StandID       uint              `gorm:"uniqueIndex:idx_offline_sales_stand_ref" json:"stand_id"`
+	ClientRef     string            `gorm:"uniqueIndex:idx_offline_sales_stand_ref" json:"client_ref"`
+	AllowanceID   uint              `json:"allowance_id"`
+	UserID        uint              `json:"user_id"`
+	StockID       uint              `json:"stock_id"`
+	Quantite      int               `json:"quantite"`
+	Montant       int64             `json:"montant"`
+	RecordedAt    time.Time         `json:"recorded_at"`
+	SyncedAt      time.Time         `json:"synced_at"`
+	Statut        OfflineSaleStatus `json:"statut"`
+	Motif         string            `json:"motif"`
+	TransactionID *uint             `json:"transaction_id"`
+}
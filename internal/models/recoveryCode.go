@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RecoveryCode est un code de secours à usage unique pour la double authentification
+type RecoveryCode struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
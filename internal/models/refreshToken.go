@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RefreshToken est conservé côté serveur pour pouvoir être tourné et révoqué.
+// Seul le hash du jeton est stocké.
+type RefreshToken struct {
+	ID           uint       `gorm:"primary_key" json:"id"`
+	UserID       uint       `gorm:"index" json:"user_id"`
+	User         User       `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	TokenHash    string     `gorm:"uniqueIndex" json:"-"`
+	FamilyID     string     `gorm:"index" json:"-"`
+	Scope        string     `json:"scope"`
+	DeviceName   string     `json:"device_name"`
+	UserAgent    string     `json:"user_agent"`
+	IPAddress    string     `json:"ip_address"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
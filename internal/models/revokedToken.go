@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// RevokedToken liste les identifiants (jti) des jetons d'accès révoqués avant leur expiration
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey" json:"jti"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+}
@@ -8,5 +8,8 @@ type Ticket struct {
 	User         User
 	Numero       string
 	EstGagnant   bool
+	EstRembourse bool `gorm:"default:false"`
+	// GroupeTirage numérote, au tirage, les tickets d'un même acheteur ou d'une même famille selon la règle de gain
+	GroupeTirage uint `gorm:"default:0"`
 	PrixEnJetons int
 }
@@ -1,9 +1,82 @@
 package models
 
+import "time"
+
+type TombolaStatus string
+
+const (
+	// TombolaOpen : vente ouverte, tirage à venir
+	TombolaOpen TombolaStatus = "OPEN"
+	// TombolaDrawn : tirage effectué, vente close
+	TombolaDrawn TombolaStatus = "DRAWN"
+	// TombolaVoided : tirage annulé par un administrateur, en attente d'un nouveau tirage ; la vente reste close
+	TombolaVoided TombolaStatus = "VOIDED"
+	// TombolaCancelled : tombola annulée, les tickets ont été remboursés
+	TombolaCancelled TombolaStatus = "CANCELLED"
+)
+
+// PrizeRule limite le nombre de lots qu'un même gagnant peut remporter lors d'un tirage
+type PrizeRule string
+
+const (
+	// PrizeRuleNone : chaque ticket gagne au plus un lot, sans autre limite
+	PrizeRuleNone PrizeRule = "NONE"
+	// PrizeRuleOnePerUser : un lot au plus par acheteur
+	PrizeRuleOnePerUser PrizeRule = "ONE_PER_USER"
+	// PrizeRuleOnePerFamily : un lot au plus par famille, élèves et tuteurs reliés par leurs tutelles
+	PrizeRuleOnePerFamily PrizeRule = "ONE_PER_FAMILY"
+)
+
+// Restricted indique si la règle limite les gains d'un même groupe de tickets
+func (r PrizeRule) Restricted() bool {
+	return r == PrizeRuleOnePerUser || r == PrizeRuleOnePerFamily
+}
+
 type Tombola struct {
 	ID         uint `gorm:"primary_key" json:"id"`
 	Nom        string `json:"nom"`
 	KermesseID uint `json:"kermesse_id"`
 	Lots       []Lot `json:"lots"`
 	Tickets    []Ticket `json:"tickets"`
+	Status     TombolaStatus `gorm:"size:16;default:OPEN;index" json:"status"`
+	// Vente : prix du ticket en jetons, fenêtre de vente et plafonds ; une date ou un plafond absents ne limitent pas
+	PrixTicket        int        `gorm:"default:2" json:"prix_ticket"`
+	VenteDebut        *time.Time `json:"vente_debut"`
+	VenteFin          *time.Time `json:"vente_fin"`
+	MaxTicketsParUser *int       `json:"max_tickets_par_user"`
+	MaxTickets        *int       `json:"max_tickets"`
+	// Remise par quantité : RemisePourcent % de réduction à partir de RemiseQuantite tickets achetés ensemble
+	RemiseQuantite int `json:"remise_quantite"`
+	RemisePourcent int `json:"remise_pourcent"`
+	// RegleGain s'applique au tirage, voir PrizeRule
+	RegleGain PrizeRule `gorm:"size:16;default:NONE" json:"regle_gain"`
+	// DateLimiteRetrait : passé cette date, les lots non remis peuvent être remis en jeu
+	DateLimiteRetrait *time.Time `json:"date_limite_retrait"`
+	// JetonsCollectes est la recette nette des tickets, remboursements déduits
+	JetonsCollectes int        `json:"jetons_collectes"`
+	CancelledAt     *time.Time `json:"cancelled_at,omitempty"`
+	CancelReason    string     `json:"cancel_reason,omitempty" gorm:"size:500"`
+	// Tirage vérifiable : l'empreinte de la graine est publiée avant la vente, la graine après le tirage
+	DrawCommitment  string     `json:"draw_commitment" gorm:"size:64"`
+	DrawSeed        string     `json:"-" gorm:"size:64"`
+	DrawPublicValue string     `json:"draw_public_value,omitempty" gorm:"size:128"`
+	DrawnAt         *time.Time `json:"drawn_at,omitempty"`
+	// RevealEndsAt est la fin de la diffusion en direct du tirage : gagnants et graine restent cachés jusque-là
+	RevealEndsAt *time.Time `json:"reveal_ends_at,omitempty"`
+	// DrawnByID a effectué le tirage ; les gagnants sont prévenus de sa part
+	DrawnByID *uint `json:"-"`
+}
+
+// Revealing indique si le tirage est encore en cours de diffusion à l'instant now
+func (t Tombola) Revealing(now time.Time) bool {
+	return t.RevealEndsAt != nil && now.Before(*t.RevealEndsAt)
+}
+
+// PrixTickets renvoie le prix total de quantite tickets achetés ensemble ; la remise est arrondie à l'unité inférieure
+func (t Tombola) PrixTickets(quantite int) int {
+	total := t.PrixTicket * quantite
+	if t.RemiseQuantite > 0 && quantite >= t.RemiseQuantite {
+		total -= total * t.RemisePourcent / 100
+	}
+	return total
 }
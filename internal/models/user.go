@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Role int
 
 const (
@@ -15,10 +17,24 @@ func (r Role) String() string {
 }
 
 type User struct {
-	ID          uint   `gorm:"primary_key" json:"id"`
-	Name        string `json:"name"`
-	Email       string `json:"email" gorm:"uniqueIndex"`
-	Password    string `json:"password"`
-	Roles       Role   `json:"role"`
-	SoldeJetons int64  `json:"solde_jetons"`
+	ID    uint   `gorm:"primary_key" json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email" gorm:"uniqueIndex"`
+	// Identifiant et PIN des comptes enfants sans adresse e-mail
+	Username *string `json:"username,omitempty" gorm:"uniqueIndex"`
+	PinHash  string  `json:"-"`
+	Password string  `json:"password"`
+	// Rôle actif ; l'ensemble des rôles détenus est dans UserRole
+	Roles           Role       `json:"role"`
+	SoldeJetons     int64      `json:"solde_jetons"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	FailedLogins    int        `json:"-"`
+	LockedUntil     *time.Time `json:"-"`
+	TOTPSecret      string     `json:"-"`
+	TOTPEnabledAt   *time.Time `json:"-"`
+	TOTPLastStep    int64      `json:"-"`
+	// Language est la langue choisie par l'utilisateur (fr, en) ; vide, la langue suit Accept-Language
+	Language string `json:"language" gorm:"size:2"`
+	// AnonymisedAt est renseigné lorsque les données personnelles ont été effacées
+	AnonymisedAt *time.Time `json:"anonymised_at,omitempty"`
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// UserRole associe un rôle à un utilisateur ; User.Roles reste le rôle actif
+type UserRole struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_user_role;not null" json:"user_id"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Role      Role      `gorm:"uniqueIndex:idx_user_role" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
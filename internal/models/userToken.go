@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type UserTokenPurpose string
+
+const (
+	UserTokenEmailVerification UserTokenPurpose = "EMAIL_VERIFICATION"
+	UserTokenPasswordReset     UserTokenPurpose = "PASSWORD_RESET"
+	UserTokenMFALogin          UserTokenPurpose = "MFA_LOGIN"
+	UserTokenChildLogin        UserTokenPurpose = "CHILD_LOGIN"
+)
+
+// UserToken enregistre les jetons à usage unique envoyés par e-mail
+// ou remis entre les deux étapes d'une connexion.
+// Seul le hash du jeton est conservé.
+type UserToken struct {
+	ID        uint             `gorm:"primary_key" json:"id"`
+	UserID    uint             `gorm:"index" json:"user_id"`
+	User      User             `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Purpose   UserTokenPurpose `gorm:"index" json:"purpose"`
+	TokenHash string           `gorm:"uniqueIndex" json:"-"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	UsedAt    *time.Time       `json:"used_at"`
+	CreatedAt time.Time        `json:"created_at"`
+}
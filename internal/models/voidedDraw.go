@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// VoidedDraw conserve un tirage annulé par un administrateur. Sa graine et sa valeur publique restent
+// publiées avec le motif de l'annulation, pour que le tirage annulé puisse encore être vérifié.
+type VoidedDraw struct {
+	ID          uint       `gorm:"primary_key" json:"id"`
+	TombolaID   uint       `gorm:"index" json:"tombola_id"`
+	Commitment  string     `gorm:"size:64" json:"commitment"`
+	Seed        string     `gorm:"size:64" json:"seed"`
+	PublicValue string     `gorm:"size:128" json:"public_value"`
+	DrawnAt     *time.Time `json:"drawn_at"`
+	Reason      string     `json:"reason"`
+	VoidedByID  uint       `json:"voided_by_id"`
+	VoidedAt    time.Time  `json:"voided_at"`
+}
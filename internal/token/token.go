@@ -0,0 +1,220 @@
+// Package token émet et valide les jetons d'accès JWT de l'API.
+package token
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultKeyID    = "default"
+	defaultIssuer   = "kermesse-api"
+	defaultAudience = "kermesse-app"
+)
+
+var (
+	ErrMalformed     = errors.New("malformed token")
+	ErrExpired       = errors.New("token expired")
+	ErrUnknownKey    = errors.New("unknown signing key")
+	ErrInvalidClaims = errors.New("invalid token claims")
+	ErrNoSigningKey  = errors.New("no signing key configured")
+	ErrBadSignature  = errors.New("invalid token signature")
+)
+
+// ScopeSpend restreint un jeton aux routes d'achat et de consultation (comptes enfants)
+const ScopeSpend = "spend"
+
+// Claims sont les informations portées par un jeton d'accès.
+// Role est le rôle actif, Roles l'ensemble des rôles détenus.
+// Un jeton sans Scope donne accès à toutes les routes autorisées par les rôles.
+// KermesseID est la kermesse à laquelle le titulaire est rattaché comme teneur de stand ou organisateur,
+// absente s'il n'en a aucune ou plusieurs.
+type Claims struct {
+	UserID     uint     `json:"id"`
+	Role       string   `json:"role"`
+	Roles      []string `json:"roles,omitempty"`
+	Scope      string   `json:"scope,omitempty"`
+	KermesseID *uint    `json:"kermesse_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// AllRoles renvoie les rôles détenus ; les jetons émis avant les rôles multiples n'en portent qu'un
+func (c *Claims) AllRoles() []string {
+	if len(c.Roles) == 0 {
+		return []string{c.Role}
+	}
+	return c.Roles
+}
+
+// Scopes renvoie les portées du jeton, séparées par des espaces dans la claim
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Grant décrit le titulaire d'un jeton à émettre
+type Grant struct {
+	UserID     uint
+	Role       string
+	Roles      []string
+	Scope      string
+	KermesseID *uint
+}
+
+type keyring struct {
+	activeID string
+	keys     map[string][]byte
+	issuer   string
+	audience string
+}
+
+var (
+	ring     *keyring
+	ringOnce sync.Once
+)
+
+// loadKeyring lit la configuration au premier usage, une fois les variables d'environnement chargées.
+// JWT_KEYS contient des paires "kid:secret" séparées par des virgules et JWT_ACTIVE_KID désigne la clé
+// de signature ; les autres clés restent acceptées en validation pendant une rotation.
+// Sans JWT_KEYS, SECRET_KEY est utilisée comme unique clé.
+func loadKeyring() *keyring {
+	ringOnce.Do(func() {
+		r := &keyring{
+			keys:     make(map[string][]byte),
+			issuer:   envOrDefault("JWT_ISSUER", defaultIssuer),
+			audience: envOrDefault("JWT_AUDIENCE", defaultAudience),
+		}
+
+		for _, pair := range strings.Split(os.Getenv("JWT_KEYS"), ",") {
+			kid, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || kid == "" || secret == "" {
+				continue
+			}
+			r.keys[kid] = []byte(secret)
+			if r.activeID == "" {
+				r.activeID = kid
+			}
+		}
+
+		if len(r.keys) == 0 {
+			if secret := os.Getenv("SECRET_KEY"); secret != "" {
+				r.keys[defaultKeyID] = []byte(secret)
+				r.activeID = defaultKeyID
+			}
+		}
+
+		if active := os.Getenv("JWT_ACTIVE_KID"); active != "" {
+			if _, ok := r.keys[active]; ok {
+				r.activeID = active
+			}
+		}
+
+		ring = r
+	})
+	return ring
+}
+
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func newTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Issue signe un jeton d'accès avec la clé active
+func Issue(grant Grant, ttl time.Duration) (string, *Claims, error) {
+	r := loadKeyring()
+	secret, ok := r.keys[r.activeID]
+	if !ok {
+		return "", nil, ErrNoSigningKey
+	}
+
+	jti, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID:     grant.UserID,
+		Role:       grant.Role,
+		Roles:      grant.Roles,
+		Scope:      grant.Scope,
+		KermesseID: grant.KermesseID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    r.issuer,
+			Audience:  jwt.ClaimStrings{r.audience},
+			Subject:   fmt.Sprint(grant.UserID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = r.activeID
+
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// Parse valide la signature, l'émetteur, l'audience et l'expiration d'un jeton
+// et renvoie ses claims. Toute entrée invalide produit une erreur, jamais une panique.
+func Parse(tokenString string) (*Claims, error) {
+	r := loadKeyring()
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(r.issuer),
+		jwt.WithAudience(r.audience),
+		jwt.WithExpirationRequired(),
+	)
+
+	claims := &Claims{}
+	_, err := parser.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		secret, ok := r.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return secret, nil
+	})
+
+	switch {
+	case err == nil:
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, ErrExpired
+	case errors.Is(err, ErrUnknownKey):
+		return nil, ErrUnknownKey
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return nil, ErrBadSignature
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return nil, ErrMalformed
+	default:
+		return nil, ErrInvalidClaims
+	}
+
+	if claims.UserID == 0 || claims.Role == "" || claims.ID == "" || (claims.KermesseID != nil && *claims.KermesseID == 0) {
+		return nil, ErrInvalidClaims
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,71 @@
+package token
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_KEYS", "current:test-secret")
+	os.Setenv("JWT_ACTIVE_KID", "current")
+	os.Exit(m.Run())
+}
+
+func TestParse(t *testing.T) {
+	kermesseID := uint(7)
+	valid, _, err := Issue(Grant{UserID: 1, Role: "PARENT", KermesseID: &kermesseID}, time.Minute)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	expired, _, err := Issue(Grant{UserID: 1, Role: "PARENT"}, -time.Minute)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	unknownKey := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID: 1,
+		Role:   "PARENT",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti",
+			Issuer:    defaultIssuer,
+			Audience:  jwt.ClaimStrings{defaultAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	unknownKey.Header["kid"] = "retired"
+	signedUnknownKey, err := unknownKey.SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		claims, err := Parse(valid)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if claims.UserID != 1 || claims.KermesseID == nil || *claims.KermesseID != kermesseID {
+			t.Fatalf("claims = %+v, want user 1 and kermesse %d", claims, kermesseID)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"malformed token", "not.a-token", ErrMalformed},
+		{"empty token", "", ErrMalformed},
+		{"expired token", expired, ErrExpired},
+		{"unknown kid", signedUnknownKey, ErrUnknownKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -3,10 +3,14 @@ package main
 import (
 	_ "example/hello/docs"
 	"example/hello/internal/apis/controller/kermesses"
+	"example/hello/internal/apis/controller/messages"
+	"example/hello/internal/apis/middleware"
 	"example/hello/internal/apis/router"
 	"example/hello/internal/config"
 	"example/hello/internal/initializers"
+	"example/hello/response"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 
@@ -36,6 +40,16 @@ func init() {
 func main() {
 
 	server := gin.Default()
+	server.Use(middleware.RequestID(), middleware.Recovery())
+
+	// Routes et méthodes inconnues renvoient aussi l'enveloppe d'erreur commune
+	server.HandleMethodNotAllowed = true
+	server.NoRoute(func(c *gin.Context) {
+		response.Fail(c, response.ErrNotFound.WithMessage("Route not found"))
+	})
+	server.NoMethod(func(c *gin.Context) {
+		response.Fail(c, response.ErrNotFound.WithMessage("Method not allowed").WithStatus(http.StatusMethodNotAllowed))
+	})
 
 	// Chargement du fichier .env
 	if err := godotenv.Load(); err != nil {
@@ -57,6 +71,7 @@ func main() {
 	router.StockRoutes(server)
 	router.JetonsTransactionRoutes(server)
 	router.MessageRoutes(server)
+	router.InvitationRoutes(server)
 	router.SetupStripeWebhookRoute(server)
 	router.ParentRoutes(server)
 
@@ -76,7 +91,8 @@ func main() {
 	corsConfig := cors.Config{
 		AllowOrigins:     origins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID"},
+		ExposeHeaders:    []string{"X-Request-ID"},
 		AllowCredentials: true,
 	}
 	server.Use(cors.New(corsConfig))
@@ -90,6 +106,9 @@ func main() {
 		log.Fatalf("Failed to load kermesse plans: %v", err)
 	}
 
+	// Les gagnants d'un tirage dont la diffusion a été interrompue par un redémarrage sont prévenus
+	messages.ResumeWinnerNotifications()
+
 	// Configuration de Swagger
 	server.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -0,0 +1,109 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Patch est un champ de mise à jour partielle au sens de JSON Merge Patch (RFC 7396) :
+// absent, le champ est conservé ; présent, il est remplacé. null efface le champ et n'est
+// accepté que si T est un pointeur.
+type Patch[T any] struct {
+	Set   bool
+	Value T
+}
+
+func (p *Patch[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) && reflect.TypeOf(&p.Value).Elem().Kind() != reflect.Ptr {
+		return errors.New("null is not allowed for this field")
+	}
+	if err := json.Unmarshal(data, &p.Value); err != nil {
+		return err
+	}
+	p.Set = true
+	return nil
+}
+
+// Apply recopie la valeur dans target si le champ a été envoyé
+func (p Patch[T]) Apply(target *T) {
+	if p.Set {
+		*target = p.Value
+	}
+}
+
+// patchValue présente au validateur la valeur envoyée. Un champ absent ou null devient un
+// pointeur nil, que la règle omitnil écarte : les règles binding "omitnil,min=1,..." ne portent
+// ainsi que sur les valeurs réellement envoyées, valeurs vides comprises.
+func patchValue(field reflect.Value) interface{} {
+	value := field.FieldByName("Value")
+	if !field.FieldByName("Set").Bool() {
+		if value.Kind() == reflect.Ptr {
+			return reflect.Zero(value.Type()).Interface()
+		}
+		return reflect.Zero(reflect.PointerTo(value.Type())).Interface()
+	}
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	return value.Interface()
+}
+
+// UnknownFieldError signale un champ absent de la liste blanche du DTO
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Field)
+}
+
+func (e UnknownFieldError) UnknownField() string {
+	return e.Field
+}
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterCustomTypeFunc(patchValue,
+			Patch[string]{}, Patch[int]{}, Patch[int64]{}, Patch[uint]{}, Patch[float64]{}, Patch[time.Time]{},
+			Patch[*string]{}, Patch[*int]{}, Patch[*int64]{}, Patch[*uint]{}, Patch[*time.Time]{})
+		// Les erreurs de validation désignent les champs par leur nom JSON
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// BindStrict décode le corps JSON en refusant les champs qui ne figurent pas dans obj,
+// puis applique les règles binding. Les DTO servent ainsi de liste blanche.
+func BindStrict(c *gin.Context, obj interface{}) error {
+	if c.Request.Body == nil {
+		return errors.New("empty request body")
+	}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("empty request body")
+		}
+		// Le décodeur ne type pas cette erreur : "json: unknown field \"role\""
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return UnknownFieldError{Field: strings.Trim(field, `"`)}
+		}
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
@@ -6,34 +6,211 @@ type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"required"`
+	// Seul PARENT est accepté ; les autres rôles s'obtiennent par invitation
+	Role string `json:"role"`
 }
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required,min=6"`
+	DeviceName string `json:"device_name"`
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	DeviceName   string `json:"device_name"`
+}
+
+type CreateInvitationRequest struct {
+	Email      string `json:"email" binding:"required,email"`
+	Role       string `json:"role" binding:"required"`
+	KermesseID *uint  `json:"kermesse_id"`
+	StandID    *uint  `json:"stand_id"`
+}
+
+// Name et Password ne sont requis que si aucun compte n'existe pour l'adresse invitée
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type RoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	DeviceName   string `json:"device_name"`
+}
+
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	AllDevices   bool   `json:"all_devices"`
+}
+
+// AddChildRequest : sans e-mail, l'enfant reçoit un identifiant et un code PIN générés
 type AddChildRequest struct {
 	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	Password string `json:"password" binding:"omitempty,min=6"`
+	Role     string `json:"role"`
+}
+
+type PINLoginRequest struct {
+	Username   string `json:"username" binding:"required"`
+	PIN        string `json:"pin" binding:"required"`
+	DeviceName string `json:"device_name"`
+}
+
+type QRLoginRequest struct {
+	Code       string `json:"code" binding:"required"`
+	DeviceName string `json:"device_name"`
+}
+
+type CreateUserRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"required"`
+	Role     string `json:"role" binding:"required,oneof=ELEVE PARENT TENEUR_STAND ORGANISATEUR ADMIN"`
+}
+
+// UpdateMeRequest : le rôle, le solde, l'e-mail et le mot de passe ont leurs propres parcours
+type UpdateMeRequest struct {
+	Name Patch[string] `json:"name" binding:"omitnil,min=1,max=100"`
+	// Language choisit la langue des messages et des e-mails ; null revient à Accept-Language
+	Language Patch[*string] `json:"language" binding:"omitnil,oneof=fr en"`
+}
+
+type CreateKermesseRequest struct {
+	Nom            string    `json:"nom" binding:"required,max=100"`
+	Date           time.Time `json:"date" binding:"required"`
+	Lieu           string    `json:"lieu" binding:"required,max=200"`
+	PlanInteractif string    `json:"plan_interactif"`
+}
+
+// UpdateKermesseRequest : plan_interactif à null efface le plan
+type UpdateKermesseRequest struct {
+	Nom            Patch[string]    `json:"nom" binding:"omitnil,min=1,max=100"`
+	Date           Patch[time.Time] `json:"date"`
+	Lieu           Patch[string]    `json:"lieu" binding:"omitnil,min=1,max=200"`
+	PlanInteractif Patch[*string]   `json:"plan_interactif"`
 }
 
+// Les compteurs jetons_collectes et points_attribues partent de zéro et ne bougent qu'avec les ventes et attributions
 type CreateStandRequest struct {
-	Nom             string `json:"nom" binding:"required"`
-	Type            string `json:"type" binding:"required"`
-	KermesseID      uint   `json:"kermesse_id" binding:"required"`
-	TeneurID        uint   `json:"teneur_id" binding:"required"`
-	PositionX       int    `json:"position_x"`
-	PositionY       int    `json:"position_y"`
-	JetonsCollectes int    `json:"jetons_collectes"`
-	PointsAttribues int    `json:"points_attribues"`
+	Nom        string `json:"nom" binding:"required,max=100"`
+	Type       string `json:"type" binding:"required,oneof=NOURRITURE BOISSON ACTIVITES"`
+	KermesseID uint   `json:"kermesse_id" binding:"required"`
+	TeneurID   uint   `json:"teneur_id" binding:"required"`
+	PositionX  int    `json:"position_x" binding:"gte=0"`
+	PositionY  int    `json:"position_y" binding:"gte=0"`
+}
+
+type UpdateStandRequest struct {
+	Nom       Patch[string] `json:"nom" binding:"omitnil,min=1,max=100"`
+	Type      Patch[string] `json:"type" binding:"omitnil,oneof=NOURRITURE BOISSON ACTIVITES"`
+	TeneurID  Patch[uint]   `json:"teneur_id" binding:"omitnil,gt=0"`
+	PositionX Patch[int]    `json:"position_x" binding:"omitnil,gte=0"`
+	PositionY Patch[int]    `json:"position_y" binding:"omitnil,gte=0"`
+}
+
+type CreateStockRequest struct {
+	NomProduit   string `json:"nom_produit" binding:"required,max=100"`
+	Quantite     int    `json:"quantite" binding:"gte=0"`
+	PrixEnJetons int    `json:"prix_en_jetons" binding:"gte=0"`
+}
+
+// UpdateStockRequest : la quantité ne change que par /stocks/{id}/adjust
+type UpdateStockRequest struct {
+	NomProduit   Patch[string] `json:"nom_produit" binding:"omitnil,min=1,max=100"`
+	PrixEnJetons Patch[int]    `json:"prix_en_jetons" binding:"omitnil,gte=0"`
 }
 
+// CreateLotRequest : sans quantité ni rang, le lot est unique et de rang 1
+type CreateLotRequest struct {
+	Nom         string  `json:"nom" binding:"required,max=100"`
+	Description string  `json:"description" binding:"max=500"`
+	Valeur      float64 `json:"valeur" binding:"gte=0"`
+	Quantite    int     `json:"quantite" binding:"omitempty,gt=0,lte=1000" example:"1"`
+	Rang        int     `json:"rang" binding:"omitempty,gt=0" example:"1"`
+}
+
+type UpdateLotRequest struct {
+	Nom         Patch[string]  `json:"nom" binding:"omitnil,min=1,max=100"`
+	Description Patch[string]  `json:"description" binding:"omitnil,max=500"`
+	Valeur      Patch[float64] `json:"valeur" binding:"omitnil,gte=0"`
+	Quantite    Patch[int]     `json:"quantite" binding:"omitnil,gt=0,lte=1000"`
+	Rang        Patch[int]     `json:"rang" binding:"omitnil,gt=0"`
+}
+
+// CreateTombolaRequest : sans prix, le ticket coûte 2 jetons ; les dates de vente, les plafonds, la remise et la règle de gain sont facultatifs
 type CreateTombolaRequest struct {
-	Nom        string `json:"nom" binding:"required"`
-	KermesseID uint   `json:"kermesse_id" binding:"required"`
+	Nom               string     `json:"nom" binding:"required,max=100"`
+	KermesseID        uint       `json:"kermesse_id" binding:"required"`
+	PrixTicket        int        `json:"prix_ticket" binding:"omitempty,gt=0" example:"2"`
+	VenteDebut        *time.Time `json:"vente_debut"`
+	VenteFin          *time.Time `json:"vente_fin"`
+	MaxTicketsParUser *int       `json:"max_tickets_par_user" binding:"omitempty,gt=0" example:"10"`
+	MaxTickets        *int       `json:"max_tickets" binding:"omitempty,gt=0" example:"500"`
+	RemiseQuantite    int        `json:"remise_quantite" binding:"omitempty,gt=0" example:"5"`
+	RemisePourcent    int        `json:"remise_pourcent" binding:"omitempty,gte=1,lte=99" example:"20"`
+	RegleGain         string     `json:"regle_gain" binding:"omitempty,oneof=NONE ONE_PER_USER ONE_PER_FAMILY" example:"NONE"`
+	DateLimiteRetrait *time.Time `json:"date_limite_retrait"`
+}
+
+// UpdateTombolaRequest : null efface une date de vente ou un plafond, remise_quantite à 0 supprime la remise
+type UpdateTombolaRequest struct {
+	Nom               Patch[string]     `json:"nom" binding:"omitnil,min=1,max=100"`
+	PrixTicket        Patch[int]        `json:"prix_ticket" binding:"omitnil,gt=0"`
+	VenteDebut        Patch[*time.Time] `json:"vente_debut"`
+	VenteFin          Patch[*time.Time] `json:"vente_fin"`
+	MaxTicketsParUser Patch[*int]       `json:"max_tickets_par_user" binding:"omitnil,gt=0"`
+	MaxTickets        Patch[*int]       `json:"max_tickets" binding:"omitnil,gt=0"`
+	RemiseQuantite    Patch[int]        `json:"remise_quantite" binding:"omitnil,gte=0"`
+	RemisePourcent    Patch[int]        `json:"remise_pourcent" binding:"omitnil,gte=0,lte=99"`
+	RegleGain         Patch[string]     `json:"regle_gain" binding:"omitnil,oneof=NONE ONE_PER_USER ONE_PER_FAMILY"`
+	DateLimiteRetrait Patch[*time.Time] `json:"date_limite_retrait"`
+}
+
+// BuyTicketRequest : sans quantité, un seul ticket est acheté
+type BuyTicketRequest struct {
+	UserID   uint `json:"user_id" binding:"required"`
+	Quantite int  `json:"quantite" binding:"omitempty,gt=0,lte=100" example:"1"`
+}
+
+// DrawRequest : la valeur publique est annoncée devant les participants au moment du tirage
+// reveal_delay est le délai en secondes entre deux étapes de la diffusion en direct du tirage
+type DrawRequest struct {
+	PublicValue string `json:"public_value" binding:"required,max=128" example:"4721"`
+	RevealDelay *int   `json:"reveal_delay" binding:"omitempty,min=0,max=30" example:"3"`
+}
+
+type VoidDrawRequest struct {
+	Reason string `json:"reason" binding:"required,min=3,max=500"`
+}
+
+type CancelTombolaRequest struct {
+	Reason string `json:"reason" binding:"required,min=3,max=500"`
+}
+
+// HandOverPrizeRequest : qr_code est le contenu du QR code scanné sur le téléphone du gagnant
+type HandOverPrizeRequest struct {
+	QRCode string `json:"qr_code" binding:"required,max=1024"`
+}
+
+// RedrawUnclaimedRequest : la nouvelle date limite de retrait s'applique aux gagnants remplaçants
+type RedrawUnclaimedRequest struct {
+	DateLimiteRetrait time.Time `json:"date_limite_retrait" binding:"required"`
 }
 
 type CreateJetonsTransactionRequest struct {
@@ -57,8 +234,72 @@ type AttributeJetonsRequest struct {
 	Amount   int  `json:"amount" example:"20"`
 }
 
+type GuardianRequest struct {
+	Email      string `json:"email" binding:"required,email"`
+	Permission string `json:"permission" binding:"required"`
+}
+
+type GuardianPermissionRequest struct {
+	Permission string `json:"permission" binding:"required"`
+}
+
+// SpendingLimitRequest : un plafond absent ou nul supprime la limite
+type SpendingLimitRequest struct {
+	PlafondJournalier *int64 `json:"plafond_journalier" binding:"omitempty,gte=0"`
+}
+
+type ErasureRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ProcessErasureRequest struct {
+	Approve *bool  `json:"approve" binding:"required"`
+	Motif   string `json:"motif"`
+}
+
 type PaymentRequest struct {
 	UserID   uint `json:"user_id" binding:"required"`
 	StandID  uint `json:"stand_id" binding:"required"`
 	Quantity int  `json:"quantity" binding:"required,gt=0"`
 }
+
+// CreateOfflineAllowanceRequest : consent est le QR code présenté par le client, émis pour ce stand et ce plafond
+type CreateOfflineAllowanceRequest struct {
+	UserID       uint   `json:"user_id" binding:"required"`
+	Plafond      int64  `json:"plafond" binding:"required,gt=0,lte=200"`
+	DureeMinutes int    `json:"duree_minutes" binding:"omitempty,gt=0,lte=1440"`
+	Consent      string `json:"consent" binding:"required"`
+}
+
+// OfflineConsentRequest : le plafond d'une autorisation hors ligne est limité à 200 jetons, quel que soit le solde
+type OfflineConsentRequest struct {
+	Plafond int64 `json:"plafond" binding:"required,gt=0,lte=200" example:"20"`
+}
+
+type OfflineSaleRequest struct {
+	ClientRef          string    `json:"client_ref" binding:"required"`
+	AllowanceID        uint      `json:"allowance_id" binding:"required"`
+	AllowanceSignature string    `json:"allowance_signature" binding:"required"`
+	UserID             uint      `json:"user_id" binding:"required"`
+	StockID            uint      `json:"stock_id" binding:"required"`
+	Quantite           int       `json:"quantite" binding:"required,gt=0"`
+	RecordedAt         time.Time `json:"recorded_at" binding:"required"`
+	Signature          string    `json:"signature" binding:"required"`
+}
+
+type OfflineSyncRequest struct {
+	Sales []OfflineSaleRequest `json:"sales" binding:"required,dive"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
@@ -0,0 +1,266 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"example/hello/i18n"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// RequestIDKey est la clé de contexte gin de l'identifiant de requête
+const RequestIDKey = "requestID"
+
+// ErrorResponse est l'enveloppe unique des erreurs de l'API :
+// {"error": {"code": "...", "message": "...", "fields": [...], "request_id": "..."}}
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	// Code est stable : les clients s'appuient dessus pour traduire le message
+	Code      string       `json:"code" example:"STAND_NOT_FOUND"`
+	Message   string       `json:"message" example:"Stand not found"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// FieldError décrit un champ refusé par la validation ; Code reprend la règle en échec (required, min, email...)
+type FieldError struct {
+	Field   string `json:"field" example:"nom"`
+	Code    string `json:"code" example:"required"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message" example:"This field is required"`
+	// args complète Message lorsque celui-ci est un format
+	args []interface{}
+}
+
+// APIError associe un code d'erreur à son statut HTTP et à son message par défaut.
+// Les messages sont écrits en anglais et traduits par Fail dans la langue de la requête.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+func (e APIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// WithMessage précise le message sans changer le code
+func (e APIError) WithMessage(message string) APIError {
+	e.Message = message
+	return e
+}
+
+// WithStatus change le statut HTTP pour les rares cas où le contexte l'impose
+func (e APIError) WithStatus(status int) APIError {
+	e.Status = status
+	return e
+}
+
+func newError(status int, code, message string) APIError {
+	return APIError{Status: status, Code: code, Message: message}
+}
+
+var (
+	ErrInvalidRequest = newError(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request format")
+	ErrValidation     = newError(http.StatusBadRequest, "VALIDATION_FAILED", "Some fields are invalid")
+	ErrInvalidID      = newError(http.StatusBadRequest, "INVALID_ID", "Invalid identifier")
+	ErrInternal       = newError(http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error")
+	ErrNotFound       = newError(http.StatusNotFound, "NOT_FOUND", "Resource not found")
+	ErrForbidden      = newError(http.StatusForbidden, "FORBIDDEN", "Access denied")
+
+	// Authentification
+	ErrUnauthenticated       = newError(http.StatusUnauthorized, "UNAUTHENTICATED", "Authentication required")
+	ErrInvalidCredentials    = newError(http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid credentials")
+	ErrTokenInvalid          = newError(http.StatusUnauthorized, "TOKEN_INVALID", "Invalid or expired token")
+	ErrTokenExpired          = newError(http.StatusUnauthorized, "TOKEN_EXPIRED", "Token expired")
+	ErrTokenRevoked          = newError(http.StatusUnauthorized, "TOKEN_REVOKED", "Token has been revoked")
+	ErrTokenScope            = newError(http.StatusForbidden, "TOKEN_SCOPE_FORBIDDEN", "Token scope does not allow this action")
+	ErrRefreshTokenInvalid   = newError(http.StatusUnauthorized, "REFRESH_TOKEN_INVALID", "Invalid refresh token")
+	ErrRefreshTokenExpired   = newError(http.StatusUnauthorized, "REFRESH_TOKEN_EXPIRED", "Refresh token expired")
+	ErrRefreshTokenRevoked   = newError(http.StatusUnauthorized, "REFRESH_TOKEN_REVOKED", "Refresh token has been revoked")
+	ErrLinkInvalid           = newError(http.StatusBadRequest, "LINK_INVALID", "Invalid or expired token")
+	ErrLoginCodeInvalid      = newError(http.StatusUnauthorized, "LOGIN_CODE_INVALID", "Invalid or expired code")
+	ErrAccountLocked         = newError(http.StatusLocked, "ACCOUNT_LOCKED", "Account temporarily locked")
+	ErrTooManyAttempts       = newError(http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "Too many login attempts, try again later")
+	ErrEmailNotVerified      = newError(http.StatusForbidden, "EMAIL_NOT_VERIFIED", "Email address not verified")
+	ErrEmailAlreadyVerified  = newError(http.StatusConflict, "EMAIL_ALREADY_VERIFIED", "Email already verified")
+	ErrEmailInUse            = newError(http.StatusConflict, "EMAIL_IN_USE", "Email already in use")
+	ErrMFATokenInvalid       = newError(http.StatusUnauthorized, "MFA_TOKEN_INVALID", "Invalid or expired MFA token")
+	ErrMFACodeInvalid        = newError(http.StatusBadRequest, "MFA_CODE_INVALID", "Invalid two-factor code")
+	ErrMFANotEnabled         = newError(http.StatusBadRequest, "MFA_NOT_ENABLED", "Two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled     = newError(http.StatusConflict, "MFA_ALREADY_ENABLED", "Two-factor authentication already enabled")
+	ErrMFAEnrollmentRequired = newError(http.StatusForbidden, "MFA_ENROLLMENT_REQUIRED", "Two-factor authentication is mandatory for this role")
+
+	// Rôles et invitations
+	ErrInvalidRole          = newError(http.StatusBadRequest, "INVALID_ROLE", "Invalid role")
+	ErrRoleNotHeld          = newError(http.StatusForbidden, "ROLE_NOT_HELD", "Role not held by user")
+	ErrLastRole             = newError(http.StatusConflict, "LAST_ROLE", "Cannot remove the last role of a user")
+	ErrRegistrationRole     = newError(http.StatusForbidden, "REGISTRATION_ROLE_FORBIDDEN", "Only parent accounts can self-register, other roles require an invitation")
+	ErrInvitationInvalid    = newError(http.StatusBadRequest, "INVITATION_INVALID", "Invalid or expired invitation")
+	ErrInvitationAccepted   = newError(http.StatusConflict, "INVITATION_ALREADY_ACCEPTED", "Invitation already accepted")
+	ErrInvitationNotPending = newError(http.StatusConflict, "INVITATION_NOT_PENDING", "Invitation is no longer pending")
+	ErrKermesseRequired     = newError(http.StatusBadRequest, "KERMESSE_REQUIRED", "kermesse_id is required for this role")
+	ErrStandNotAllowed      = newError(http.StatusBadRequest, "STAND_NOT_ALLOWED", "stand_id is only allowed for TENEUR_STAND invitations")
+	ErrNotKermesseOrganiser = newError(http.StatusForbidden, "NOT_KERMESSE_ORGANISER", "You are not an organiser of this kermesse")
+
+	// Familles
+	ErrNotGuardian        = newError(http.StatusForbidden, "NOT_GUARDIAN", "You are not a guardian of this child")
+	ErrGuardianPermission = newError(http.StatusForbidden, "GUARDIAN_PERMISSION_DENIED", "Guardian permission does not allow this action")
+	ErrInvalidPermission  = newError(http.StatusBadRequest, "INVALID_PERMISSION", "Permission must be FULL, FUND_ONLY or VIEW_ONLY")
+	ErrInvalidGuardian    = newError(http.StatusBadRequest, "INVALID_GUARDIAN", "A child cannot be their own guardian")
+	ErrLastFullGuardian   = newError(http.StatusConflict, "LAST_FULL_GUARDIAN", "A child must keep at least one guardian with full permission")
+	ErrInvalidUserType    = newError(http.StatusBadRequest, "INVALID_USER_TYPE", "User is neither a parent nor a student")
+	ErrErasurePending     = newError(http.StatusConflict, "ERASURE_ALREADY_PENDING", "An erasure request is already pending for this account")
+	ErrErasureProcessed   = newError(http.StatusConflict, "ERASURE_ALREADY_PROCESSED", "Erasure request already processed")
+	ErrAlreadyErased      = newError(http.StatusConflict, "ALREADY_ERASED", "Account already anonymised")
+
+	// Ventes, stocks et tombolas
+	ErrDailyLimitExceeded     = newError(http.StatusForbidden, "DAILY_LIMIT_EXCEEDED", "Daily spending limit exceeded")
+	ErrInsufficientBalance    = newError(http.StatusBadRequest, "INSUFFICIENT_BALANCE", "Insufficient jeton balance")
+	ErrStockExhausted         = newError(http.StatusBadRequest, "STOCK_EXHAUSTED", "No stock available for this stand")
+	ErrInvalidQuantity        = newError(http.StatusBadRequest, "INVALID_QUANTITY", "Stock quantity cannot be negative")
+	ErrStandNotInKermesse     = newError(http.StatusBadRequest, "STAND_NOT_IN_KERMESSE", "Stand does not belong to this kermesse")
+	ErrNoLotsAvailable        = newError(http.StatusBadRequest, "NO_LOTS_AVAILABLE", "No lots available for the draw")
+	ErrNoTicketsSold          = newError(http.StatusBadRequest, "NO_TICKETS_SOLD", "No tickets available for the draw")
+	ErrTicketSalesNotOpen     = newError(http.StatusConflict, "TICKET_SALES_NOT_OPEN", "Ticket sales have not opened yet")
+	ErrTicketSalesClosed      = newError(http.StatusConflict, "TICKET_SALES_CLOSED", "Ticket sales are closed for this tombola")
+	ErrTicketsSoldOut         = newError(http.StatusConflict, "TICKETS_SOLD_OUT", "Not enough tickets left for this tombola")
+	ErrTicketLimitReached     = newError(http.StatusConflict, "TICKET_LIMIT_REACHED", "Ticket limit per user reached for this tombola")
+	ErrTombolaNotDrawn        = newError(http.StatusConflict, "TOMBOLA_NOT_DRAWN", "The draw has not taken place yet")
+	ErrTombolaCancelled       = newError(http.StatusConflict, "TOMBOLA_CANCELLED", "This tombola has been cancelled")
+	ErrTombolaAlreadyDrawn    = newError(http.StatusConflict, "TOMBOLA_ALREADY_DRAWN", "The draw must be voided before the tombola can be cancelled")
+	ErrPrizeNotClaimable      = newError(http.StatusConflict, "PRIZE_NOT_CLAIMABLE", "This prize can no longer be claimed")
+	ErrPrizeAlreadyHandedOver = newError(http.StatusConflict, "PRIZE_ALREADY_HANDED_OVER", "This prize has already been handed over")
+	ErrClaimDeadlinePassed    = newError(http.StatusConflict, "CLAIM_DEADLINE_PASSED", "The claim deadline has passed")
+	ErrInvalidHandoverCode    = newError(http.StatusBadRequest, "INVALID_HANDOVER_CODE", "Invalid prize QR code for this tombola")
+	ErrNoClaimDeadline        = newError(http.StatusConflict, "NO_CLAIM_DEADLINE", "No claim deadline is set for this tombola")
+	ErrClaimDeadlineNotPassed = newError(http.StatusConflict, "CLAIM_DEADLINE_NOT_PASSED", "The claim deadline has not passed yet")
+	ErrOfflineConsentUsed     = newError(http.StatusConflict, "OFFLINE_CONSENT_USED", "Authorisation code already used")
+	ErrLotsLocked             = newError(http.StatusConflict, "LOTS_LOCKED", "Lots cannot change once the tombola is drawn")
+	ErrDrawRevealing          = newError(http.StatusConflict, "DRAW_REVEALING", "The draw is still being revealed live")
+	ErrPublicValueReused      = newError(http.StatusConflict, "PUBLIC_VALUE_REUSED", "A re-draw needs a new public value, different from those of the voided draws")
+	ErrDrawNotCommitted       = newError(http.StatusConflict, "DRAW_NOT_COMMITTED", "No seed commitment was published before ticket sales")
+
+	// Imports
+	ErrFileRequired   = newError(http.StatusBadRequest, "FILE_REQUIRED", "File is required")
+	ErrFileTooLarge   = newError(http.StatusBadRequest, "FILE_TOO_LARGE", "File is too large")
+	ErrFileUnreadable = newError(http.StatusBadRequest, "FILE_UNREADABLE", "Failed to read file")
+
+	// Ressources introuvables
+	ErrUserNotFound          = newError(http.StatusNotFound, "USER_NOT_FOUND", "User not found")
+	ErrChildNotFound         = newError(http.StatusNotFound, "CHILD_NOT_FOUND", "Child not found")
+	ErrParentNotFound        = newError(http.StatusNotFound, "PARENT_NOT_FOUND", "Parent not found")
+	ErrGuardianNotFound      = newError(http.StatusNotFound, "GUARDIAN_NOT_FOUND", "Guardian not found")
+	ErrTeneurNotFound        = newError(http.StatusNotFound, "TENEUR_NOT_FOUND", "Teneur not found")
+	ErrKermesseNotFound      = newError(http.StatusNotFound, "KERMESSE_NOT_FOUND", "Kermesse not found")
+	ErrStandNotFound         = newError(http.StatusNotFound, "STAND_NOT_FOUND", "Stand not found")
+	ErrInvalidOfflineConsent = newError(http.StatusForbidden, "INVALID_OFFLINE_CONSENT", "Invalid or expired customer authorisation code")
+	ErrNotStandManager       = newError(http.StatusForbidden, "NOT_STAND_MANAGER", "You do not hold this stand or organise its kermesse")
+	ErrStockNotFound         = newError(http.StatusNotFound, "STOCK_NOT_FOUND", "Stock not found")
+	ErrTombolaNotFound       = newError(http.StatusNotFound, "TOMBOLA_NOT_FOUND", "Tombola not found")
+	ErrLotNotFound           = newError(http.StatusNotFound, "LOT_NOT_FOUND", "Lot not found")
+	ErrWinnerNotFound        = newError(http.StatusNotFound, "WINNER_NOT_FOUND", "Gagnant not found")
+	ErrInvitationNotFound    = newError(http.StatusNotFound, "INVITATION_NOT_FOUND", "Invitation not found")
+	ErrMessageNotFound       = newError(http.StatusNotFound, "MESSAGE_NOT_FOUND", "Message not found")
+	ErrSessionNotFound       = newError(http.StatusNotFound, "SESSION_NOT_FOUND", "Session not found")
+	ErrErasureNotFound       = newError(http.StatusNotFound, "ERASURE_REQUEST_NOT_FOUND", "Erasure request not found")
+)
+
+// Internal signale une erreur serveur ; le message décrit l'opération qui a échoué
+func Internal(message string) APIError {
+	return ErrInternal.WithMessage(message)
+}
+
+// InvalidField signale un champ de la requête (corps, chemin ou paramètre) refusé ; args complète message lorsque celui-ci est un format
+func InvalidField(field, code, message string, args ...interface{}) APIError {
+	return ErrValidation.withFields(FieldError{Field: field, Code: code, Message: message, args: args})
+}
+
+func (e APIError) withFields(fields ...FieldError) APIError {
+	e.Fields = append(append([]FieldError(nil), e.Fields...), fields...)
+	return e
+}
+
+// BindingError convertit une erreur de lecture du corps en VALIDATION_FAILED avec le détail
+// des champs, ou en INVALID_REQUEST si le corps n'est pas un JSON exploitable. Une APIError est renvoyée telle quelle.
+func BindingError(err error) APIError {
+	var apiError APIError
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	var unknownField interface{ UnknownField() string }
+
+	switch {
+	case errors.As(err, &apiError):
+		return apiError
+	case errors.As(err, &validationErrors):
+		fields := make([]FieldError, 0, len(validationErrors))
+		for _, fieldError := range validationErrors {
+			message, args := ruleMessage(fieldError.Tag(), fieldError.Param())
+			fields = append(fields, FieldError{
+				Field:   fieldError.Field(),
+				Code:    fieldError.Tag(),
+				Param:   fieldError.Param(),
+				Message: message,
+				args:    args,
+			})
+		}
+		return ErrValidation.withFields(fields...)
+	case errors.As(err, &typeError):
+		return ErrValidation.withFields(FieldError{
+			Field:   typeError.Field,
+			Code:    "type",
+			Message: "Expected a value of type %s",
+			args:    []interface{}{typeError.Type.String()},
+		})
+	case errors.As(err, &unknownField):
+		return InvalidField(unknownField.UnknownField(), "unknown", "This field is not accepted")
+	}
+	return ErrInvalidRequest
+}
+
+// ruleMessage renvoie le message d'une règle de validation et ses arguments
+func ruleMessage(rule, param string) (string, []interface{}) {
+	switch rule {
+	case "required":
+		return "This field is required", nil
+	case "email":
+		return "Must be a valid email address", nil
+	case "min":
+		return "Must be at least %s", []interface{}{param}
+	case "max":
+		return "Must be at most %s", []interface{}{param}
+	case "gt":
+		return "Must be greater than %s", []interface{}{param}
+	case "gte":
+		return "Must be greater than or equal to %s", []interface{}{param}
+	case "lte":
+		return "Must be less than or equal to %s", []interface{}{param}
+	case "oneof":
+		return "Must be one of: %s", []interface{}{param}
+	}
+	return "Invalid value", nil
+}
+
+// Fail interrompt la requête et renvoie l'erreur dans l'enveloppe commune, traduite dans la langue de la requête
+func Fail(c *gin.Context, err APIError) {
+	lang := i18n.FromContext(c)
+
+	var fields []FieldError
+	for _, field := range err.Fields {
+		field.Message = i18n.Translate(lang, field.Message, field.args...)
+		fields = append(fields, field)
+	}
+
+	c.AbortWithStatusJSON(err.Status, ErrorResponse{Error: ErrorBody{
+		Code:      err.Code,
+		Message:   i18n.Translate(lang, err.Message),
+		Fields:    fields,
+		RequestID: c.GetString(RequestIDKey),
+	}})
+}
@@ -6,12 +6,41 @@ type SuccessResponse struct {
 	Data bool `json:"data"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
 }
 
-type TokenResponse struct {
-	Token string `json:"token"`
+type MFAChallengeResponse struct {
+	MFARequired        bool   `json:"mfa_required"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+	MFAToken           string `json:"mfa_token"`
+	ExpiresIn          int64  `json:"expires_in"`
+}
+
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAEnrollmentCompleteResponse struct {
+	TokenResponse
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type SessionResponse struct {
+	ID         uint       `json:"id"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
 }
 
 type UserResponse struct {
@@ -20,6 +49,59 @@ type UserResponse struct {
 	Email string `json:"email"`
 	Roles string `json:"roles"`
 	SoldeJetons int64 `json:"solde_jetons"`
+	EmailVerified bool `json:"email_verified"`
+	MFAEnabled bool `json:"mfa_enabled"`
+	AvailableRoles []string `json:"available_roles"`
+	Language string `json:"language,omitempty"`
+}
+
+type InvitationResponse struct {
+	ID         uint       `json:"id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	KermesseID *uint      `json:"kermesse_id"`
+	StandID    *uint      `json:"stand_id"`
+	Status     string     `json:"status"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type AcceptInvitationResponse struct {
+	UserID     uint   `json:"user_id"`
+	Role       string `json:"role"`
+	KermesseID *uint  `json:"kermesse_id"`
+	StandID    *uint  `json:"stand_id"`
+}
+
+type RosterImportResponse struct {
+	DryRun           bool             `json:"dry_run"`
+	Committed        bool             `json:"committed"`
+	Rows             int              `json:"rows"`
+	ParentsCreated   int              `json:"parents_created"`
+	ParentsExisting  int              `json:"parents_existing"`
+	StudentsCreated  int              `json:"students_created"`
+	StudentsExisting int              `json:"students_existing"`
+	Errors           []RosterRowError `json:"errors"`
+}
+
+type RosterRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type UserRolesResponse struct {
+	UserID     uint     `json:"user_id"`
+	ActiveRole string   `json:"active_role"`
+	Roles      []string `json:"roles"`
+}
+
+type ActiveRoleResponse struct {
+	ActiveRole string   `json:"active_role"`
+	Roles      []string `json:"roles"`
+	Token      string   `json:"token"`
+	ExpiresIn  int64    `json:"expires_in"`
 }
 
 type KermesseResponse struct {
@@ -52,12 +134,148 @@ type LotResponse struct {
 	Description string  `json:"description"`
 	TombolaID uint   `json:"tombola_id"`
 	Valeur      float64 `json:"valeur"`
+	Quantite    int     `json:"quantite"`
+	Rang        int     `json:"rang"`
 }
 
 type TombolaResponse struct {
 	ID         uint   `json:"id"`
 	Nom        string `json:"nom"`
 	KermesseID uint   `json:"kermesse_id"`
+	Status     string `json:"status"`
+	// Conditions de vente
+	PrixTicket        int        `json:"prix_ticket"`
+	VenteDebut        *time.Time `json:"vente_debut"`
+	VenteFin          *time.Time `json:"vente_fin"`
+	MaxTicketsParUser *int       `json:"max_tickets_par_user"`
+	MaxTickets        *int       `json:"max_tickets"`
+	RemiseQuantite    int        `json:"remise_quantite"`
+	RemisePourcent    int        `json:"remise_pourcent"`
+	RegleGain         string     `json:"regle_gain"`
+	DateLimiteRetrait *time.Time `json:"date_limite_retrait"`
+	// JetonsCollectes est la recette nette des tickets, remboursements déduits
+	JetonsCollectes int        `json:"jetons_collectes"`
+	CancelledAt     *time.Time `json:"cancelled_at,omitempty"`
+	// DrawCommitment est l'empreinte SHA-256 de la graine du tirage, révélée après celui-ci
+	DrawCommitment string     `json:"draw_commitment"`
+	DrawnAt        *time.Time `json:"drawn_at,omitempty"`
+}
+
+// LiveDrawEvent est une étape de la diffusion en direct d'un tirage : draw_started, lot_announced,
+// ticket_revealed, draw_finished ou draw_voided. mine signale au spectateur connecté que le ticket révélé est le sien
+type LiveDrawEvent struct {
+	Type      string `json:"type"`
+	TombolaID uint   `json:"tombola_id"`
+	Total     int    `json:"total,omitempty"`
+	Ordre     int    `json:"ordre,omitempty"`
+	LotID     uint   `json:"lot_id,omitempty"`
+	LotNom    string `json:"lot_nom,omitempty"`
+	Numero    string `json:"numero,omitempty"`
+	Mine      bool   `json:"mine,omitempty"`
+}
+
+// DrawVerificationResponse publie les éléments d'un tirage pour que chacun puisse recalculer les gagnants
+type DrawVerificationResponse struct {
+	TombolaID       uint                 `json:"tombola_id"`
+	Algorithm       string               `json:"algorithm"`
+	Commitment      string               `json:"commitment"`
+	Seed            string               `json:"seed"`
+	PublicValue     string               `json:"public_value"`
+	DrawnAt         time.Time            `json:"drawn_at"`
+	TicketIDs       []uint               `json:"ticket_ids"`
+	LotIDs          []uint               `json:"lot_ids"`
+	// AwardLotIDs sont les gains dans l'ordre d'attribution : lots par rang, répétés selon leur quantité
+	AwardLotIDs []uint `json:"award_lot_ids"`
+	PrizeRule   string `json:"prize_rule"`
+	// TicketGroups donne le groupe de chaque ticket de ticket_ids lorsque la règle de gain est restrictive
+	TicketGroups    []uint               `json:"ticket_groups,omitempty"`
+	Winners         []DrawWinnerResponse `json:"winners"`
+	CommitmentValid bool                 `json:"commitment_valid"`
+	WinnersMatch    bool                 `json:"winners_match"`
+	// VoidedDraws sont les tirages annulés précédemment, avec leur graine révélée
+	VoidedDraws []VoidedDrawResponse `json:"voided_draws"`
+	// RecommittedAt est la date de publication de l'empreinte en vigueur lorsqu'elle a remplacé celle d'un
+	// tirage annulé : elle a alors été publiée après la clôture de la vente
+	RecommittedAt *time.Time `json:"recommitted_at,omitempty"`
+}
+
+type VoidedDrawResponse struct {
+	ID          uint       `json:"id"`
+	Commitment  string     `json:"commitment"`
+	Seed        string     `json:"seed"`
+	PublicValue string     `json:"public_value"`
+	DrawnAt     *time.Time `json:"drawn_at"`
+	Reason      string     `json:"reason"`
+	VoidedByID  uint       `json:"voided_by_id"`
+	VoidedAt    time.Time  `json:"voided_at"`
+}
+
+type DrawWinnerResponse struct {
+	Ordre    int    `json:"ordre"`
+	LotID    uint   `json:"lot_id"`
+	TicketID uint   `json:"ticket_id"`
+	Numero   string `json:"numero"`
+	// ReplacesOrdre est l'ordre du gain non retiré dont ce gain a repris le lot
+	ReplacesOrdre int `json:"replaces_ordre,omitempty"`
+}
+
+// PrizeResponse décrit un gain et l'avancement de son retrait
+type PrizeResponse struct {
+	ID           uint       `json:"id"`
+	TombolaID    uint       `json:"tombola_id"`
+	UserID       uint       `json:"user_id"`
+	LotID        uint       `json:"lot_id"`
+	LotNom       string     `json:"lot_nom"`
+	TicketID     uint       `json:"ticket_id"`
+	Numero       string     `json:"numero"`
+	Ordre        int        `json:"ordre"`
+	Statut       string     `json:"statut"`
+	NotifiedAt   *time.Time `json:"notified_at"`
+	ClaimedAt    *time.Time `json:"claimed_at"`
+	HandedOverAt *time.Time `json:"handed_over_at"`
+}
+
+// PrizeClaimResponse : qr_code est à afficher en QR code lors du retrait du lot ; il expire à qr_expires_at
+type PrizeClaimResponse struct {
+	Prize       PrizeResponse `json:"prize"`
+	QRCode      string        `json:"qr_code"`
+	QRExpiresAt time.Time     `json:"qr_expires_at"`
+}
+
+type PrizeRedrawResponse struct {
+	Unclaimed         int             `json:"unclaimed"`
+	Winners           []PrizeResponse `json:"winners"`
+	DateLimiteRetrait time.Time       `json:"date_limite_retrait"`
+}
+
+// TombolaRefundResponse résume les remboursements effectués à l'annulation d'une tombola
+type TombolaRefundResponse struct {
+	Message         string `json:"message"`
+	TombolaID       uint   `json:"tombola_id"`
+	BuyersRefunded  int    `json:"buyers_refunded"`
+	TicketsRefunded int    `json:"tickets_refunded"`
+	JetonsRefunded  int    `json:"jetons_refunded"`
+}
+
+// TombolaSalesReportResponse : les montants proviennent du journal des jetons, recette nette = encaissés - remboursés
+type TombolaSalesReportResponse struct {
+	TombolaID         uint                       `json:"tombola_id"`
+	Nom               string                     `json:"nom"`
+	Status            string                     `json:"status"`
+	PrixTicket        int                        `json:"prix_ticket"`
+	TicketsVendus     int64                      `json:"tickets_vendus"`
+	TicketsRembourses int64                      `json:"tickets_rembourses"`
+	Acheteurs         int64                      `json:"acheteurs"`
+	JetonsEncaisses   int64                      `json:"jetons_encaisses"`
+	JetonsRembourses  int64                      `json:"jetons_rembourses"`
+	RecetteNette      int64                      `json:"recette_nette"`
+	ParJour           []DailyTicketSalesResponse `json:"par_jour"`
+}
+
+type DailyTicketSalesResponse struct {
+	Jour   string `json:"jour"`
+	Achats int    `json:"achats"`
+	Jetons int64  `json:"jetons"`
 }
 
 type JetonCollectesResponse struct {
@@ -73,6 +291,60 @@ type PointsAttribuesResponse struct {
 type SuccessAddChildResponse struct {
 	Message string `json:"message"`
 	Data    bool   `json:"data"`
+	// Username et PIN ne sont renvoyés qu'une fois, à la création d'un compte enfant sans e-mail
+	Username string `json:"username,omitempty"`
+	PIN      string `json:"pin,omitempty"`
+}
+
+type GuardianResponse struct {
+	ParentID   uint      `json:"parent_id"`
+	UserID     uint      `json:"user_id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Permission string    `json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type GuardedChildResponse struct {
+	EleveID    uint   `json:"eleve_id"`
+	UserID     uint   `json:"user_id"`
+	Name       string `json:"name"`
+	Permission string `json:"permission"`
+}
+
+type SpendingLimitResponse struct {
+	EleveID           uint   `json:"eleve_id"`
+	PlafondJournalier *int64 `json:"plafond_journalier"`
+	DepenseJour       int64  `json:"depense_jour"`
+	Restant           *int64 `json:"restant"`
+}
+
+type ErasureRequestResponse struct {
+	ID            uint       `json:"id"`
+	UserID        uint       `json:"user_id"`
+	RequestedByID uint       `json:"requested_by_id"`
+	Reason        string     `json:"reason"`
+	Status        string     `json:"status"`
+	Motif         string     `json:"motif,omitempty"`
+	ProcessedAt   *time.Time `json:"processed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type AuditChainResponse struct {
+	Valid         bool   `json:"valid"`
+	Checked       int    `json:"checked"`
+	FirstBrokenID *uint  `json:"first_broken_id,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+type ChildPINResponse struct {
+	Username string `json:"username"`
+	PIN      string `json:"pin"`
+}
+
+type ChildLoginCodeResponse struct {
+	Code      string `json:"code"`
+	ExpiresIn int64  `json:"expires_in"`
 }
 
 type AllTicketsResponse struct {
@@ -106,3 +378,50 @@ type UsersListResponse struct {
 }
 
 
+
+// OfflineConsentResponse : code est à afficher en QR code au stand, qui le scanne pour émettre l'autorisation
+type OfflineConsentResponse struct {
+	Code      string    `json:"code"`
+	StandID   uint      `json:"stand_id"`
+	Plafond   int64     `json:"plafond"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type OfflineAllowanceResponse struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"user_id"`
+	StandID   uint      `json:"stand_id"`
+	Plafond   int64     `json:"plafond"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Signature string    `json:"signature"`
+	SaleKey   string    `json:"sale_key"`
+}
+
+type OfflineSaleResult struct {
+	ClientRef     string `json:"client_ref"`
+	Statut        string `json:"statut"`
+	Motif         string `json:"motif,omitempty"`
+	Montant       int64  `json:"montant"`
+	TransactionID *uint  `json:"transaction_id,omitempty"`
+	Duplicate     bool   `json:"duplicate"`
+}
+
+type OfflineSyncResponse struct {
+	Results  []OfflineSaleResult `json:"results"`
+	Applied  int                 `json:"applied"`
+	Rejected int                 `json:"rejected"`
+}
+
+// PageResponse est l'enveloppe commune des listes paginées
+type PageResponse struct {
+	Data interface{} `json:"data"`
+	Page PageMeta    `json:"page"`
+}
+
+// PageMeta décrit la page renvoyée ; NextCursor est à repasser dans le paramètre cursor pour obtenir la suite
+type PageMeta struct {
+	Limit      int    `json:"limit" example:"50"`
+	Sort       string `json:"sort" example:"-id"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}